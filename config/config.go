@@ -2,47 +2,121 @@ package config
 
 import (
 	"fmt"
+	"image/color"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/phil-mansfield/rogue/error"
 )
 
-// Should contain only public fields and only those of type string.
+// Info contains every configuration variable. It should contain only public
+// fields, each of which declares its configuration through struct tags:
 //
-// TODO: consider whether or not it would be exceptionally paintful to do
-// otherwise. Since we're using reflection anyway, there's no reason to do this
-// other than laziness. There's also no reason to lock it in to the Info
-// type.
+//	default  The default value, written as it would be in a config file.
+//	convert  The ConvertFunc used to parse values (see parseConvertSpec).
+//	         If omitted, it is inferred from the field's type.
+//	help     A one-line description shown by Options.
+//
+// Struct fields with a section tag instead group the variables which may be
+// set under the [section] header of that name. Their variables are referred
+// to by the dotted path of field names, like "Display.FramesPerSecond".
+//
+// A field whose default or converter does not match its type results in a
+// Sanity error from Default.
 type Info struct {
-	FramesPerSecond int
+	Display  DisplayInfo  `section:"display"`
+	Input    InputInfo    `section:"input"`
+	Gameplay GameplayInfo `section:"gameplay"`
+	Logging  LoggingInfo  `section:"logging"`
+
+	FavoriteQuote string `default:"What I cannot create, I do not understand." help:"Your favorite quote."`
+	FavoriteNumber int `default:"1729" help:"Your favorite number."`
+}
+
+// DisplayInfo contains the variables in the [display] section.
+type DisplayInfo struct {
+	FramesPerSecond int `default:"20" convert:"int(1, 1000)" help:"Number of frames drawn per second."`
+	Terminal string `default:"curses" convert:"enum(curses, gl)" help:"Terminal backend used for drawing."`
+	ShowFrameRate bool `default:"false" help:"Whether the frame rate is shown on screen."`
+	MessageDelay time.Duration `default:"500ms" help:"How long each message is shown before the next one."`
+	ScrollSpeed float64 `default:"1" convert:"float(0.1, 10)" help:"Multiplier on the speed of scrolling text."`
+	HighlightColor color.RGBA `default:"yellow" help:"Color used to highlight the cursor and selections."`
+}
 
-	FavoriteQuote string
-	FavoriteNumber int
+// InputInfo contains the variables in the [input] section.
+type InputInfo struct {
+	KeyRepeatDelay time.Duration `default:"150ms" help:"Minimum time between repeats of a held key."`
+	ConfirmQuit bool `default:"true" help:"Whether quitting asks for confirmation."`
+}
+
+// GameplayInfo contains the variables in the [gameplay] section.
+type GameplayInfo struct {
+	AutoPickup []string `default:"potion, scroll" help:"Item classes which are picked up automatically."`
+	Permadeath bool `default:"false" help:"Whether new games delete their save file when the player dies."`
+	SaveFile string `default:"" help:"Save file location. Empty string indicates game.sav in the XDG state directory."`
+}
+
+// LoggingInfo contains the variables in the [logging] section.
+type LoggingInfo struct {
+	Level string `default:"warning" convert:"enum(debug, info, warning, error, off)" help:"Least severe level of message which is logged."`
+	Packages []string `default:"" help:"Packages whose messages are logged. Empty logs every package. Errors are always logged."`
+	File string `default:"" help:"Log file location. Empty string indicates rogue.log in the XDG state directory."`
+	MaxSize int `default:"1024" convert:"int(1, 1048576)" help:"Size in kilobytes at which the log file is rotated."`
+	MaxFiles int `default:"3" convert:"int(1, 100)" help:"Number of rotated log files which are kept."`
 }
 
 type varInfo struct {
 	defaultValue interface{}
 	convert      ConvertFunc
+	defaultStr   string
+	help         string
+	typ          reflect.Type
+	widget       Widget
+}
+
+// registry contains the bookkeeping information generated from the tags of
+// Info.
+type registry struct {
+	// names lists the dotted names of every configuration variable in the
+	// order they appear in Info.
+	names []string
+	infos map[string]varInfo
+	// sections maps the lower-case name of each section to the name of its
+	// field in Info.
+	sections map[string]string
 }
 
 var (
-	varInfos = map[string]varInfo{
-		"FramesPerSecond": {20, IntRangeConvert(1, 1000)},
-		"FavoriteQuote": {"What I cannot create, I do not understand.", NoConvert},
-		"FavoriteNumber": {1729, IntConvert},
-	}
+	varNames     []string
+	varInfos     map[string]varInfo
+	sectionNames map[string]string
+	// registryErr is the error, if any, encountered when building varInfos
+	// from the tags of Info. It is returned by Default.
+	registryErr *error.Error
 )
 
-// TODO: add Value error handling to this. (e.g. if the use gives a blank
-// string).
+func init() {
+	reg, err := buildRegistry(reflect.TypeOf(Info{}))
+	if err != nil {
+		registryErr = err
+		return
+	}
+	varNames, varInfos, sectionNames = reg.names, reg.infos, reg.sections
+}
 
 // Parse returns an Info instance corresponding to the configuration variables
 // set in fileName.
 //
+// Problems with individual lines, like malformed lines, unknown variables,
+// invalid values and variables which are assigned more than once, do not
+// stop parsing. Every valid assignment is applied to the returned Info, and
+// a single Configuration error describing every problem is returned. When a
+// variable is assigned more than once, the first assignment is used.
+//
 // Parse can return Configuration, Library, MissingFile, and Sanity errors.
 // The Info instance is valid (although perhaps not what the user wanted) as
 // long as a Sanity error is not returned.
@@ -52,57 +126,178 @@ func Parse(filePath string) (*Info, *error.Error) {
 		return nil, err
 	}
 
-	lines, err := readFile(filePath)
+	assignments, problems, err := readAssignments(filePath, []string{})
 	if err != nil {
 		return info, err
 	}
 
+	applied, err := applyAssignments(info, assignments, UserLayer, Origins{})
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, applied...)
+
+	return info, combineProblems(filePath, problems)
+}
+
+// applyAssignments sets the variables of info according to assignments, and
+// records in origins that their values came from the given layer. Problems
+// with individual assignments are returned in problems, while Sanity errors
+// are returned in err. When a variable is assigned more than once, the first
+// assignment is used.
+func applyAssignments(
+	info *Info, assignments []Assignment, layer Layer, origins Origins,
+) (problems []*error.Error, err *error.Error) {
+
 	reflectedInfo := reflect.Indirect(reflect.ValueOf(info))
+	assigned := make(map[string]Assignment)
+	problems = []*error.Error{}
 
-	for i, line := range lines {
+	for _, a := range assignments {
 
-		// Parse current line.
+		// Find the variable being assigned to.
 
-		field, value, desc := parseLine(line)
+		key, desc := resolveKey(a)
 		if desc != "" {
-			def, err := Default()
-			if err != nil {
-				return nil, err
-			}
+			problems = append(problems, AssignmentError(a, desc))
+			continue
+		}
 
-			return def, propogateParseError(i, filePath, desc)
-		} else if field == "" {
+		if prev, ok := assigned[key]; ok {
+			desc := fmt.Sprintf("Variable '%s' was already assigned by %s.",
+				key, prev.Location())
+			problems = append(problems, AssignmentError(a, desc))
 			continue
 		}
 
 		// Place converted value in info.
 
-		vInfo, ok := varInfos[field]
+		if val, ok, desc := varInfos[key].convert(a.Value); !ok {
+			desc = fmt.Sprintf("Invalid value for '%s': %s", key, desc)
+			problems = append(problems, AssignmentError(a, desc))
+			continue
+		} else if !setField(reflectedInfo, key, val) {
+			desc := fmt.Sprintf("setField returned false for field %s "+
+				"with value of type %T.", key, val)
+			return nil, error.New(error.Sanity, desc)
+		}
+
+		assigned[key] = a
+		origins[key] = Origin{layer, a.Location()}
+	}
+
+	return problems, nil
+}
+
+// resolveKey returns the dotted name of the variable an assignment refers
+// to. Outside of any section, fields may be given by their full dotted name,
+// or by their field name alone if only one section has a field of that name.
+// If the variable or section is unknown, the description suggests the
+// closest known name. An empty string in the desc return value indicates no
+// errors.
+func resolveKey(a Assignment) (key, desc string) {
+	key = a.Field
+	if a.Section != "" {
+		section, ok := sectionNames[strings.ToLower(a.Section)]
 		if !ok {
-			def, err := Default()
-			if err != nil { 
-				return nil, err
+			known := make([]string, 0, len(sectionNames))
+			for name := range sectionNames {
+				known = append(known, name)
 			}
+			desc = fmt.Sprintf("Unknown section '%s'.", a.Section)
+			return "", desc + suggestion(a.Section, known)
+		}
+		key = section + "." + a.Field
+	}
 
-			desc := fmt.Sprintf("Unknown variable '%s'.", field)
-			return def, propogateParseError(i, filePath, desc)
+	if _, ok := varInfos[key]; ok {
+		return key, ""
+	}
+
+	if a.Section == "" {
+		if key, ok := uniqueSuffix(a.Field); ok {
+			return key, ""
 		}
 
-		if  val, ok, desc := vInfo.convert(value); !ok {
-			def, err := Default()
-			if err != nil {
-				return nil, err
+		desc = fmt.Sprintf("Unknown variable '%s'.", a.Field)
+		return "", desc + suggestion(a.Field, varNames)
+	}
+
+	prefix := sectionNames[strings.ToLower(a.Section)] + "."
+	known := []string{}
+	for _, name := range varNames {
+		if strings.HasPrefix(name, prefix) {
+			known = append(known, strings.TrimPrefix(name, prefix))
+		}
+	}
+	desc = fmt.Sprintf("Unknown variable '%s' in section '%s'.",
+		a.Field, a.Section)
+	return "", desc + suggestion(a.Field, known)
+}
+
+// uniqueSuffix returns the dotted name of the only variable in a section
+// whose field name is field. ok is false if there is no such variable or if
+// several sections have one.
+func uniqueSuffix(field string) (key string, ok bool) {
+	for _, name := range varNames {
+		if strings.HasSuffix(name, "."+field) {
+			if ok {
+				return "", false
 			}
+			key, ok = name, true
+		}
+	}
+	return key, ok
+}
 
-			return def, propogateParseError(i, filePath, desc)
-		} else if !setField(reflectedInfo, field, val) {
-			desc := fmt.Sprintf("setField returned false for field %s.",
-				field)
-			return nil, error.New(error.Sanity, desc)
+// suggestion returns a sentence suggesting the name in known closest to
+// name, or the empty string if none are close enough to be likely typos.
+// Case is ignored when comparing names.
+func suggestion(name string, known []string) string {
+	best, bestDist := "", -1
+	for _, candidate := range known {
+		dist := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = candidate, dist
 		}
 	}
 
-	return info, nil
+	// Allow roughly one typo for every three characters.
+	if bestDist < 0 || bestDist > 2 + len(name)/3 {
+		return ""
+	}
+	return fmt.Sprintf(" Did you mean '%s'?", best)
+}
+
+// editDistance returns the Levenshtein distance between s1 and s2.
+func editDistance(s1, s2 string) int {
+	r1, r2 := []rune(s1), []rune(s2)
+	prev := make([]int, len(r2)+1)
+	curr := make([]int, len(r2)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(r1); i++ {
+		curr[0] = i
+		for j := 1; j <= len(r2); j++ {
+			cost := 1
+			if r1[i-1] == r2[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, minInt(curr[j-1]+1, prev[j-1]+cost))
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(r2)]
+}
+
+func minInt(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
 }
 
 // Default returns an Info instance containing the default values for all
@@ -112,53 +307,89 @@ func Parse(filePath string) (*Info, *error.Error) {
 // configuration variable bookkeeping. The returned Info instance will not be
 // valid if this error is returned.
 func Default() (*Info, *error.Error) {
+	if registryErr != nil {
+		return nil, registryErr
+	}
+
 	info := new(Info)
 	v := reflect.Indirect(reflect.ValueOf(info))
 
-	for key, vInfo := range varInfos {
-		field := v.FieldByName(key)
+	for _, key := range varNames {
+		vInfo := varInfos[key]
+		field := fieldByName(v, key)
 		if !field.IsValid() {
 			desc := fmt.Sprintf("Key '%s' in varInfos unmatched by field "+
 				"in Info struct.", key)
 			return nil, error.New(error.Sanity, desc)
+		} else if err := checkVarInfo(key, vInfo, field.Type()); err != nil {
+			return nil, err
 		}
-		field.Set(reflect.ValueOf(vInfo.defaultValue))
+		field.Set(copyValue(vInfo.defaultValue))
 	}
 
 	return info, nil
 }
 
+// copyValue returns a reflect.Value holding val. Slices are copied so that
+// modifying an Info instance cannot modify the defaults in varInfos.
+func copyValue(val interface{}) reflect.Value {
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Slice {
+		return v
+	}
+
+	c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(c, v)
+	return c
+}
+
+// checkVarInfo returns a Sanity error if either the default value of vInfo
+// or the result of its ConvertFunc cannot be assigned to a field of type
+// fieldType. The ConvertFunc is tested by converting the formatted default
+// value, which must also succeed.
+func checkVarInfo(key string, vInfo varInfo, fieldType reflect.Type) *error.Error {
+	defType := reflect.TypeOf(vInfo.defaultValue)
+	if defType == nil || !defType.AssignableTo(fieldType) {
+		desc := fmt.Sprintf("Default value of '%s' has type %v, but the "+
+			"Info field has type %v.", key, defType, fieldType)
+		return error.New(error.Sanity, desc)
+	}
+
+	str := formatValue(vInfo.defaultValue)
+	val, ok, convDesc := vInfo.convert(str)
+	if !ok {
+		desc := fmt.Sprintf("ConvertFunc of '%s' rejects its own default "+
+			"value '%s': %s", key, str, convDesc)
+		return error.New(error.Sanity, desc)
+	}
+
+	convType := reflect.TypeOf(val)
+	if convType == nil || !convType.AssignableTo(fieldType) {
+		desc := fmt.Sprintf("ConvertFunc of '%s' produces type %v, but the "+
+			"Info field has type %v.", key, convType, fieldType)
+		return error.New(error.Sanity, desc)
+	}
+
+	return nil
+}
+
 // readFile takes care of the boiler plate required to decompose a file into a
 // slice ofthe lines inside of that file, and potentially return an error.
 func readFile(filePath string) ([]string, *error.Error) {
 	if bytes, err := ioutil.ReadFile(filePath); err != nil {
-		if _, err := os.Stat(filePath); err == nil {
-			return []string{}, error.New(error.Library, err.Error())
+		if _, statErr := os.Stat(filePath); statErr == nil {
+			desc := fmt.Sprintf("Could not read config file '%s'.", filePath)
+			return []string{},
+				error.Wrap(error.Library, err, desc).With("path", filePath)
 		}
 		desc := fmt.Sprintf("Config file '%s' does not exist.", filePath)
-		return []string{}, error.New(error.MissingFile, desc)
+		return []string{},
+			error.Wrap(error.MissingFile, err, desc).With("path", filePath)
 	} else {
 		return strings.Split(string(bytes), "\n"), nil
 	}
 }
 
-// This function is awful. Rewrite as more tests are done.
-//
-// An empty string in the desc return value indicates no errors.
-func parseLine(line string) (field, value string, desc string) {
-	line = strings.Trim(line, " \t")
-	if line == "" {
-		return "", "", ""
-	}
-
-	subs := strings.SplitN(line, "=", 2)
-	if len(subs) < 2 {
-		return "", "", "No variable assignment in non-empty line."
-	}
-
-	return strings.Trim(subs[0], " \t"), strings.Trim(subs[1], " \t"), ""
-}
-
 // propogateParseError takes care of the boiler-plate code required to create
 // an Error instance from a description of a parsing error.
 //
@@ -166,26 +397,44 @@ func parseLine(line string) (field, value string, desc string) {
 // horribly, horribly wrong, it could return a Library error.
 func propogateParseError(lineNum int, filePath, desc string) *error.Error {
 	if absPath, pathErr := filepath.Abs(filePath); pathErr != nil {
-		return error.New(error.Library, pathErr.Error())
+		return error.Wrap(error.Library, pathErr, "Could not find "+
+			"absolute path of config file.").With("path", filePath)
 	} else {
 		fullDesc := fmt.Sprintf("%s On line %d of '%s'.",
 			desc, lineNum+1, absPath)
-		return error.New(error.Configuration, fullDesc)
+		return error.New(error.Configuration, fullDesc).
+			With("path", absPath).With("line", lineNum+1)
 	}
 }
 
 // setField attempts to set the field with name fieldName to value. The function
-// returns true on success and false if v had no such field.
-//
-// TODO: This function can panic if things go wrong due to design choices in
-// the standard library. Maybe fix that.
+// returns true on success and false if v had no such field or if value cannot
+// be assigned to it. Fields of nested structs may be named by their dotted
+// path.
 func setField(v reflect.Value, fieldName string, value interface{}) bool {
-	if field := v.FieldByName(fieldName); !field.IsValid() {
+	if field := fieldByName(v, fieldName); !field.IsValid() {
 		return false
 	} else if !field.CanSet() {
 		return false
+	} else if t := reflect.TypeOf(value); t == nil ||
+		!t.AssignableTo(field.Type()) {
+		return false
 	} else {
 		field.Set(reflect.ValueOf(value))
 		return true
 	}
 }
+
+// fieldByName returns the field of the struct v with the given dotted path.
+// The zero Value is returned if there is no such field.
+func fieldByName(v reflect.Value, path string) reflect.Value {
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}
+		}
+		if v = v.FieldByName(name); !v.IsValid() {
+			return v
+		}
+	}
+	return v
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/phil-mansfield/rogue/error"
 )
@@ -19,6 +24,17 @@ func TestParseLine(t *testing.T) {
 		{"", "", "", true},
 		{" 		 ", "", "", true},
 		{"What I cannot create, I do not understand.", "", "", false},
+		{"# A = B", "", "", true},
+		{"  # comment", "", "", true},
+		{"A = B # comment", "A", "B", true},
+		{"A = # comment", "A", "", true},
+		{"A # = B", "", "", false},
+		{"= B", "", "", false},
+		{`A = "B # C"`, "A", "B # C", true},
+		{`A = "B \"C\"\n" # comment`, "A", "B \"C\"\n", true},
+		{`A = "B`, "", "", false},
+		{`A = "B" C`, "", "", false},
+		{`A = "\q"`, "", "", false},
 	}
 
 	for i, test := range tests {
@@ -120,7 +136,7 @@ func TestParse(t *testing.T) {
 		{"test_config_files/valid_config.txt",
 		"Do not destroy what you cannot create.", 3, true, 0},
 		{"test_config_files/invalid_var_config.txt",
-		"What I cannot create, I do not understand.", 1729,
+		"Do not destroy what you cannot create.", 3,
 		false, error.Configuration},
 		{"test_config_files/does_not_exist.txt",
 		"What I cannot create, I do not understand.", 1729,
@@ -144,13 +160,235 @@ func TestParse(t *testing.T) {
 				"but got '%s' instead.", i, test.filePath, test.favoriteQuote,
 				info.FavoriteQuote)
 		} else if info.FavoriteNumber != test.favoriteNumber {
-			t.Errorf("Test %d: Expected '%s' to give FavoriteNumber = '%s'," +
-				"but got '%s' instead.", i, test.filePath, test.favoriteNumber,
+			t.Errorf("Test %d: Expected '%s' to give FavoriteNumber = %d," +
+				"but got %d instead.", i, test.filePath, test.favoriteNumber,
 				info.FavoriteNumber)
 		}
 	}
 }
 
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		convert ConvertFunc
+		in      string
+		val     interface{}
+		isValid bool
+	}{
+		{BoolConvert, "true", true, true},
+		{BoolConvert, "Off", false, true},
+		{BoolConvert, "maybe", nil, false},
+		{FloatConvert, "2.5", 2.5, true},
+		{FloatConvert, "two", nil, false},
+		{FloatRangeConvert(0, 1), "0.5", 0.5, true},
+		{FloatRangeConvert(0, 1), "1.5", nil, false},
+		{FloatRangeConvert(0, 1), "NaN", nil, false},
+		{IntRangeConvert(0, 10), "11", nil, false},
+		{EnumConvert("curses", "gl"), "GL", "gl", true},
+		{EnumConvert("curses", "gl"), "sdl", nil, false},
+		{DurationConvert, "1.5s", 1500 * time.Millisecond, true},
+		{DurationConvert, "-1s", nil, false},
+		{DurationConvert, "1 second", nil, false},
+		{ColorConvert, "Red", color.RGBA{255, 0, 0, 255}, true},
+		{ColorConvert, "#102030", color.RGBA{16, 32, 48, 255}, true},
+		{ColorConvert, "#10203", nil, false},
+		{ColorConvert, "octarine", nil, false},
+	}
+
+	for i, test := range tests {
+		val, ok, desc := test.convert(test.in)
+		if test.isValid && !ok {
+			t.Errorf("Test %d: Expected '%s' to convert to %v, but got "+
+				"'%s'.", i, test.in, test.val, desc)
+		} else if !test.isValid && ok {
+			t.Errorf("Test %d: Expected '%s' to be invalid, but got %v.",
+				i, test.in, val)
+		} else if test.isValid && val != test.val {
+			t.Errorf("Test %d: Expected '%s' to convert to %v, but got %v.",
+				i, test.in, test.val, val)
+		}
+	}
+
+	val, _, _ := ListConvert(" a, b ,,c ")
+	if list := val.([]string); !stringSliceEqual(list, []string{"a", "b", "c"}) {
+		t.Errorf("ListConvert gave %v instead of [a b c].", list)
+	}
+}
+
+func TestCheckVarInfo(t *testing.T) {
+	intType := reflect.TypeOf(0)
+
+	tests := []struct {
+		vInfo   varInfo
+		isValid bool
+	}{
+		{varInfo{defaultValue: 1, convert: IntConvert}, true},
+		{varInfo{defaultValue: "1", convert: IntConvert}, false},
+		{varInfo{defaultValue: 1, convert: NoConvert}, false},
+		{varInfo{defaultValue: 1, convert: BoolConvert}, false},
+		{varInfo{defaultValue: 20, convert: IntRangeConvert(1, 10)}, false},
+		{varInfo{defaultValue: nil, convert: IntConvert}, false},
+	}
+
+	for i, test := range tests {
+		err := checkVarInfo("Test", test.vInfo, intType)
+		if test.isValid && err != nil {
+			t.Errorf("Test %d: Unexpected error: %s", i, err.Error())
+		} else if !test.isValid && err == nil {
+			t.Errorf("Test %d: Mismatched varInfo marked as valid.", i)
+		} else if !test.isValid && err.Code != error.Sanity {
+			t.Errorf("Test %d: Expected Sanity error, got %s.", i, err.Code)
+		}
+	}
+
+	if _, err := Default(); err != nil {
+		t.Errorf("Default() failed: %s", err.Error())
+	}
+}
+
+func TestParseSection(t *testing.T) {
+	tests := []struct {
+		in      string
+		name    string
+		ok      bool
+		isValid bool
+	}{
+		{"[display]", "display", true, true},
+		{" [ input ] # comment", "input", true, true},
+		{"A = [B]", "", false, true},
+		{"[display", "", false, false},
+		{"[]", "", false, false},
+	}
+
+	for i, test := range tests {
+		name, ok, desc := parseSection(test.in)
+		if test.isValid != (desc == "") {
+			t.Errorf("Test %d: Expected parseSection('%s') to be %s, but "+
+				"got desc = '%s'.", i, test.in, boolToValid(test.isValid), desc)
+		} else if name != test.name || ok != test.ok {
+			t.Errorf("Test %d: Expected parseSection('%s') to give ('%s', "+
+				"%v), but got ('%s', %v).",
+				i, test.in, test.name, test.ok, name, ok)
+		}
+	}
+}
+
+func TestSectionsAndIncludes(t *testing.T) {
+	info, err := Parse("test_config_files/include_config.txt")
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err.VerboseError())
+	}
+
+	if info.Display.FramesPerSecond != 30 {
+		t.Errorf("Display.FramesPerSecond = %d, not 30.",
+			info.Display.FramesPerSecond)
+	}
+	if info.Input.ConfirmQuit {
+		t.Errorf("Input.ConfirmQuit = true, not false.")
+	}
+	if info.FavoriteNumber != 12 {
+		t.Errorf("FavoriteNumber = %d, not 12.", info.FavoriteNumber)
+	}
+	if info.FavoriteQuote != "Quoted # \"text\"" {
+		t.Errorf("FavoriteQuote = '%s'.", info.FavoriteQuote)
+	}
+	if info.Display.ScrollSpeed != 3 {
+		t.Errorf("Display.ScrollSpeed = %g, not 3.", info.Display.ScrollSpeed)
+	}
+	if info.Input.KeyRepeatDelay != 100*time.Millisecond {
+		t.Errorf("Input.KeyRepeatDelay = %s, not 100ms.",
+			info.Input.KeyRepeatDelay)
+	}
+
+	tests := []struct {
+		filePath string
+		line     string
+	}{
+		{"test_config_files/include_cycle.txt", "line 2 of"},
+		{"test_config_files/include_missing.txt", "line 1 of"},
+		{"test_config_files/unknown_section.txt", "line 3 of"},
+	}
+
+	for i, test := range tests {
+		_, err := Parse(test.filePath)
+		if err == nil {
+			t.Errorf("Test %d: Parse('%s') succeeded.", i, test.filePath)
+		} else if err.Code != error.Configuration {
+			t.Errorf("Test %d: Expected Configuration error, got %s.",
+				i, err.Error())
+		} else if !strings.Contains(err.Description, test.line) {
+			t.Errorf("Test %d: Expected error on %s, got '%s'.",
+				i, test.line, err.Description)
+		} else if strings.Count(err.Error(), "does not exist") > 1 {
+			t.Errorf("Test %d: Error repeats its description: %s",
+				i, err.Error())
+		}
+	}
+}
+
+func TestParseProblems(t *testing.T) {
+	info, err := Parse("test_config_files/many_problems.txt")
+	if err == nil {
+		t.Fatalf("Parse succeeded on file with problems.")
+	} else if err.Code != error.Configuration {
+		t.Fatalf("Expected Configuration error, got %s.", err.Error())
+	}
+
+	// Valid assignments are kept, even after problems.
+	if info.FavoriteNumber != 7 {
+		t.Errorf("FavoriteNumber = %d, not 7.", info.FavoriteNumber)
+	}
+	if info.Display.FramesPerSecond != 40 {
+		t.Errorf("Display.FramesPerSecond = %d, not 40.",
+			info.Display.FramesPerSecond)
+	}
+	if info.Input.ConfirmQuit != false {
+		t.Errorf("Input.ConfirmQuit = true, not false.")
+	}
+
+	problems := []string{
+		"Did you mean 'FavoriteQuote'? On line 2 ",
+		"No variable assignment in non-empty line. On line 3 ",
+		"Invalid value for 'Input.KeyRepeatDelay'",
+		"Did you mean 'FramesPerSecond'? On line 7 ",
+		"already assigned by line 6 ",
+		"Did you mean 'input'? On line 10 ",
+	}
+
+	if len(err.Errors) != len(problems) {
+		t.Errorf("Expected %d problems, got %d:\n%s",
+			len(problems), len(err.Errors), err.Description)
+	}
+	for i, problem := range problems {
+		if !strings.Contains(err.Description, problem) {
+			t.Errorf("Test %d: Error does not contain '%s':\n%s",
+				i, problem, err.Description)
+		}
+	}
+
+	if s := suggestion("Frobnicate", varNames); s != "" {
+		t.Errorf("Unrelated name given suggestion '%s'.", s)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		s1, s2 string
+		dist   int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"FramesPerSecond", "FramesPerSecnod", 2},
+	}
+
+	for i, test := range tests {
+		if dist := editDistance(test.s1, test.s2); dist != test.dist {
+			t.Errorf("Test %d: editDistance('%s', '%s') = %d, not %d.",
+				i, test.s1, test.s2, dist, test.dist)
+		}
+	}
+}
+
 func boolToValid(b bool) string {
 	if b {
 		return "valid"
@@ -170,4 +408,454 @@ func stringSliceEqual(s1, s2 []string) bool {
 	}
 
 	return true
-}
\ No newline at end of file
+}
+func TestParseTyped(t *testing.T) {
+	info, err := Parse("test_config_files/typed_config.txt")
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err.VerboseError())
+	}
+
+	if info.Display.Terminal != "gl" {
+		t.Errorf("Terminal = '%s', not 'gl'.", info.Display.Terminal)
+	}
+	if !info.Display.ShowFrameRate {
+		t.Errorf("ShowFrameRate = false, not true.")
+	}
+	if info.Display.MessageDelay != 250*time.Millisecond {
+		t.Errorf("MessageDelay = %s, not 250ms.", info.Display.MessageDelay)
+	}
+	if info.Display.ScrollSpeed != 2.5 {
+		t.Errorf("ScrollSpeed = %g, not 2.5.", info.Display.ScrollSpeed)
+	}
+	if info.Display.HighlightColor != (color.RGBA{255, 128, 0, 255}) {
+		t.Errorf("HighlightColor = %v, not orange.", info.Display.HighlightColor)
+	}
+	if !stringSliceEqual(info.Gameplay.AutoPickup, []string{"wand", "ring"}) {
+		t.Errorf("AutoPickup = %v, not [wand ring].", info.Gameplay.AutoPickup)
+	}
+
+	def, _ := Default()
+	if !stringSliceEqual(def.Gameplay.AutoPickup, []string{"potion", "scroll"}) {
+		t.Errorf("Parse modified default AutoPickup to %v.", def.Gameplay.AutoPickup)
+	}
+}
+
+type badDefaultInfo struct {
+	Number int `default:"seven" help:"A number."`
+}
+
+type badConvertInfo struct {
+	Number int `default:"7" convert:"bool" help:"A number."`
+}
+
+type badSpecInfo struct {
+	Number int `default:"7" convert:"int(1)" help:"A number."`
+}
+
+type noHelpInfo struct {
+	Number int `default:"7"`
+}
+
+type badTypeInfo struct {
+	Numbers []int `default:"7" help:"Some numbers."`
+}
+
+type goodInfo struct {
+	Number int `default:"7" convert:"int(0, 10)" help:"A number."`
+	Mode string `default:"b" convert:"enum(a, b)" help:"A mode."`
+	Sub struct {
+		Word string `default:"bird" help:"A word."`
+	} `section:"sub"`
+}
+
+type nestedSectionInfo struct {
+	Outer struct {
+		Inner struct {
+			Word string `default:"bird" help:"A word."`
+		} `section:"inner"`
+	} `section:"outer"`
+}
+
+type badSectionInfo struct {
+	Number int `default:"7" help:"A number." section:"number"`
+}
+
+func TestBuildRegistry(t *testing.T) {
+	tests := []struct {
+		info    interface{}
+		isValid bool
+	}{
+		{goodInfo{}, true},
+		{Info{}, true},
+		{badDefaultInfo{}, false},
+		{badConvertInfo{}, false},
+		{badSpecInfo{}, false},
+		{noHelpInfo{}, false},
+		{badTypeInfo{}, false},
+		{nestedSectionInfo{}, false},
+		{badSectionInfo{}, false},
+	}
+
+	for i, test := range tests {
+		reg, err := buildRegistry(reflect.TypeOf(test.info))
+		if test.isValid && err != nil {
+			t.Errorf("Test %d: Unexpected error: %s", i, err.Error())
+		} else if !test.isValid && err == nil {
+			t.Errorf("Test %d: Expected Sanity error, got names %v.",
+				i, reg.names)
+		} else if !test.isValid && err.Code != error.Sanity {
+			t.Errorf("Test %d: Expected Sanity error, got %s.", i, err.Code)
+		}
+	}
+
+	reg, _ := buildRegistry(reflect.TypeOf(goodInfo{}))
+	names := []string{"Number", "Mode", "Sub.Word"}
+	if !stringSliceEqual(reg.names, names) {
+		t.Errorf("Registry names are %v instead of %v.", reg.names, names)
+	} else if reg.sections["sub"] != "Sub" {
+		t.Errorf("Registry sections are %v.", reg.sections)
+	} else if reg.infos["Number"].defaultValue != 7 {
+		t.Errorf("Default of Number is %v.", reg.infos["Number"].defaultValue)
+	} else if _, ok, _ := reg.infos["Number"].convert("11"); ok {
+		t.Errorf("Converter of Number accepts 11.")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	opts, err := Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(opts) != len(varNames) {
+		t.Errorf("Options() gave %d options for %d variables.",
+			len(opts), len(varNames))
+	}
+	if opts[0].Name != "Display.FramesPerSecond" || opts[0].Default != "20" {
+		t.Errorf("First option is %v.", opts[0])
+	}
+
+	if text, err := HelpText(); err != nil {
+		t.Error(err)
+	} else if !strings.Contains(text,
+		"Display.FramesPerSecond (int, default: 20)") {
+		t.Errorf("HelpText() is missing FramesPerSecond:\n%s", text)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	src := Sources{
+		SystemPath: "test_config_files/layer_system.txt",
+		UserPath:   "test_config_files/layer_user.txt",
+		Env: []string{
+			"HOME=/nonexistent",
+			"ROGUE_DISPLAY_SHOWFRAMERATE=yes",
+			"rogue_favoritenumber=3",
+		},
+		Overrides: []string{"FramesPerSecond = 50", "FavoriteQuote=\"a # b\""},
+	}
+
+	info, origins, err := Load(src)
+	if err != nil {
+		t.Fatalf("Load failed: %s", err.VerboseError())
+	}
+
+	tests := []struct {
+		key      string
+		value    interface{}
+		layer    Layer
+		location string
+	}{
+		{"Display.FramesPerSecond", 50, FlagLayer, "-set"},
+		{"Display.Terminal", "gl", SystemLayer, "line 6 of"},
+		{"Display.ShowFrameRate", true, EnvironmentLayer,
+			"ROGUE_DISPLAY_SHOWFRAMERATE"},
+		{"Input.ConfirmQuit", false, UserLayer, "line 5 of"},
+		{"Input.KeyRepeatDelay", 150 * time.Millisecond, DefaultLayer, ""},
+		{"FavoriteNumber", 3, EnvironmentLayer, "rogue_favoritenumber"},
+		{"FavoriteQuote", "a # b", FlagLayer, "-set"},
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(info))
+	for i, test := range tests {
+		if val := fieldByName(v, test.key).Interface(); val != test.value {
+			t.Errorf("Test %d: %s = %v, not %v.", i, test.key, val, test.value)
+		}
+		origin := origins[test.key]
+		if origin.Layer != test.layer {
+			t.Errorf("Test %d: %s came from %s layer, not %s.",
+				i, test.key, origin.Layer, test.layer)
+		} else if !strings.Contains(origin.Location, test.location) {
+			t.Errorf("Test %d: %s has location '%s', expected '%s'.",
+				i, test.key, origin.Location, test.location)
+		}
+	}
+
+	report, err := Report(info, origins)
+	if err != nil {
+		t.Fatalf("Report failed: %s", err.VerboseError())
+	}
+	if !strings.Contains(report, "Display.Terminal = gl    # system file:") {
+		t.Errorf("Report does not list Display.Terminal:\n%s", report)
+	}
+}
+
+func TestLoadProblems(t *testing.T) {
+	// Missing XDG files are skipped.
+	_, _, err := Load(Sources{Env: []string{"XDG_CONFIG_HOME=/nonexistent",
+		"XDG_CONFIG_DIRS=/nonexistent"}})
+	if err != nil {
+		t.Errorf("Load with missing XDG files failed: %s", err.VerboseError())
+	}
+
+	// Explicit files are not.
+	_, _, err = Load(Sources{UserPath: "test_config_files/does_not_exist.txt"})
+	if err == nil || err.Code != error.MissingFile {
+		t.Errorf("Expected MissingFile error for missing user file.")
+	}
+
+	src := Sources{
+		UserPath:  "test_config_files/layer_user.txt",
+		Env:       []string{"ROGUE_DISPLAY_FRAMESPERSECOMD=10"},
+		Overrides: []string{"FramesPerSecond", "ConfirmQuit = maybe"},
+	}
+	info, _, err := Load(src)
+	if err == nil {
+		t.Fatalf("Load with bad overrides succeeded.")
+	} else if info.Display.FramesPerSecond != 40 {
+		t.Errorf("Display.FramesPerSecond = %d, not 40.",
+			info.Display.FramesPerSecond)
+	}
+
+	problems := []string{
+		"Did you mean 'ROGUE_DISPLAY_FRAMESPERSECOND'?",
+		"In -set 'FramesPerSecond'.",
+		"Invalid value for 'Input.ConfirmQuit'",
+	}
+	if len(err.Errors) != len(problems) {
+		t.Errorf("Expected %d problems, got %d:\n%s",
+			len(problems), len(err.Errors), err.Description)
+	}
+	for i, problem := range problems {
+		if !strings.Contains(err.Description, problem) {
+			t.Errorf("Test %d: Error does not contain '%s':\n%s",
+				i, problem, err.Description)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	info, err := Parse("test_config_files/typed_config.txt")
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err.VerboseError())
+	}
+	info.FavoriteQuote = "  A quote with # and \"quotes\" "
+	info.Display.HighlightColor = color.RGBA{1, 2, 3, 255}
+
+	dir := t.TempDir()
+	dumpPath := filepath.Join(dir, "sub", "dump.txt")
+	if err = WriteFile(dumpPath, info); err != nil {
+		t.Fatalf("WriteFile failed: %s", err.VerboseError())
+	}
+
+	dumped, err := Parse(dumpPath)
+	if err != nil {
+		t.Fatalf("Parse of dump failed: %s", err.VerboseError())
+	} else if !reflect.DeepEqual(info, dumped) {
+		t.Errorf("Dumped Info %v differs from original %v.", dumped, info)
+	}
+
+	templatePath := filepath.Join(dir, "template.txt")
+	if err = WriteTemplate(templatePath); err != nil {
+		t.Fatalf("WriteTemplate failed: %s", err.VerboseError())
+	}
+
+	templated, err := Parse(templatePath)
+	if err != nil {
+		t.Fatalf("Parse of template failed: %s", err.VerboseError())
+	}
+	defaults, _ := Default()
+	if !reflect.DeepEqual(defaults, templated) {
+		t.Errorf("Template Info %v differs from defaults %v.",
+			templated, defaults)
+	}
+
+	text, _ := Template()
+	if !strings.Contains(text, "# FramesPerSecond = 20\n") {
+		t.Errorf("Template does not contain commented FramesPerSecond:\n%s",
+			text)
+	}
+
+	if err = WriteTemplate(templatePath); err == nil {
+		t.Errorf("WriteTemplate overwrote an existing file.")
+	} else if err.Code != error.Value {
+		t.Errorf("Expected Value error, got %s.", err.Error())
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, "config.txt")
+	includePath := filepath.Join(dir, "included.txt")
+
+	write := func(path, text string) {
+		if writeErr := ioutil.WriteFile(path, []byte(text), 0644); writeErr != nil {
+			t.Fatal(writeErr)
+		}
+	}
+
+	write(includePath, "FavoriteNumber = 5\n")
+	write(userPath, "include included.txt\n[display]\nFramesPerSecond = 30\n")
+
+	w, info, _, err := NewWatcher(Sources{UserPath: userPath})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %s", err.VerboseError())
+	} else if info.Display.FramesPerSecond != 30 {
+		t.Errorf("Display.FramesPerSecond = %d, not 30.",
+			info.Display.FramesPerSecond)
+	}
+
+	if _, _, changed, _ := w.Check(); changed {
+		t.Errorf("Check reported a change to unchanged files.")
+	}
+
+	write(userPath, "include included.txt\n[display]\nFramesPerSecond = 120\n")
+	info, origins, changed, err := w.Check()
+	if !changed || err != nil {
+		t.Fatalf("Check after edit gave changed = %v, err = %v.", changed, err)
+	} else if info.Display.FramesPerSecond != 120 {
+		t.Errorf("Display.FramesPerSecond = %d, not 120.",
+			info.Display.FramesPerSecond)
+	} else if origins["FavoriteNumber"].Layer != UserLayer {
+		t.Errorf("FavoriteNumber came from %s layer, not user file.",
+			origins["FavoriteNumber"].Layer)
+	}
+
+	write(includePath, "FavoriteNumber = many\n")
+	info, _, changed, err = w.Check()
+	if !changed || err == nil || info != nil {
+		t.Errorf("Check after bad edit to included file gave changed = %v, "+
+			"err = %v, info = %v.", changed, err, info)
+	}
+
+	if _, _, changed, _ = w.Check(); changed {
+		t.Errorf("Check reported the same bad edit twice.")
+	}
+
+	write(includePath, "FavoriteNumber = 8\n")
+	info, _, changed, err = w.Check()
+	if !changed || err != nil {
+		t.Fatalf("Check after fix gave changed = %v, err = %v.", changed, err)
+	} else if info.FavoriteNumber != 8 {
+		t.Errorf("FavoriteNumber = %d, not 8.", info.FavoriteNumber)
+	}
+}
+
+func TestSetAndEditUserFile(t *testing.T) {
+	info, _ := Default()
+
+	tests := []struct {
+		name, value string
+		code        error.ErrorCode
+		valid       bool
+	}{
+		{"Display.FramesPerSecond", "60", 0, true},
+		{"Display.FramesPerSecond", "0", error.Value, false},
+		{"Display.Terminal", "GL", 0, true},
+		{"Gameplay.AutoPickup", "ring,amulet", 0, true},
+		{"FramesPerSecond", "60", error.Value, false},
+	}
+
+	for i, test := range tests {
+		err := Set(info, test.name, test.value)
+		if test.valid && err != nil {
+			t.Errorf("Test %d: Set(%s, %s) failed: %s",
+				i, test.name, test.value, err.Error())
+		} else if !test.valid && (err == nil || err.Code != test.code) {
+			t.Errorf("Test %d: Set(%s, %s) did not give a %s error.",
+				i, test.name, test.value, test.code)
+		}
+	}
+
+	if value, _ := Value(info, "Gameplay.AutoPickup"); value != "ring, amulet" {
+		t.Errorf("Value of Gameplay.AutoPickup is '%s'.", value)
+	}
+	if value, _ := Value(info, "Display.Terminal"); value != "gl" {
+		t.Errorf("Value of Display.Terminal is '%s'.", value)
+	}
+
+	copied, _ := Copy(info)
+	copied.Gameplay.AutoPickup[0] = "sword"
+	if info.Gameplay.AutoPickup[0] != "ring" {
+		t.Errorf("Copy shares slices with the original.")
+	}
+
+	// Only the edited assignments change. Comments, includes, invalid lines
+	// and values from included files are left alone.
+	dir := t.TempDir()
+	includePath := filepath.Join(dir, "include.txt")
+	userPath := filepath.Join(dir, "config.txt")
+	original := strings.Join([]string{
+		"# My settings.",
+		"include \"include.txt\"",
+		"",
+		"[display]",
+		"FramesPerSecond = 30 # Slow computer.",
+		"NotAVariable = 3",
+		"",
+		"[gameplay]",
+		"",
+	}, "\n")
+	for path, text := range map[string]string{
+		includePath: "[display]\nTerminal = \"gl\"\n", userPath: original,
+	} {
+		if writeErr := ioutil.WriteFile(path, []byte(text), 0644); writeErr != nil {
+			t.Fatalf("Could not write %s: %s", path, writeErr)
+		}
+	}
+
+	keys := []string{"Display.FramesPerSecond", "Gameplay.AutoPickup"}
+	if err := EditUserFile(userPath, info, keys); err != nil {
+		t.Fatalf("EditUserFile failed: %s", err.VerboseError())
+	}
+
+	text, readErr := ioutil.ReadFile(userPath)
+	if readErr != nil {
+		t.Fatalf("Could not read user file: %s", readErr)
+	}
+	expected := strings.Join([]string{
+		"# My settings.",
+		"include \"include.txt\"",
+		"",
+		"[display]",
+		"FramesPerSecond = 60 # Slow computer.",
+		"NotAVariable = 3",
+		"",
+		"[gameplay]",
+		"AutoPickup = ring, amulet",
+		"",
+	}, "\n")
+	if string(text) != expected {
+		t.Errorf("Edited user file is\n%s\nnot\n%s", text, expected)
+	}
+
+	if err := EditUserFile(userPath, info, []string{"Terminal"}); err == nil ||
+		err.Code != error.Value {
+		t.Errorf("EditUserFile with an unknown key did not give a Value error.")
+	}
+
+	// A missing user file starts from the template.
+	newPath := filepath.Join(dir, "new.txt")
+	if err := EditUserFile(newPath, info, keys[:1]); err != nil {
+		t.Fatalf("EditUserFile of a new file failed: %s", err.VerboseError())
+	}
+	saved, err := Parse(newPath)
+	if err != nil {
+		t.Fatalf("Parse of new user file failed: %s", err.VerboseError())
+	} else if saved.Display.FramesPerSecond != 60 {
+		t.Errorf("Saved Display.FramesPerSecond = %d, not 60.",
+			saved.Display.FramesPerSecond)
+	} else if saved.Display.Terminal != "curses" {
+		t.Errorf("Unedited Display.Terminal was saved to the user file.")
+	}
+}
@@ -2,7 +2,10 @@ package config
 
 import (
 	"fmt"
+	"image/color"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Functions of type CheckFunc takes in a string and converts it to a value.
@@ -32,10 +35,149 @@ func IntRangeConvert(low, high int) ConvertFunc {
 
 		if num < low || num > high {
 			desc := fmt.Sprintf("Value %d is outside of range [%d, %d]",
-				val, low, high)
+				num, low, high)
 			return 0, false, desc
 		}
 
 		return num, true, ""
 	}
-}
\ No newline at end of file
+}
+
+// BoolConvert converts true/false, yes/no, on/off and 1/0 to a bool. Case is
+// ignored.
+func BoolConvert(str string) (val interface{}, ok bool, desc string) {
+	switch strings.ToLower(str) {
+	case "true", "yes", "on", "1":
+		return true, true, ""
+	case "false", "no", "off", "0":
+		return false, true, ""
+	}
+	return false, false, fmt.Sprintf("'%s' is not a boolean value.", str)
+}
+
+// FloatConvert converts a string to a float64.
+func FloatConvert(str string) (val interface{}, ok bool, desc string) {
+	num, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0.0, false, err.Error()
+	}
+	return num, true, ""
+}
+
+// FloatRangeConvert returns a ConvertFunc which converts a string to a
+// float64 within the range [low, high].
+func FloatRangeConvert(low, high float64) ConvertFunc {
+	return func(str string) (val interface{}, ok bool, desc string) {
+		num, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return 0.0, false, err.Error()
+		}
+
+		if !(num >= low && num <= high) {
+			desc := fmt.Sprintf("Value %g is outside of range [%g, %g]",
+				num, low, high)
+			return 0.0, false, desc
+		}
+
+		return num, true, ""
+	}
+}
+
+// EnumConvert returns a ConvertFunc which accepts only the given options,
+// ignoring case. The converted value is the option as it was given to
+// EnumConvert.
+func EnumConvert(options ...string) ConvertFunc {
+	return func(str string) (val interface{}, ok bool, desc string) {
+		for _, option := range options {
+			if strings.EqualFold(str, option) {
+				return option, true, ""
+			}
+		}
+
+		desc = fmt.Sprintf("'%s' is not one of %s.",
+			str, strings.Join(options, ", "))
+		return "", false, desc
+	}
+}
+
+// ListConvert converts a comma-separated list to a []string. Whitespace
+// around each element is removed and empty elements are dropped.
+func ListConvert(str string) (val interface{}, ok bool, desc string) {
+	list := []string{}
+	for _, elem := range strings.Split(str, ",") {
+		if elem = strings.Trim(elem, " \t"); elem != "" {
+			list = append(list, elem)
+		}
+	}
+	return list, true, ""
+}
+
+// DurationConvert converts a string like "1.5s" or "300ms" to a
+// non-negative time.Duration.
+func DurationConvert(str string) (val interface{}, ok bool, desc string) {
+	dur, err := time.ParseDuration(str)
+	if err != nil {
+		return time.Duration(0), false, err.Error()
+	} else if dur < 0 {
+		desc := fmt.Sprintf("Duration %s is negative.", dur)
+		return time.Duration(0), false, desc
+	}
+	return dur, true, ""
+}
+
+// colorNames are the colors which can be referred to by name in config
+// files. They correspond to the colors supported by the term package.
+var colorNames = map[string]color.RGBA{
+	"black":  {0, 0, 0, 255},
+	"white":  {255, 255, 255, 255},
+	"gray":   {128, 128, 128, 255},
+	"red":    {255, 0, 0, 255},
+	"green":  {0, 255, 0, 255},
+	"blue":   {0, 0, 255, 255},
+	"cyan":   {0, 255, 255, 255},
+	"pink":   {255, 128, 192, 255},
+	"yellow": {255, 255, 0, 255},
+	"purple": {128, 0, 255, 255},
+	"brown":  {128, 64, 0, 255},
+	"orange": {255, 128, 0, 255},
+}
+
+// ColorConvert converts either a color name (like "red") or a hex string of
+// the form "#rrggbb" to a color.RGBA.
+func ColorConvert(str string) (val interface{}, ok bool, desc string) {
+	if c, ok := colorNames[strings.ToLower(str)]; ok {
+		return c, true, ""
+	}
+
+	if len(str) == 7 && str[0] == '#' {
+		if rgb, err := strconv.ParseUint(str[1:], 16, 32); err == nil {
+			c := color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 255}
+			return c, true, ""
+		}
+	}
+
+	return color.RGBA{}, false, fmt.Sprintf(
+		"'%s' is neither a color name nor of the form #rrggbb.", str,
+	)
+}
+
+// formatValue returns the string which the ConvertFunc for a value of val's
+// type would convert back into val.
+func formatValue(val interface{}) string {
+	switch v := val.(type) {
+	case []string:
+		return strings.Join(v, ", ")
+	case time.Duration:
+		return v.String()
+	case color.RGBA:
+		for name, c := range colorNames {
+			if c == v {
+				return name
+			}
+		}
+		return fmt.Sprintf("#%02x%02x%02x", v.R, v.G, v.B)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(val)
+}
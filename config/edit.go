@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// Copy returns a deep copy of info, so that changes to the copy do not affect
+// the original.
+//
+// Copy returns the same Sanity errors as Default.
+func Copy(info *Info) (*Info, *error.Error) {
+	if registryErr != nil {
+		return nil, registryErr
+	}
+
+	dst := new(Info)
+	srcValue := reflect.Indirect(reflect.ValueOf(info))
+	dstValue := reflect.Indirect(reflect.ValueOf(dst))
+	for _, key := range varNames {
+		field := fieldByName(srcValue, key)
+		if !field.IsValid() {
+			desc := fmt.Sprintf("Key '%s' in varInfos unmatched by field "+
+				"in Info struct.", key)
+			return nil, error.New(error.Sanity, desc)
+		}
+		fieldByName(dstValue, key).Set(copyValue(field.Interface()))
+	}
+
+	return dst, nil
+}
+
+// Value returns the value of the variable with the given dotted name in
+// info, written as it would be in a config file.
+//
+// A Value error is returned if there is no such variable.
+func Value(info *Info, name string) (string, *error.Error) {
+	if _, ok := varInfos[name]; !ok {
+		desc := fmt.Sprintf("There is no config variable named '%s'.", name)
+		return "", error.New(error.Value, desc)
+	}
+
+	field := fieldByName(reflect.Indirect(reflect.ValueOf(info)), name)
+	return formatValue(field.Interface()), nil
+}
+
+// Set converts str with the ConvertFunc of the variable with the given dotted
+// name and assigns the result to that variable in info.
+//
+// Since configuration errors cannot occur after initialization, a Value
+// error is returned if there is no such variable or if str is not a valid
+// value for it. A Sanity error is returned if the converted value cannot be
+// assigned to the field.
+func Set(info *Info, name, str string) *error.Error {
+	vInfo, ok := varInfos[name]
+	if !ok {
+		desc := fmt.Sprintf("There is no config variable named '%s'.", name)
+		return error.New(error.Value, desc)
+	}
+
+	val, ok, desc := vInfo.convert(str)
+	if !ok {
+		desc = fmt.Sprintf("Invalid value for '%s': %s", name, desc)
+		return error.New(error.Value, desc)
+	}
+
+	if !setField(reflect.Indirect(reflect.ValueOf(info)), name, val) {
+		desc := fmt.Sprintf("setField returned false for field %s "+
+			"with value of type %T.", name, val)
+		return error.New(error.Sanity, desc)
+	}
+
+	return nil
+}
+
+// UserFile returns the path of the user config file read by Load.
+func (src Sources) UserFile() string {
+	path, _ := layerPath(src.UserPath, UserLayer, src.Env)
+	return path
+}
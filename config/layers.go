@@ -0,0 +1,353 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// Layer identifies one of the sources which configuration variables are read
+// from. Values from later layers override those from earlier ones.
+type Layer uint8
+
+const (
+	DefaultLayer Layer = iota
+	SystemLayer
+	UserLayer
+	EnvironmentLayer
+	FlagLayer
+	layerLimit
+)
+
+var layerNames = [layerLimit]string{
+	"default", "system file", "user file", "environment", "flag",
+}
+
+// String returns a short, human-readable name for the layer.
+func (layer Layer) String() string {
+	if layer >= layerLimit {
+		return fmt.Sprintf("Layer(%d)", layer)
+	}
+	return layerNames[layer]
+}
+
+// Origin records where the final value of a variable came from. Location
+// describes the specific place within the layer, like a line of a file or
+// the name of an environment variable.
+type Origin struct {
+	Layer    Layer
+	Location string
+}
+
+// Origins maps the dotted name of every variable to its Origin.
+type Origins map[string]Origin
+
+// EnvPrefix begins the name of every environment variable read by Load.
+const EnvPrefix = "ROGUE_"
+
+// Sources lists the places Load reads configuration variables from.
+//
+// SystemPath and UserPath are the paths of the system-wide and per-user
+// config files. If either is empty, the corresponding file in the XDG
+// config directories is used instead, and is skipped if it does not exist.
+// Explicitly given files must exist.
+//
+// Env is a list of "KEY=value" strings, in the format of os.Environ. Only
+// variables beginning with EnvPrefix are assignments: ROGUE_<SECTION>_<FIELD>
+// sets Section.Field, and ROGUE_<FIELD> sets a top-level variable. Case is
+// ignored.
+//
+// Overrides is a list of assignments of the form "field = value", as given
+// to the -set flag. Fields in a section may be named either by their dotted
+// name or, if unambiguous, by their field name alone.
+type Sources struct {
+	SystemPath, UserPath string
+	Env                  []string
+	Overrides            []string
+}
+
+// DefaultSources returns the Sources for the current process, using the XDG
+// config files and the process's environment.
+func DefaultSources() Sources {
+	return Sources{Env: os.Environ()}
+}
+
+// SystemConfigPath returns the path of the system-wide config file given
+// the environment env: the rogue/config.txt file in the first directory of
+// $XDG_CONFIG_DIRS, or in /etc/xdg if it is not set.
+func SystemConfigPath(env []string) string {
+	dirs := lookupEnv(env, "XDG_CONFIG_DIRS")
+	dir := strings.Split(dirs, string(filepath.ListSeparator))[0]
+	if dir == "" {
+		dir = "/etc/xdg"
+	}
+	return filepath.Join(dir, "rogue", "config.txt")
+}
+
+// UserConfigPath returns the path of the per-user config file given the
+// environment env: the rogue/config.txt file in $XDG_CONFIG_HOME, or in
+// $HOME/.config if it is not set. The empty string is returned if neither
+// is set.
+func UserConfigPath(env []string) string {
+	dir := lookupEnv(env, "XDG_CONFIG_HOME")
+	if dir == "" {
+		home := lookupEnv(env, "HOME")
+		if home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "rogue", "config.txt")
+}
+
+// lookupEnv returns the value of the variable key in env, or the empty
+// string if it is not set.
+func lookupEnv(env []string, key string) string {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			return kv[len(key)+1:]
+		}
+	}
+	return ""
+}
+
+// Load returns the Info instance given by applying each of the layers of
+// src on top of the default values, along with the Origin of every
+// variable's final value.
+//
+// As with Parse, problems within a layer do not stop loading, and every
+// problem in every layer is described by a single Configuration error.
+// Within a single layer the first assignment to a variable is used, but
+// later layers override earlier ones.
+//
+// Load can return Configuration, Library, MissingFile, and Sanity errors.
+// The Info instance and Origins are valid as long as a Sanity error is not
+// returned.
+func Load(src Sources) (*Info, Origins, *error.Error) {
+	info, origins, _, err := load(src)
+	return info, origins, err
+}
+
+// load does the work of Load. It also returns the path of every file which
+// was read, or which would have been read if it existed.
+func load(src Sources) (*Info, Origins, []string, *error.Error) {
+	info, err := Default()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	origins := Origins{}
+	for _, key := range varNames {
+		origins[key] = Origin{DefaultLayer, "built-in default"}
+	}
+
+	problems := []*error.Error{}
+	apply := func(assignments []Assignment, layer Layer) *error.Error {
+		applied, err := applyAssignments(info, assignments, layer, origins)
+		problems = append(problems, applied...)
+		return err
+	}
+
+	layerFiles := []struct {
+		path  string
+		layer Layer
+	}{
+		{src.SystemPath, SystemLayer}, {src.UserPath, UserLayer},
+	}
+	paths := []string{}
+	for _, file := range layerFiles {
+		path, required := layerPath(file.path, file.layer, src.Env)
+		if path == "" {
+			continue
+		}
+		paths = append(paths, path)
+
+		assignments, fileProblems, err := readLayerFile(path, required)
+		if err != nil {
+			return info, origins, paths, err
+		}
+		problems = append(problems, fileProblems...)
+		if err = apply(assignments, file.layer); err != nil {
+			return nil, nil, nil, err
+		}
+		paths = appendIncluded(paths, assignments)
+	}
+
+	assignments, envProblems := envAssignments(src.Env)
+	problems = append(problems, envProblems...)
+	if err = apply(assignments, EnvironmentLayer); err != nil {
+		return nil, nil, nil, err
+	}
+
+	assignments, flagProblems := overrideAssignments(src.Overrides)
+	problems = append(problems, flagProblems...)
+	if err = apply(assignments, FlagLayer); err != nil {
+		return nil, nil, nil, err
+	}
+
+	switch len(problems) {
+	case 0:
+		return info, origins, paths, nil
+	case 1:
+		return info, origins, paths, problems[0]
+	}
+	summary := fmt.Sprintf("Found %d configuration problems:", len(problems))
+	return info, origins, paths,
+		error.Join(error.Configuration, summary, problems)
+}
+
+// layerPath returns the path of the config file for the given layer. If
+// path is empty, the XDG path for the layer is used. required is false if
+// the file may be skipped when it does not exist.
+func layerPath(path string, layer Layer, env []string) (string, bool) {
+	if path != "" {
+		return path, true
+	} else if layer == SystemLayer {
+		return SystemConfigPath(env), false
+	}
+	return UserConfigPath(env), false
+}
+
+// appendIncluded appends the files which assignments were read from to
+// paths, if they are not already in it.
+func appendIncluded(paths []string, assignments []Assignment) []string {
+	for _, a := range assignments {
+		found := false
+		for _, path := range paths {
+			if path == a.File {
+				found = true
+				break
+			}
+		}
+		if !found {
+			paths = append(paths, a.File)
+		}
+	}
+	return paths
+}
+
+// readLayerFile reads the assignments in the config file at path. If the
+// file is not required, a missing file is treated as an empty one.
+func readLayerFile(
+	path string, required bool,
+) (assignments []Assignment, problems []*error.Error, err *error.Error) {
+
+	if !required {
+		if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+			return []Assignment{}, []*error.Error{}, nil
+		}
+	}
+
+	return readAssignments(path, []string{})
+}
+
+// envAssignments converts the variables in env which begin with EnvPrefix
+// to assignments. Variables which do not name a configuration variable are
+// returned as problems.
+func envAssignments(env []string) ([]Assignment, []*error.Error) {
+	names := make(map[string]string)
+	for _, key := range varNames {
+		names[envName(key)] = key
+	}
+
+	assignments := []Assignment{}
+	problems := []*error.Error{}
+
+	for _, kv := range env {
+		if !strings.HasPrefix(strings.ToUpper(kv), EnvPrefix) {
+			continue
+		}
+
+		eq := strings.Index(kv, "=")
+		if eq < 0 {
+			continue
+		}
+		envKey, value := kv[:eq], kv[eq+1:]
+
+		a := Assignment{
+			"environment variable " + envKey, -1, "", "", value,
+		}
+		key, ok := names[strings.ToUpper(envKey)]
+		if !ok {
+			known := make([]string, 0, len(names))
+			for _, key := range varNames {
+				known = append(known, envName(key))
+			}
+			desc := fmt.Sprintf("Unknown variable '%s'.", envKey)
+			problems = append(problems, AssignmentError(
+				a, desc+suggestion(strings.ToUpper(envKey), known),
+			))
+			continue
+		}
+
+		a.Field = key
+		assignments = append(assignments, a)
+	}
+
+	return assignments, problems
+}
+
+// envName returns the name of the environment variable which sets the
+// variable with the given dotted name.
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+// overrideAssignments converts the strings given to the -set flag into
+// assignments. Malformed overrides are returned as problems.
+func overrideAssignments(overrides []string) ([]Assignment, []*error.Error) {
+	assignments := []Assignment{}
+	problems := []*error.Error{}
+
+	for _, override := range overrides {
+		a := Assignment{fmt.Sprintf("-set '%s'", override), -1, "", "", ""}
+
+		field, value, desc := parseLine(override)
+		if desc == "" && field == "" {
+			desc = "Override is empty."
+		}
+		if desc != "" {
+			problems = append(problems, AssignmentError(a, desc))
+			continue
+		}
+
+		a.Field, a.Value = field, value
+		assignments = append(assignments, a)
+	}
+
+	return assignments, problems
+}
+
+// Report returns a human-readable listing of the value of every variable in
+// info and the layer it came from.
+//
+// Report returns the same Sanity errors as Default.
+func Report(info *Info, origins Origins) (string, *error.Error) {
+	if registryErr != nil {
+		return "", registryErr
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(info))
+	lines := make([]string, len(varNames))
+	for i, key := range varNames {
+		field := fieldByName(v, key)
+		if !field.IsValid() {
+			desc := fmt.Sprintf("Key '%s' in varInfos unmatched by field "+
+				"in Info struct.", key)
+			return "", error.New(error.Sanity, desc)
+		}
+
+		origin, ok := origins[key]
+		if !ok {
+			origin = Origin{DefaultLayer, "built-in default"}
+		}
+		lines[i] = fmt.Sprintf("%s = %s    # %s: %s", key,
+			formatValue(field.Interface()), origin.Layer, origin.Location)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
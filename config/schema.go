@@ -0,0 +1,293 @@
+package config
+
+import (
+	"fmt"
+	"image/color"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// Option describes a single configuration variable.
+type Option struct {
+	Name    string
+	Type    string
+	Default string
+	Help    string
+	Widget  Widget
+}
+
+// WidgetKind is the type of control used to edit a variable on the options
+// screen.
+type WidgetKind uint8
+
+const (
+	// TextWidget variables are edited as free text.
+	TextWidget WidgetKind = iota
+	// ToggleWidget variables are booleans.
+	ToggleWidget
+	// ChoiceWidget variables are one of the strings in Widget.Choices.
+	ChoiceWidget
+	// IntSliderWidget variables are integers in [Widget.Min, Widget.Max].
+	IntSliderWidget
+	// FloatSliderWidget variables are floats in [Widget.Min, Widget.Max].
+	FloatSliderWidget
+)
+
+// Widget describes how a variable is edited on the options screen. It is
+// inferred from the variable's convert tag.
+type Widget struct {
+	Kind     WidgetKind
+	Choices  []string
+	Min, Max float64
+}
+
+// Options returns a description of every configuration variable in the
+// order they are declared in Info.
+//
+// Options returns the same Sanity errors as Default.
+func Options() ([]Option, *error.Error) {
+	if registryErr != nil {
+		return nil, registryErr
+	}
+
+	opts := make([]Option, len(varNames))
+	for i, name := range varNames {
+		vInfo := varInfos[name]
+		opts[i] = Option{
+			name, vInfo.typ.String(), vInfo.defaultStr, vInfo.help, vInfo.widget,
+		}
+	}
+
+	return opts, nil
+}
+
+// HelpText returns a human-readable listing of every configuration variable,
+// its type, default value and description.
+//
+// HelpText returns the same Sanity errors as Default.
+func HelpText() (string, *error.Error) {
+	opts, err := Options()
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, 0, 3*len(opts))
+	for _, opt := range opts {
+		lines = append(lines,
+			fmt.Sprintf("%s (%s, default: %s)", opt.Name, opt.Type, opt.Default),
+			fmt.Sprintf("    %s", opt.Help),
+			"",
+		)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// buildRegistry reads the struct tags of every field in infoType, and of
+// every field in its sections, and returns the resulting registry.
+//
+// A Sanity error is returned if a field is unexported, lacks a help tag, has
+// an unparsable convert tag or a default which its converter rejects, or if
+// the converter does not produce values of the field's type. Sections must be
+// structs and cannot be nested.
+func buildRegistry(infoType reflect.Type) (*registry, *error.Error) {
+	reg := &registry{
+		[]string{}, make(map[string]varInfo), make(map[string]string),
+	}
+
+	for i := 0; i < infoType.NumField(); i++ {
+		field := infoType.Field(i)
+		section, ok := field.Tag.Lookup("section")
+		if !ok {
+			if err := reg.addField(field, ""); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if field.Type.Kind() != reflect.Struct || section == "" {
+			desc := fmt.Sprintf("Info field '%s' is a section, but is not "+
+				"a struct or has an empty name.", field.Name)
+			return nil, error.New(error.Sanity, desc)
+		} else if _, ok := reg.sections[strings.ToLower(section)]; ok {
+			desc := fmt.Sprintf("Section '%s' is declared twice.", section)
+			return nil, error.New(error.Sanity, desc)
+		}
+		reg.sections[strings.ToLower(section)] = field.Name
+
+		for j := 0; j < field.Type.NumField(); j++ {
+			sub := field.Type.Field(j)
+			if _, ok := sub.Tag.Lookup("section"); ok {
+				desc := fmt.Sprintf("Section '%s' contains nested section "+
+					"'%s'.", section, sub.Name)
+				return nil, error.New(error.Sanity, desc)
+			} else if err := reg.addField(sub, field.Name+"."); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return reg, nil
+}
+
+// addField adds the variable described by the tags of field to the
+// registry. prefix is prepended to the field's name.
+func (reg *registry) addField(field reflect.StructField, prefix string) *error.Error {
+	name := prefix + field.Name
+	if field.PkgPath != "" {
+		desc := fmt.Sprintf("Info field '%s' is unexported.", name)
+		return error.New(error.Sanity, desc)
+	}
+
+	help, ok := field.Tag.Lookup("help")
+	if !ok || help == "" {
+		desc := fmt.Sprintf("Info field '%s' has no help tag.", name)
+		return error.New(error.Sanity, desc)
+	}
+
+	convert, widget, desc := parseConvertSpec(
+		field.Tag.Get("convert"), field.Type,
+	)
+	if desc != "" {
+		desc = fmt.Sprintf("Info field '%s' has invalid convert tag: %s",
+			name, desc)
+		return error.New(error.Sanity, desc)
+	}
+
+	defaultStr := field.Tag.Get("default")
+	val, ok, desc := convert(defaultStr)
+	if !ok {
+		desc = fmt.Sprintf("Info field '%s' has invalid default '%s': %s",
+			name, defaultStr, desc)
+		return error.New(error.Sanity, desc)
+	}
+
+	vInfo := varInfo{val, convert, defaultStr, help, field.Type, widget}
+	if err := checkVarInfo(name, vInfo, field.Type); err != nil {
+		return err
+	}
+
+	reg.names = append(reg.names, name)
+	reg.infos[name] = vInfo
+	return nil
+}
+
+// parseConvertSpec returns the ConvertFunc described by the value of a
+// convert tag, along with the Widget used to edit it. Recognized
+// specifications are
+//
+//	string, int, int(low, high), bool, float, float(low, high),
+//	enum(option, ...), list, duration, and color
+//
+// An empty spec selects the converter matching fieldType. An empty string in
+// the desc return value indicates no errors.
+func parseConvertSpec(
+	spec string, fieldType reflect.Type,
+) (convert ConvertFunc, widget Widget, desc string) {
+
+	spec = strings.Trim(spec, " \t")
+	if spec == "" {
+		convert, desc = defaultConvert(fieldType)
+		if fieldType.Kind() == reflect.Bool {
+			widget.Kind = ToggleWidget
+		}
+		return convert, widget, desc
+	}
+
+	name, args := spec, []string{}
+	if open := strings.Index(spec, "("); open >= 0 {
+		if !strings.HasSuffix(spec, ")") {
+			return nil, widget, fmt.Sprintf("'%s' has unclosed parenthesis.", spec)
+		}
+		name = strings.Trim(spec[:open], " \t")
+		for _, arg := range strings.Split(spec[open+1:len(spec)-1], ",") {
+			args = append(args, strings.Trim(arg, " \t"))
+		}
+	}
+
+	switch name {
+	case "string":
+		return NoConvert, widget, checkArgs(name, args, 0)
+	case "bool":
+		return BoolConvert, Widget{Kind: ToggleWidget}, checkArgs(name, args, 0)
+	case "list":
+		return ListConvert, widget, checkArgs(name, args, 0)
+	case "duration":
+		return DurationConvert, widget, checkArgs(name, args, 0)
+	case "color":
+		return ColorConvert, widget, checkArgs(name, args, 0)
+	case "enum":
+		if len(args) == 0 {
+			return nil, widget, "enum requires at least one option."
+		}
+		return EnumConvert(args...), Widget{Kind: ChoiceWidget, Choices: args}, ""
+	case "int":
+		if len(args) == 0 {
+			return IntConvert, widget, ""
+		} else if desc = checkArgs(name, args, 2); desc != "" {
+			return nil, widget, desc
+		}
+		low, err1 := strconv.Atoi(args[0])
+		high, err2 := strconv.Atoi(args[1])
+		if err1 != nil || err2 != nil {
+			return nil, widget, fmt.Sprintf("'%s' has non-integer bounds.", spec)
+		}
+		widget = Widget{Kind: IntSliderWidget, Min: float64(low), Max: float64(high)}
+		return IntRangeConvert(low, high), widget, ""
+	case "float":
+		if len(args) == 0 {
+			return FloatConvert, widget, ""
+		} else if desc = checkArgs(name, args, 2); desc != "" {
+			return nil, widget, desc
+		}
+		low, err1 := strconv.ParseFloat(args[0], 64)
+		high, err2 := strconv.ParseFloat(args[1], 64)
+		if err1 != nil || err2 != nil {
+			return nil, widget, fmt.Sprintf("'%s' has non-numeric bounds.", spec)
+		}
+		widget = Widget{Kind: FloatSliderWidget, Min: low, Max: high}
+		return FloatRangeConvert(low, high), widget, ""
+	}
+
+	return nil, widget, fmt.Sprintf("Unknown converter '%s'.", name)
+}
+
+// checkArgs returns a description of the problem if args does not have
+// exactly n elements. An empty string indicates no errors.
+func checkArgs(name string, args []string, n int) string {
+	if len(args) != n {
+		return fmt.Sprintf("%s takes %d arguments, but was given %d.",
+			name, n, len(args))
+	}
+	return ""
+}
+
+// defaultConvert returns the ConvertFunc used for fields of type t which do
+// not have a convert tag.
+func defaultConvert(t reflect.Type) (convert ConvertFunc, desc string) {
+	switch t {
+	case reflect.TypeOf(time.Duration(0)):
+		return DurationConvert, ""
+	case reflect.TypeOf(color.RGBA{}):
+		return ColorConvert, ""
+	case reflect.TypeOf([]string{}):
+		return ListConvert, ""
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return NoConvert, ""
+	case reflect.Int:
+		return IntConvert, ""
+	case reflect.Bool:
+		return BoolConvert, ""
+	case reflect.Float64:
+		return FloatConvert, ""
+	}
+
+	return nil, fmt.Sprintf("There is no default converter for type %v.", t)
+}
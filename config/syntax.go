@@ -0,0 +1,325 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// Config files are made up of lines of the following forms:
+//
+//	# A comment, which is ignored along with blank lines.
+//	Field = value             # Trailing comments are also ignored.
+//	Field = "quoted value"    # Quotes allow '#' and Go-style escapes.
+//	[section]
+//	include other_file.txt
+//
+// Assignments following a section header belong to that section until the
+// next header. An include directive reads the named file, relative to the
+// directory of the including file, as if its contents were inserted in place
+// of the directive. Included files start outside of any section and do not
+// change the section of the including file.
+
+const maxIncludeDepth = 16
+
+// Assignment is a single "field = value" line from a configuration-style
+// file. File is the path of the file the assignment was read from, Line is
+// the zero-indexed line number it appeared on, and Section is the name of the
+// most recent section header, or the empty string if there was none.
+//
+// Assignments which do not come from files, like those made through
+// environment variables, have a negative Line and describe their origin in
+// File.
+type Assignment struct {
+	File         string
+	Line         int
+	Section      string
+	Field, Value string
+}
+
+// ReadAssignments returns every assignment in the file at filePath, and in
+// any files it includes, in the order they appear. It allows other packages
+// to load data files which share the syntax of config files.
+//
+// Malformed lines do not stop reading. If there are any, the valid
+// assignments are returned along with a Configuration error describing every
+// malformed line. ReadAssignments can also return Library and MissingFile
+// errors, in which case no assignments are returned.
+func ReadAssignments(filePath string) ([]Assignment, *error.Error) {
+	assignments, problems, err := readAssignments(filePath, []string{})
+	if err != nil {
+		return nil, err
+	}
+	return assignments, combineProblems(filePath, problems)
+}
+
+// readAssignments does the work of ReadAssignments. includers is the list of
+// absolute paths of the files which include filePath, and is used to detect
+// include cycles. Problems with individual lines are returned in problems,
+// while errors which prevent reading entirely are returned in err.
+func readAssignments(
+	filePath string, includers []string,
+) (assignments []Assignment, problems []*error.Error, err *error.Error) {
+
+	absPath, pathErr := filepath.Abs(filePath)
+	if pathErr != nil {
+		return nil, nil, error.Wrap(error.Library, pathErr, "Could not "+
+			"find absolute path of config file.").With("path", filePath)
+	}
+
+	lines, err := readFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	includers = append(includers, absPath)
+	assignments = []Assignment{}
+	problems = []*error.Error{}
+	section := ""
+
+	for i, line := range lines {
+		if name, ok, desc := parseSection(line); desc != "" {
+			problems = append(problems, propogateParseError(i, filePath, desc))
+			continue
+		} else if ok {
+			section = name
+			continue
+		}
+
+		if path, ok, desc := parseInclude(line); desc != "" {
+			problems = append(problems, propogateParseError(i, filePath, desc))
+			continue
+		} else if ok {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(filePath), path)
+			}
+			if desc := checkInclude(path, includers); desc != "" {
+				problems = append(
+					problems, propogateParseError(i, filePath, desc),
+				)
+				continue
+			}
+
+			included, subProblems, err := readAssignments(path, includers)
+			if err != nil && err.Code == error.MissingFile {
+				problems = append(
+					problems, propogateParseError(i, filePath, err.Description),
+				)
+				continue
+			} else if err != nil {
+				return nil, nil, err
+			}
+			assignments = append(assignments, included...)
+			problems = append(problems, subProblems...)
+			continue
+		}
+
+		field, value, desc := parseLine(line)
+		if desc != "" {
+			problems = append(problems, propogateParseError(i, filePath, desc))
+			continue
+		} else if field == "" {
+			continue
+		}
+
+		assignments = append(
+			assignments, Assignment{filePath, i, section, field, value},
+		)
+	}
+
+	return assignments, problems, nil
+}
+
+// combineProblems returns a single Configuration error describing every
+// problem found in the file at filePath, or nil if there were none.
+func combineProblems(filePath string, problems []*error.Error) *error.Error {
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return problems[0]
+	}
+
+	summary := fmt.Sprintf("Found %d problems in '%s':",
+		len(problems), filePath)
+	if absPath, pathErr := filepath.Abs(filePath); pathErr == nil {
+		summary = fmt.Sprintf("Found %d problems in '%s':",
+			len(problems), absPath)
+	}
+	return error.Join(error.Configuration, summary, problems)
+}
+
+// checkInclude returns a description of the problem if including path from
+// the last file in includers would create a cycle or nest too deeply. An
+// empty string indicates no errors.
+func checkInclude(path string, includers []string) string {
+	absPath, pathErr := filepath.Abs(path)
+	if pathErr != nil {
+		return pathErr.Error()
+	}
+
+	for _, includer := range includers {
+		if includer == absPath {
+			return fmt.Sprintf("File '%s' includes itself.", absPath)
+		}
+	}
+	if len(includers) >= maxIncludeDepth {
+		return fmt.Sprintf("Includes are nested more than %d deep.",
+			maxIncludeDepth)
+	}
+
+	return ""
+}
+
+// AssignmentError creates a Configuration error describing a problem with
+// an assignment read by ReadAssignments.
+func AssignmentError(a Assignment, desc string) *error.Error {
+	if a.Line < 0 {
+		fullDesc := fmt.Sprintf("%s In %s.", desc, a.File)
+		return error.New(error.Configuration, fullDesc)
+	}
+	return propogateParseError(a.Line, a.File, desc)
+}
+
+// Location returns a human-readable description of where the assignment
+// was made. Assignments which did not come from a file have a negative Line
+// and a File which describes their origin instead of a path.
+func (a Assignment) Location() string {
+	if a.Line < 0 {
+		return a.File
+	}
+
+	path := a.File
+	if absPath, pathErr := filepath.Abs(path); pathErr == nil {
+		path = absPath
+	}
+	return fmt.Sprintf("line %d of '%s'", a.Line+1, path)
+}
+
+// parseLine splits an assignment into its field and value, removing
+// comments and surrounding whitespace, and unquoting quoted values. Blank
+// lines and comments give an empty field.
+//
+// An empty string in the desc return value indicates no errors.
+func parseLine(line string) (field, value string, desc string) {
+	line = strings.Trim(line, " \t\r")
+
+	eq := strings.Index(line, "=")
+	hash := strings.Index(line, "#")
+	if hash >= 0 && (eq < 0 || hash < eq) {
+		line = strings.Trim(line[:hash], " \t")
+		eq = -1
+	}
+
+	if line == "" {
+		return "", "", ""
+	} else if eq < 0 {
+		return "", "", "No variable assignment in non-empty line."
+	}
+
+	field = strings.Trim(line[:eq], " \t")
+	if field == "" {
+		return "", "", "No variable name before '='."
+	}
+
+	value, desc = parseValue(strings.Trim(line[eq+1:], " \t"))
+	if desc != "" {
+		return "", "", desc
+	}
+
+	return field, value, ""
+}
+
+// parseValue removes trailing comments from an unquoted value, or unquotes a
+// quoted one. An empty string in the desc return value indicates no errors.
+func parseValue(raw string) (value, desc string) {
+	if !strings.HasPrefix(raw, "\"") {
+		if hash := strings.Index(raw, "#"); hash >= 0 {
+			raw = raw[:hash]
+		}
+		return strings.Trim(raw, " \t"), ""
+	}
+
+	end := quoteEnd(raw)
+	if end < 0 {
+		return "", "Quoted value is missing closing '\"'."
+	}
+
+	rest := strings.Trim(raw[end+1:], " \t")
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Sprintf("Unexpected text '%s' after quoted value.", rest)
+	}
+
+	value, err := strconv.Unquote(raw[:end+1])
+	if err != nil {
+		return "", fmt.Sprintf("Quoted value %s has invalid escape sequence.",
+			raw[:end+1])
+	}
+
+	return value, ""
+}
+
+// quoteEnd returns the index of the '"' which closes the quoted string at the
+// start of raw, or -1 if it is not closed.
+func quoteEnd(raw string) int {
+	for i := 1; i < len(raw); i++ {
+		if raw[i] == '\\' {
+			i++
+		} else if raw[i] == '"' {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseSection returns the name of the section if line is a section header.
+// An empty string in the desc return value indicates no errors.
+func parseSection(line string) (name string, ok bool, desc string) {
+	line = strings.Trim(line, " \t\r")
+	if !strings.HasPrefix(line, "[") {
+		return "", false, ""
+	}
+
+	if hash := strings.Index(line, "#"); hash >= 0 {
+		line = strings.Trim(line[:hash], " \t")
+	}
+
+	if !strings.HasSuffix(line, "]") {
+		return "", false, "Section header is missing closing ']'."
+	}
+
+	name = strings.Trim(line[1:len(line)-1], " \t")
+	if name == "" {
+		return "", false, "Section header has no name."
+	}
+
+	return name, true, ""
+}
+
+// parseInclude returns the path named by line if it is an include
+// directive. An empty string in the desc return value indicates no errors.
+func parseInclude(line string) (path string, ok bool, desc string) {
+	line = strings.Trim(line, " \t\r")
+	if !strings.HasPrefix(line, "include ") &&
+		!strings.HasPrefix(line, "include\t") {
+		return "", false, ""
+	}
+
+	// "include = x" is an assignment to a variable named include.
+	rest := strings.Trim(line[len("include"):], " \t")
+	if strings.HasPrefix(rest, "=") {
+		return "", false, ""
+	}
+
+	path, desc = parseValue(rest)
+	if desc != "" {
+		return "", false, desc
+	} else if path == "" {
+		return "", false, "Include directive has no file name."
+	}
+
+	return path, true, ""
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"time"
+
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// Watcher detects changes to the config files read by Load so that they can
+// be reloaded while the game is running. It works by polling: each call to
+// Check compares the modification times and sizes of the files with those
+// seen by the previous load.
+//
+// The watched files are the system and user config files, whether or not
+// they exist, and every included file which has contained an assignment.
+// Environment variables and overrides are read once, when the Watcher is
+// created, and are reapplied on every reload.
+type Watcher struct {
+	src    Sources
+	stamps map[string]fileStamp
+}
+
+// fileStamp is the state of a watched file at the time of the last load.
+type fileStamp struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher loads the configuration given by src and returns the result
+// along with a Watcher which reloads it when the files change. The returned
+// values are the same as those of Load.
+func NewWatcher(src Sources) (*Watcher, *Info, Origins, *error.Error) {
+	w := &Watcher{src, make(map[string]fileStamp)}
+	info, origins, err := w.load()
+	return w, info, origins, err
+}
+
+// Check reloads the configuration if any watched file has changed since the
+// last load. changed is false if nothing has changed, in which case the other
+// return values are nil.
+//
+// If the new configuration has any problems, info and origins are nil and err
+// describes them, so that the caller can keep using the old configuration.
+// Since a file which is still being written to will typically fail to parse,
+// problems are reported only once per change; the next successful save will
+// be picked up by a later call to Check.
+func (w *Watcher) Check() (
+	info *Info, origins Origins, changed bool, err *error.Error,
+) {
+	if !w.changed() {
+		return nil, nil, false, nil
+	}
+
+	info, origins, err = w.load()
+	if err != nil {
+		return nil, nil, true, err
+	}
+	return info, origins, true, nil
+}
+
+// load loads the configuration and records the state of every file read.
+func (w *Watcher) load() (*Info, Origins, *error.Error) {
+	info, origins, paths, err := load(w.src)
+
+	// Files which are no longer read stay watched. Otherwise, an included
+	// file whose only assignment was broken by an edit would be forgotten,
+	// and fixing it would not trigger a reload.
+	for path := range w.stamps {
+		w.stamps[path] = stamp(path)
+	}
+	for _, path := range paths {
+		w.stamps[path] = stamp(path)
+	}
+
+	return info, origins, err
+}
+
+// changed returns true if any watched file differs from its recorded state.
+func (w *Watcher) changed() bool {
+	for path, prev := range w.stamps {
+		if stamp(path) != prev {
+			return true
+		}
+	}
+	return false
+}
+
+// stamp returns the current state of the file at path.
+func stamp(path string) fileStamp {
+	fileInfo, statErr := os.Stat(path)
+	if statErr != nil {
+		return fileStamp{}
+	}
+	return fileStamp{true, fileInfo.ModTime(), fileInfo.Size()}
+}
@@ -0,0 +1,317 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/phil-mansfield/rogue/error"
+)
+
+const fileHeader = `# Configuration file for rogue.
+#
+# Each variable is preceded by a description of its purpose. Lines starting
+# with '#' are comments. Run rogue with -help-config for more details.
+`
+
+// Dump returns the contents of a config file which sets every variable to its
+// value in info. Each variable is preceded by a comment containing its help
+// text and default value. Parsing the result gives an Info identical to info.
+//
+// Dump returns the same Sanity errors as Default.
+func Dump(info *Info) (string, *error.Error) {
+	return format(info, func(string) bool { return true })
+}
+
+// Template returns the contents of a starter config file which lists every
+// variable with its help text and default value. The assignments are
+// commented out, so the file has no effect until the user edits it.
+//
+// Template returns the same Sanity errors as Default.
+func Template() (string, *error.Error) {
+	info, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return format(info, func(string) bool { return false })
+}
+
+// WriteFile writes Dump(info) to filePath, creating any missing directories.
+// The file is replaced atomically, so a failed write leaves the previous
+// contents intact.
+//
+// WriteFile can return Library and Sanity errors.
+func WriteFile(filePath string, info *Info) *error.Error {
+	text, err := Dump(info)
+	if err != nil {
+		return err
+	}
+	return writeText(filePath, text)
+}
+
+// EditUserFile sets the variables named by keys to their values in info in
+// the user config file at filePath, as when saving changes made on the
+// options screen. An existing assignment to one of the variables is
+// replaced in place, keeping any trailing comment. Other variables are added
+// to the end of their section. Every other line, including comments, include
+// directives and invalid lines, is left unchanged, and values set in
+// included files are not copied into the user file. If the file does not
+// exist, it is created from Template.
+//
+// A Value error is returned if a key is not the name of a variable.
+// EditUserFile can also return Library and Sanity errors.
+func EditUserFile(filePath string, info *Info, keys []string) *error.Error {
+	if registryErr != nil {
+		return registryErr
+	}
+
+	lines, err := readFile(filePath)
+	if err != nil && err.Code == error.MissingFile {
+		text, templateErr := Template()
+		if templateErr != nil {
+			return templateErr
+		}
+		lines = strings.Split(text, "\n")
+	} else if err != nil {
+		return err
+	}
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value, err := Value(info, key)
+		if err != nil {
+			return err
+		}
+		values[key] = quoteValue(value)
+	}
+
+	// As when reading, the first assignment to a variable is the one which
+	// counts, so it is the one which is replaced.
+	replaced := make(map[string]bool)
+	section := ""
+	for i, line := range lines {
+		if name, ok, _ := parseSection(line); ok {
+			section = name
+			continue
+		} else if _, ok, _ := parseInclude(line); ok {
+			continue
+		}
+
+		field, _, desc := parseLine(line)
+		if desc != "" || field == "" {
+			continue
+		}
+		key, desc := resolveKey(Assignment{Section: section, Field: field})
+		if value, ok := values[key]; ok && desc == "" && !replaced[key] {
+			lines[i] = replaceValue(line, value)
+			replaced[key] = true
+		}
+	}
+
+	for _, key := range keys {
+		if !replaced[key] {
+			lines = insertAssignment(lines, key, values[key])
+			replaced[key] = true
+		}
+	}
+
+	return writeText(filePath, strings.Join(lines, "\n"))
+}
+
+// replaceValue returns the assignment line with its value replaced by value,
+// keeping the text before the value and any trailing comment.
+func replaceValue(line, value string) string {
+	eq := strings.Index(line, "=")
+	rest := line[eq+1:]
+	start := len(rest) - len(strings.TrimLeft(rest, " \t"))
+	raw := rest[start:]
+
+	end := len(strings.TrimRight(raw, " \t\r"))
+	if strings.HasPrefix(raw, "\"") {
+		end = quoteEnd(raw) + 1
+	} else if hash := strings.Index(raw, "#"); hash >= 0 {
+		end = len(strings.TrimRight(raw[:hash], " \t"))
+	}
+
+	space := rest[:start]
+	if space == "" {
+		space = " "
+	}
+	return line[:eq+1] + space + value + raw[end:]
+}
+
+// insertAssignment adds an assignment of value to the variable key to
+// lines. Sectioned variables go at the end of the first block of their
+// section, or in a new section at the end of the file if there is none.
+// Top-level variables go before the first section header.
+func insertAssignment(lines []string, key, value string) []string {
+	header, name := "", key
+	if dot := strings.Index(key, "."); dot >= 0 {
+		for lower, fieldName := range sectionNames {
+			if fieldName == key[:dot] {
+				header = lower
+			}
+		}
+		name = key[dot+1:]
+	}
+	assignment := fmt.Sprintf("%s = %s", name, value)
+
+	// The block runs from the line after blockStart up to at.
+	blockStart, at := -1, -1
+	inBlock := header == ""
+	for i, line := range lines {
+		sectionName, ok, _ := parseSection(line)
+		if !ok {
+			continue
+		} else if inBlock {
+			at = i
+			break
+		} else if strings.EqualFold(sectionName, header) {
+			blockStart, inBlock = i, true
+		}
+	}
+
+	if !inBlock {
+		for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		return append(lines, "", fmt.Sprintf("[%s]", header), assignment, "")
+	} else if at < 0 {
+		at = len(lines)
+	}
+
+	// Blank lines which separate the block from the next one stay after it.
+	for at-1 > blockStart && strings.TrimSpace(lines[at-1]) == "" {
+		at--
+	}
+	lines = append(lines[:at], append([]string{assignment}, lines[at:]...)...)
+	return lines
+}
+
+// WriteTemplate writes Template() to filePath, creating any missing
+// directories. A Value error is returned if filePath already exists.
+//
+// WriteTemplate can also return Library and Sanity errors.
+func WriteTemplate(filePath string) *error.Error {
+	if _, statErr := os.Stat(filePath); statErr == nil {
+		desc := fmt.Sprintf("Config file '%s' already exists.", filePath)
+		return error.New(error.Value, desc)
+	}
+
+	text, err := Template()
+	if err != nil {
+		return err
+	}
+	return writeText(filePath, text)
+}
+
+// writeText replaces the contents of filePath with text by writing to a
+// temporary file in the same directory and renaming it.
+func writeText(filePath, text string) *error.Error {
+	dir := filepath.Dir(filePath)
+	if mkErr := os.MkdirAll(dir, 0755); mkErr != nil {
+		return error.Wrap(error.Library, mkErr, "Could not create config "+
+			"directory.").With("path", dir)
+	}
+
+	tmp, tmpErr := ioutil.TempFile(dir, ".config-")
+	if tmpErr != nil {
+		return error.Wrap(error.Library, tmpErr, "Could not create "+
+			"temporary config file.").With("path", dir)
+	}
+
+	_, writeErr := tmp.WriteString(text)
+	closeErr := tmp.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr == nil {
+		writeErr = os.Rename(tmp.Name(), filePath)
+	}
+	if writeErr != nil {
+		os.Remove(tmp.Name())
+		return error.Wrap(error.Library, writeErr, "Could not write config "+
+			"file.").With("path", filePath)
+	}
+
+	return nil
+}
+
+// format does the work of Dump and Template. Variables for
+// which active returns true are assigned their values in info. All others
+// are commented out and shown with their default values.
+func format(info *Info, active func(key string) bool) (string, *error.Error) {
+	if registryErr != nil {
+		return "", registryErr
+	}
+
+	// Top-level variables must come before the first section header.
+	topLevel, sectioned := []string{}, []string{}
+	for _, key := range varNames {
+		if strings.Contains(key, ".") {
+			sectioned = append(sectioned, key)
+		} else {
+			topLevel = append(topLevel, key)
+		}
+	}
+
+	headers := make(map[string]string)
+	for header, fieldName := range sectionNames {
+		headers[fieldName] = header
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(info))
+	lines := []string{fileHeader}
+	section := ""
+
+	for _, key := range append(topLevel, sectioned...) {
+		field := fieldByName(v, key)
+		if !field.IsValid() {
+			desc := fmt.Sprintf("Key '%s' in varInfos unmatched by field "+
+				"in Info struct.", key)
+			return "", error.New(error.Sanity, desc)
+		}
+
+		name := key
+		if dot := strings.Index(key, "."); dot >= 0 {
+			if key[:dot] != section {
+				section = key[:dot]
+				lines = append(lines, fmt.Sprintf("[%s]\n", headers[section]))
+			}
+			name = key[dot+1:]
+		}
+
+		vInfo := varInfos[key]
+		var assignment string
+		if active(key) {
+			assignment = fmt.Sprintf("%s = %s",
+				name, quoteValue(formatValue(field.Interface())))
+		} else {
+			assignment = fmt.Sprintf("# %s = %s",
+				name, quoteValue(formatValue(vInfo.defaultValue)))
+		}
+
+		lines = append(lines,
+			fmt.Sprintf("# %s", vInfo.help),
+			fmt.Sprintf("# Type: %s. Default: %s", vInfo.typ, vInfo.defaultStr),
+			assignment,
+			"",
+		)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// quoteValue quotes value if parseValue would not otherwise read it back
+// unchanged.
+func quoteValue(value string) string {
+	if value != strings.Trim(value, " \t") ||
+		strings.ContainsAny(value, "#\"\\\n\r") {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,169 @@
+package deque
+
+import (
+	"fmt"
+	"iter"
+)
+
+/* Implements a double-ended queue using a ring buffer. Values can be pushed
+and popped at either end in amortized O(1) time.
+
+A deque made with NewFixed never grows: once it is full, pushing to one end
+overwrites the value at the other. This makes it suitable for histories,
+such as recent messages or input, where only the newest values matter:
+
+	history := deque.NewFixed[string](100)
+	history.PushBack(msg) // Drops the oldest message once there are 100.
+
+Unlike the other containers, deques take no locks, and must not be used by
+more than one goroutine at a time. */
+
+// Of is a double-ended queue of values of type T.
+type Of[T any] struct {
+	// The values in the deque are buf[head], buf[head+1], ... wrapping
+	// around to the start of buf.
+	buf   []T
+	head  int
+	n     int
+	fixed bool
+}
+
+// NewOf creates an empty deque with room for capacity values before it
+// needs to grow.
+func NewOf[T any](capacity int) *Of[T] {
+	if capacity < 0 { panic("Deques need non-negative capacities.") }
+	return &Of[T]{buf: make([]T, capacity)}
+}
+
+// NewFixed creates an empty deque which holds at most capacity values. When
+// it is full, PushBack overwrites the front value and PushFront overwrites
+// the back value.
+func NewFixed[T any](capacity int) *Of[T] {
+	if capacity <= 0 { panic("Fixed deques need positive capacities.") }
+	return &Of[T]{buf: make([]T, capacity), fixed: true}
+}
+
+// Len returns the number of values in the deque.
+func (d *Of[T]) Len() int { return d.n }
+
+// Cap returns the number of values the deque can hold before it grows or,
+// for fixed deques, starts overwriting values.
+func (d *Of[T]) Cap() int { return len(d.buf) }
+
+// Full returns true if the deque is at its capacity.
+func (d *Of[T]) Full() bool { return d.n == len(d.buf) }
+
+// index returns the position in buf of the i-th value.
+func (d *Of[T]) index(i int) int {
+	i += d.head
+	if i >= len(d.buf) { i -= len(d.buf) }
+	return i
+}
+
+// grow doubles the size of the buffer, moving the values to its start.
+func (d *Of[T]) grow() {
+	buf := make([]T, max(2*len(d.buf), 8))
+	n := copy(buf, d.buf[d.head:])
+	copy(buf[n:], d.buf[:d.head])
+	d.buf, d.head = buf, 0
+}
+
+// PushBack adds v to the back of the deque.
+func (d *Of[T]) PushBack(v T) {
+	if d.Full() {
+		if d.fixed {
+			d.buf[d.head] = v
+			d.head = d.index(1)
+			return
+		}
+		d.grow()
+	}
+	d.buf[d.index(d.n)] = v
+	d.n++
+}
+
+// PushFront adds v to the front of the deque.
+func (d *Of[T]) PushFront(v T) {
+	if d.Full() && !d.fixed { d.grow() }
+
+	d.head--
+	if d.head < 0 { d.head += len(d.buf) }
+	d.buf[d.head] = v
+
+	// In a full fixed deque, the new head was the back value.
+	if d.n < len(d.buf) { d.n++ }
+}
+
+// PopFront removes and returns the value at the front of the deque. ok is
+// false if the deque is empty.
+func (d *Of[T]) PopFront() (v T, ok bool) {
+	if d.n == 0 { return v, false }
+
+	var zero T
+	v, d.buf[d.head] = d.buf[d.head], zero
+	d.head = d.index(1)
+	d.n--
+	return v, true
+}
+
+// PopBack removes and returns the value at the back of the deque. ok is
+// false if the deque is empty.
+func (d *Of[T]) PopBack() (v T, ok bool) {
+	if d.n == 0 { return v, false }
+
+	var zero T
+	i := d.index(d.n - 1)
+	v, d.buf[i] = d.buf[i], zero
+	d.n--
+	return v, true
+}
+
+// Front returns the value at the front of the deque without removing it. ok
+// is false if the deque is empty.
+func (d *Of[T]) Front() (v T, ok bool) {
+	if d.n == 0 { return v, false }
+	return d.buf[d.head], true
+}
+
+// Back returns the value at the back of the deque without removing it. ok
+// is false if the deque is empty.
+func (d *Of[T]) Back() (v T, ok bool) {
+	if d.n == 0 { return v, false }
+	return d.buf[d.index(d.n - 1)], true
+}
+
+// At returns the i-th value from the front of the deque. It panics if i is
+// out of range.
+func (d *Of[T]) At(i int) T {
+	if i < 0 || i >= d.n {
+		panic(fmt.Sprintf("Index %d out of range for deque of length %d.",
+			i, d.n))
+	}
+	return d.buf[d.index(i)]
+}
+
+// Clear removes every value from the deque, keeping its capacity.
+func (d *Of[T]) Clear() {
+	clear(d.buf)
+	d.head, d.n = 0, 0
+}
+
+// All returns an iterator over the positions and values in the deque from
+// front to back. The deque must not be modified during iteration.
+func (d *Of[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i := 0; i < d.n; i++ {
+			if !yield(i, d.buf[d.index(i)]) { return }
+		}
+	}
+}
+
+// Backward returns an iterator over the positions and values in the deque
+// from back to front. The deque must not be modified during iteration.
+func (d *Of[T]) Backward() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i := d.n - 1; i >= 0; i-- {
+			if !yield(i, d.buf[d.index(i)]) { return }
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package deque
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// contents returns the values in d from front to back.
+func contents[T any](d *Of[T]) []T {
+	var vs []T
+	for _, v := range d.All() {
+		vs = append(vs, v)
+	}
+	return vs
+}
+
+// TestOf compares a deque against a slice after a long series of random
+// operations at both ends.
+func TestOf(t *testing.T) {
+	d := NewOf[int](0)
+	if _, ok := d.PopFront(); ok {
+		t.Errorf("PopFront on an empty deque succeeded.")
+	} else if _, ok := d.Back(); ok {
+		t.Errorf("Back on an empty deque succeeded.")
+	}
+
+	var want []int
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		switch op := r.Intn(5); {
+		case op < 2 && i < 1500:
+			d.PushBack(i)
+			want = append(want, i)
+		case op < 4 && i < 1500:
+			d.PushFront(i)
+			want = append([]int{i}, want...)
+		case len(want) > 0 && r.Intn(2) == 0:
+			if v, ok := d.PopFront(); !ok || v != want[0] {
+				t.Fatalf("Op %d: PopFront() = %d, %v instead of %d.",
+					i, v, ok, want[0])
+			}
+			want = want[1:]
+		case len(want) > 0:
+			if v, ok := d.PopBack(); !ok || v != want[len(want)-1] {
+				t.Fatalf("Op %d: PopBack() = %d, %v instead of %d.",
+					i, v, ok, want[len(want)-1])
+			}
+			want = want[:len(want)-1]
+		}
+
+		if d.Len() != len(want) {
+			t.Fatalf("Op %d: Len() = %d instead of %d.", i, d.Len(), len(want))
+		}
+	}
+
+	if got := contents(d); !slices.Equal(got, want) {
+		t.Errorf("All() = %v instead of %v.", got, want)
+	}
+	if len(want) > 0 {
+		if v, _ := d.Front(); v != want[0] {
+			t.Errorf("Front() = %d instead of %d.", v, want[0])
+		}
+		if v, _ := d.Back(); v != want[len(want)-1] {
+			t.Errorf("Back() = %d instead of %d.", v, want[len(want)-1])
+		}
+		if v := d.At(len(want) / 2); v != want[len(want)/2] {
+			t.Errorf("At(%d) = %d instead of %d.",
+				len(want)/2, v, want[len(want)/2])
+		}
+	}
+
+	d.Clear()
+	if d.Len() != 0 || len(contents(d)) != 0 {
+		t.Errorf("Deque is not empty after Clear.")
+	}
+}
+
+func TestFixed(t *testing.T) {
+	d := NewFixed[int](3)
+
+	tests := []struct {
+		front bool
+		v     int
+		want  []int
+	}{
+		{false, 1, []int{1}},
+		{false, 2, []int{1, 2}},
+		{false, 3, []int{1, 2, 3}},
+		{false, 4, []int{2, 3, 4}},
+		{false, 5, []int{3, 4, 5}},
+		{true, 6, []int{6, 3, 4}},
+		{true, 7, []int{7, 6, 3}},
+		{false, 8, []int{6, 3, 8}},
+	}
+
+	for i, test := range tests {
+		if test.front {
+			d.PushFront(test.v)
+		} else {
+			d.PushBack(test.v)
+		}
+		if got := contents(d); !slices.Equal(got, test.want) {
+			t.Errorf("Test %d: deque holds %v instead of %v.",
+				i, got, test.want)
+		}
+	}
+
+	if d.Cap() != 3 || !d.Full() {
+		t.Errorf("Cap() = %d and Full() = %v.", d.Cap(), d.Full())
+	}
+
+	var backward []int
+	for i, v := range d.Backward() {
+		if d.At(i) != v {
+			t.Errorf("Backward gave %d at %d, but At(%d) = %d.", v, i, i, d.At(i))
+		}
+		backward = append(backward, v)
+	}
+	if !slices.Equal(backward, []int{8, 3, 6}) {
+		t.Errorf("Backward() = %v instead of [8 3 6].", backward)
+	}
+}
+
+func BenchmarkPushPop(b *testing.B) {
+	b.Run("Of", func(b *testing.B) {
+		d := NewOf[int](0)
+		for i := 0; i < b.N; i++ {
+			d.PushBack(i)
+			d.PushFront(i)
+			d.PopFront()
+		}
+	})
+
+	b.Run("Fixed", func(b *testing.B) {
+		d := NewFixed[int](64)
+		for i := 0; i < b.N; i++ {
+			d.PushBack(i)
+		}
+	})
+}
@@ -2,90 +2,248 @@ package pq
 
 import (
 	"container/heap"
+	"iter"
+	"slices"
 	"sync"
 )
 
-type Elem struct {
+/* Implements a min-priority queue using container/heap. NewOf creates a
+queue which is locked with a mutex, and NewUnsynchronized creates one which
+takes no locks, for use by a single goroutine. Unlike the stack and queue
+packages there is no lock-free variant: every operation on a heap touches its
+root, so a lock-free heap would be no faster than a locked one.
+
+PriorityQueue is the older interface{} API, which is kept as a thin wrapper
+around a locked Of[interface{}].
+
+Entries with equal priorities are popped in the order they were pushed, so
+that, for example, actors which act on the same tick take turns in a fixed
+order. */
+
+// Entry is a value stored in a priority queue along with its priority.
+// Priority must not be changed directly while the Entry is in a queue; use
+// Update instead.
+type Entry[T any] struct {
 	Priority int64
-	Value interface{} 
+	Value T
 
 	index int
+	seq uint64 // Push order, used to break ties between equal priorities.
+}
+
+// Of is a priority queue of values of type T which pops the value with the
+// lowest priority first.
+type Of[T any] struct {
+	ph priorityHeap[T]
+	nextSeq uint64
+	locked bool
+	m sync.Mutex
+}
+
+// NewOf creates an empty thread-safe priority queue with room for capacity
+// values before it needs to grow.
+func NewOf[T any](capacity int) *Of[T] {
+	return &Of[T]{ph: make(priorityHeap[T], 0, capacity), locked: true}
+}
+
+// NewUnsynchronized creates an empty priority queue which takes no locks,
+// with room for capacity values before it needs to grow. It must not be used
+// by more than one goroutine at a time.
+func NewUnsynchronized[T any](capacity int) *Of[T] {
+	return &Of[T]{ph: make(priorityHeap[T], 0, capacity)}
+}
+
+// lock locks the queue if it is synchronized.
+func (pq *Of[T]) lock() {
+	if pq.locked { pq.m.Lock() }
+}
+
+// unlock unlocks the queue if it is synchronized.
+func (pq *Of[T]) unlock() {
+	if pq.locked { pq.m.Unlock() }
+}
+
+// Len returns the number of values in the queue.
+func (pq *Of[T]) Len() int {
+	pq.lock()
+	defer pq.unlock()
+
+	return len(pq.ph)
+}
+
+// Push adds value to the queue with the given priority and returns its
+// Entry.
+func (pq *Of[T]) Push(priority int64, value T) *Entry[T] {
+	pq.lock()
+	defer pq.unlock()
+
+	elem := &Entry[T]{Priority: priority, Value: value, index: -1,
+		seq: pq.nextSeq}
+	pq.nextSeq++
+	heap.Push(&pq.ph, elem)
+	return elem
+}
+
+// Pop removes and returns the Entry with the lowest priority. ok is false if
+// the queue is empty.
+func (pq *Of[T]) Pop() (elem *Entry[T], ok bool) {
+	pq.lock()
+	defer pq.unlock()
+
+	if len(pq.ph) == 0 { return nil, false }
+	return heap.Pop(&pq.ph).(*Entry[T]), true
 }
 
+// Peek returns the Entry with the lowest priority without removing it. ok is
+// false if the queue is empty.
+func (pq *Of[T]) Peek() (elem *Entry[T], ok bool) {
+	pq.lock()
+	defer pq.unlock()
+
+	if len(pq.ph) == 0 { return nil, false }
+	return pq.ph[0], true
+}
+
+// Update changes the priority of elem, which must have been returned by Push
+// on this queue. elem is placed after any other entries with the same
+// priority, as if it had just been pushed. ok is false if elem is not in the
+// queue, in which case nothing is changed.
+func (pq *Of[T]) Update(elem *Entry[T], priority int64) (ok bool) {
+	pq.lock()
+	defer pq.unlock()
+
+	if !pq.contains(elem) { return false }
+	elem.Priority, elem.seq = priority, pq.nextSeq
+	pq.nextSeq++
+	heap.Fix(&pq.ph, elem.index)
+	return true
+}
+
+// Remove removes elem from the queue. ok is false if elem is not in the
+// queue.
+func (pq *Of[T]) Remove(elem *Entry[T]) (ok bool) {
+	pq.lock()
+	defer pq.unlock()
+
+	if !pq.contains(elem) { return false }
+	heap.Remove(&pq.ph, elem.index)
+	return true
+}
+
+// contains returns true if elem is currently in the queue.
+func (pq *Of[T]) contains(elem *Entry[T]) bool {
+	return elem != nil && elem.index >= 0 && elem.index < len(pq.ph) &&
+		pq.ph[elem.index] == elem
+}
+
+// Clear removes every value from the queue, keeping its capacity.
+func (pq *Of[T]) Clear() {
+	pq.lock()
+	defer pq.unlock()
+
+	for _, elem := range pq.ph { elem.index = -1 }
+	clear(pq.ph)
+	pq.ph = pq.ph[:0]
+}
+
+// All returns an iterator over the entries in the queue in order of
+// increasing priority, which is the order they would be popped in. It
+// iterates over a sorted copy taken when iteration starts, so the queue may
+// be modified inside the loop.
+func (pq *Of[T]) All() iter.Seq[*Entry[T]] {
+	return func(yield func(*Entry[T]) bool) {
+		pq.lock()
+		elems := slices.Clone([]*Entry[T](pq.ph))
+		pq.unlock()
+
+		slices.SortFunc(elems, compareEntries[T])
+		for _, elem := range elems {
+			if !yield(elem) { return }
+		}
+	}
+}
+
+// compareEntries orders entries by priority, and then by push order.
+func compareEntries[T any](a, b *Entry[T]) int {
+	switch {
+	case a.Priority < b.Priority: return -1
+	case a.Priority > b.Priority: return +1
+	case a.seq < b.seq: return -1
+	case a.seq > b.seq: return +1
+	}
+	return 0
+}
+
+// Elem is the Entry type used by PriorityQueue.
+type Elem = Entry[interface{}]
+
 type PriorityQueue interface {
 	Len() int
 
-	Push(priority int64, value interface{})
+	Push(priority int64, value interface{}) *Elem
 	Pop() (*Elem, bool) // Pops the LOWEST priority
+	Peek() (*Elem, bool)
+
+	Update(elem *Elem, priority int64) bool // false if elem isn't queued
+	Remove(elem *Elem) bool
 }
 
 type priorityQueue struct {
-	ph *priorityHeap
-	m sync.Mutex
+	pq *Of[interface{}]
 }
 
 var _ PriorityQueue = new(priorityQueue) // typechecking
 
-func (pq *priorityQueue) Len() int { 
-	pq.m.Lock()
-	defer pq.m.Unlock()
+func (pq *priorityQueue) Len() int { return pq.pq.Len() }
 
-	return len(*pq.ph) 
+func (pq *priorityQueue) Push(priority int64, value interface{}) *Elem {
+	return pq.pq.Push(priority, value)
 }
 
-func (pq *priorityQueue) Push(priority int64, value interface{}) {
-	pq.m.Lock()
-	defer pq.m.Unlock()
-
-	heap.Push(pq.ph, &Elem{priority, value, -1})
-}
+func (pq *priorityQueue) Pop() (*Elem, bool) { return pq.pq.Pop() }
 
-func (pq *priorityQueue) Pop() (*Elem, bool) {
-	pq.m.Lock()
-	defer pq.m.Unlock()
+func (pq *priorityQueue) Peek() (*Elem, bool) { return pq.pq.Peek() }
 
-	if pq.ph.Len() == 0 { return nil, false }
-	elem, ok := heap.Pop(pq.ph).(*Elem)
-	if !ok { panic("What are you doing?") }
-	return elem, true
+func (pq *priorityQueue) Update(elem *Elem, priority int64) bool {
+	return pq.pq.Update(elem, priority)
 }
 
+func (pq *priorityQueue) Remove(elem *Elem) bool { return pq.pq.Remove(elem) }
+
 func New() PriorityQueue {
-	pq := &priorityQueue{&priorityHeap{}, sync.Mutex{}}
-	heap.Init(pq.ph)
-	return pq
+	return &priorityQueue{NewOf[interface{}](0)}
 }
 
 // This implementation is almost entirely borrowed from Go's container.heap
 // documentation:
 
-type priorityHeap []*Elem
+type priorityHeap[T any] []*Entry[T]
 
-func (ph priorityHeap) Len() int { return len(ph) }
+func (ph priorityHeap[T]) Len() int { return len(ph) }
 
-func (ph priorityHeap) Less(i, j int) bool {
-	return ph[i].Priority < ph[j].Priority
+func (ph priorityHeap[T]) Less(i, j int) bool {
+	return compareEntries(ph[i], ph[j]) < 0
 }
 
-func (pq priorityHeap) Swap(i, j int) {
+func (pq priorityHeap[T]) Swap(i, j int) {
 	pq[i], pq[j] = pq[j], pq[i]
 	pq[i].index = i
 	pq[j].index = j
 }
 
-func (pq *priorityHeap) Push(x interface{}) {
+func (pq *priorityHeap[T]) Push(x interface{}) {
 	n := len(*pq)
-	elem := x.(*Elem)
+	elem := x.(*Entry[T])
 	elem.index = n
 	*pq = append(*pq, elem)
 }
 
-func (pq *priorityHeap) Pop() interface{} {
+func (pq *priorityHeap[T]) Pop() interface{} {
 	old := *pq
 	n := len(old)
 	elem := old[n-1]
 	elem.index = -1
+	old[n-1] = nil // Don't keep the entry alive.
 	*pq = old[0 : n-1]
 	return elem
 }
-
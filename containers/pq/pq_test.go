@@ -0,0 +1,284 @@
+package pq
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	pq := NewOf[string](4)
+	if _, ok := pq.Pop(); ok {
+		t.Errorf("Pop on an empty queue succeeded.")
+	} else if _, ok := pq.Peek(); ok {
+		t.Errorf("Peek on an empty queue succeeded.")
+	}
+
+	tests := []struct {
+		priority int64
+		value    string
+	}{
+		{5, "e"}, {1, "a"}, {3, "c"}, {-2, "z"}, {4, "d"},
+	}
+	for _, test := range tests {
+		pq.Push(test.priority, test.value)
+	}
+
+	if elem, ok := pq.Peek(); !ok || elem.Value != "z" {
+		t.Errorf("Peek() = %v, %v instead of 'z', true.", elem, ok)
+	}
+
+	var values []string
+	for elem := range pq.All() {
+		values = append(values, elem.Value)
+	}
+	if want := []string{"z", "a", "c", "d", "e"}; !slices.Equal(values, want) {
+		t.Errorf("All() = %v instead of %v.", values, want)
+	}
+
+	for i, want := range []string{"z", "a", "c", "d", "e"} {
+		if elem, ok := pq.Pop(); !ok || elem.Value != want {
+			t.Errorf("Pop %d: got %v, %v instead of '%s', true.",
+				i, elem, ok, want)
+		}
+	}
+	if pq.Len() != 0 {
+		t.Errorf("Len() = %d after emptying the queue.", pq.Len())
+	}
+
+	pq.Push(1, "a")
+	pq.Clear()
+	if _, ok := pq.Pop(); ok {
+		t.Errorf("Pop succeeded after Clear.")
+	}
+}
+
+func TestPriorityQueue(t *testing.T) {
+	pq := New()
+	priorities := rand.New(rand.NewSource(1)).Perm(50)
+	for _, p := range priorities {
+		pq.Push(int64(p), p)
+	}
+
+	for i := 0; i < len(priorities); i++ {
+		elem, ok := pq.Pop()
+		if !ok || elem.Priority != int64(i) || elem.Value != i {
+			t.Fatalf("Pop %d: got %v, %v.", i, elem, ok)
+		}
+	}
+	if _, ok := pq.Pop(); ok {
+		t.Errorf("Pop on an empty PriorityQueue succeeded.")
+	}
+}
+
+func TestStableOrder(t *testing.T) {
+	pq := NewOf[int](0)
+	for i := 0; i < 20; i++ {
+		pq.Push(int64(i%2), i)
+	}
+
+	var values []int
+	for elem := range pq.All() {
+		values = append(values, elem.Value)
+	}
+	for i := 0; i < 20; i++ {
+		elem, _ := pq.Pop()
+		want := 2*i
+		if i >= 10 {
+			want = 2*(i-10) + 1
+		}
+		if elem.Value != want || values[i] != want {
+			t.Errorf("Pop %d: got %d and All() gave %d instead of %d.",
+				i, elem.Value, values[i], want)
+		}
+	}
+}
+
+func TestUpdateRemove(t *testing.T) {
+	pq := NewOf[string](0)
+	a := pq.Push(1, "a")
+	b := pq.Push(2, "b")
+	c := pq.Push(3, "c")
+	d := pq.Push(4, "d")
+
+	if !pq.Update(d, 0) {
+		t.Errorf("Update(d, 0) failed.")
+	}
+	if !pq.Update(a, 2) {
+		t.Errorf("Update(a, 2) failed.")
+	}
+	if !pq.Remove(c) {
+		t.Errorf("Remove(c) failed.")
+	}
+	if pq.Remove(c) || pq.Update(c, 0) {
+		t.Errorf("Remove or Update succeeded on a removed entry.")
+	}
+
+	other := NewOf[string](0)
+	other.Push(1, "x")
+	if pq.Remove(other.Push(0, "y")) {
+		t.Errorf("Remove succeeded on an entry from another queue.")
+	}
+
+	// a was moved to priority 2 after b was pushed, so it comes after b.
+	for i, want := range []*Entry[string]{d, b, a} {
+		if elem, ok := pq.Pop(); !ok || elem != want {
+			t.Errorf("Pop %d: got %v, %v instead of %v.", i, elem, ok, want)
+		}
+	}
+	if pq.Update(a, 0) {
+		t.Errorf("Update succeeded on a popped entry.")
+	}
+}
+
+// legacyHeap is the heap used by PriorityQueue before it was made generic
+// and stable, kept as a baseline for the benchmarks.
+type legacyHeap []*Elem
+
+func (h legacyHeap) Len() int           { return len(h) }
+func (h legacyHeap) Less(i, j int) bool { return h[i].Priority < h[j].Priority }
+func (h legacyHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *legacyHeap) Push(x interface{}) { *h = append(*h, x.(*Elem)) }
+func (h *legacyHeap) Pop() interface{} {
+	old := *h
+	elem := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return elem
+}
+
+func BenchmarkPushPop(b *testing.B) {
+	for _, n := range []int{100, 10000} {
+		priorities := rand.New(rand.NewSource(1)).Perm(n)
+
+		b.Run(fmt.Sprintf("legacy/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				h := &legacyHeap{}
+				for _, p := range priorities {
+					heap.Push(h, &Elem{Priority: int64(p), Value: p})
+				}
+				for h.Len() > 0 {
+					heap.Pop(h)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("PriorityQueue/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				pq := New()
+				for _, p := range priorities {
+					pq.Push(int64(p), p)
+				}
+				for pq.Len() > 0 {
+					pq.Pop()
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("Of/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				pq := NewOf[int](n)
+				for _, p := range priorities {
+					pq.Push(int64(p), p)
+				}
+				for pq.Len() > 0 {
+					pq.Pop()
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("Unsynchronized/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				pq := NewUnsynchronized[int](n)
+				for _, p := range priorities {
+					pq.Push(int64(p), p)
+				}
+				for pq.Len() > 0 {
+					pq.Pop()
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDecreaseKey compares Update with the usual workaround for a heap
+// without decrease-key: pushing a duplicate entry and skipping stale entries
+// when they are popped.
+func BenchmarkDecreaseKey(b *testing.B) {
+	const n = 10000
+	priorities := rand.New(rand.NewSource(1)).Perm(n)
+
+	b.Run("duplicate", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pq := NewOf[int](2 * n)
+			for j, p := range priorities {
+				pq.Push(int64(p)+n, j)
+			}
+			for j, p := range priorities {
+				pq.Push(int64(p), j)
+			}
+			for pq.Len() > 0 {
+				pq.Pop()
+			}
+		}
+	})
+
+	b.Run("Update", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pq := NewOf[int](n)
+			elems := make([]*Entry[int], n)
+			for j, p := range priorities {
+				elems[j] = pq.Push(int64(p)+n, j)
+			}
+			for j, p := range priorities {
+				pq.Update(elems[j], int64(p))
+			}
+			for pq.Len() > 0 {
+				pq.Pop()
+			}
+		}
+	})
+}
+
+// TestConcurrentUse pushes and pops from many goroutines at once and checks
+// that every value is popped exactly once. It is most useful with -race.
+func TestConcurrentUse(t *testing.T) {
+	const workers, perWorker = 8, 1000
+
+	pq := NewOf[int](0)
+	seen := make([][]int, workers)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				v := w*perWorker + i
+				elem := pq.Push(int64(v%7), v)
+				if i%5 == 0 {
+					pq.Update(elem, -1)
+				}
+				if elem, ok := pq.Pop(); ok {
+					seen[w] = append(seen[w], elem.Value)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	all := slices.Concat(seen...)
+	for elem, ok := pq.Pop(); ok; elem, ok = pq.Pop() {
+		all = append(all, elem.Value)
+	}
+	slices.Sort(all)
+	if len(all) != workers*perWorker {
+		t.Fatalf("Popped %d values instead of %d.", len(all), workers*perWorker)
+	}
+	for i, v := range all {
+		if v != i {
+			t.Fatalf("Value %d was lost or popped twice.", i)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package queue
+
+import (
+	"iter"
+	"sync/atomic"
+)
+
+// Concurrent is a lock-free first-in, first-out queue of values of type T,
+// implemented as a Michael-Scott queue: a linked list with a dummy node at
+// its head, whose ends are advanced with compare-and-swap. Every Enq
+// allocates, so it is slower than an Of when used by a single goroutine, but
+// it does not serialize goroutines which use it at once.
+type Concurrent[T any] struct {
+	// head is the dummy node. The values in the queue start at head.next.
+	head, tail atomic.Pointer[node[T]]
+	n          atomic.Int64
+}
+
+// node is an element of a Concurrent queue. Its value is never modified
+// after it is enqueued.
+type node[T any] struct {
+	v    T
+	next atomic.Pointer[node[T]]
+}
+
+// NewConcurrent creates an empty lock-free queue.
+func NewConcurrent[T any]() *Concurrent[T] {
+	q := &Concurrent[T]{}
+	dummy := &node[T]{}
+	q.head.Store(dummy)
+	q.tail.Store(dummy)
+	return q
+}
+
+// Len returns the number of values in the queue. It may be out of date by
+// the time it returns if other goroutines are using the queue.
+func (q *Concurrent[T]) Len() int {
+	// Deq can decrement the count before the matching Enq increments it.
+	return int(max(q.n.Load(), 0))
+}
+
+// Enq adds v to the back of the queue.
+func (q *Concurrent[T]) Enq(v T) {
+	n := &node[T]{v: v}
+	for {
+		tail := q.tail.Load()
+		next := tail.next.Load()
+		if tail != q.tail.Load() { continue }
+
+		if next != nil {
+			// Another Enq linked a node but hasn't moved the tail yet.
+			q.tail.CompareAndSwap(tail, next)
+		} else if tail.next.CompareAndSwap(nil, n) {
+			q.tail.CompareAndSwap(tail, n)
+			break
+		}
+	}
+	q.n.Add(1)
+}
+
+// Deq removes and returns the value at the front of the queue. ok is false
+// if the queue is empty.
+func (q *Concurrent[T]) Deq() (v T, ok bool) {
+	for {
+		head, tail := q.head.Load(), q.tail.Load()
+		next := head.next.Load()
+		if head != q.head.Load() { continue }
+
+		if next == nil {
+			return v, false
+		} else if head == tail {
+			q.tail.CompareAndSwap(tail, next)
+		} else if v = next.v; q.head.CompareAndSwap(head, next) {
+			// next is the new dummy node, and keeps its value alive until
+			// the following Deq.
+			q.n.Add(-1)
+			return v, true
+		}
+	}
+}
+
+// Peek returns the value at the front of the queue without removing it. ok
+// is false if the queue is empty.
+func (q *Concurrent[T]) Peek() (v T, ok bool) {
+	next := q.head.Load().next.Load()
+	if next == nil { return v, false }
+	return next.v, true
+}
+
+// Clear removes every value from the queue.
+func (q *Concurrent[T]) Clear() {
+	for {
+		if _, ok := q.Deq(); !ok { return }
+	}
+}
+
+// All returns an iterator over the values in the queue from front to back.
+// Values enqueued after iteration starts may or may not be included, and
+// values dequeued during iteration are still included.
+func (q *Concurrent[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := q.head.Load().next.Load(); n != nil; n = n.next.Load() {
+			if !yield(n.v) { return }
+		}
+	}
+}
@@ -1,16 +1,139 @@
 package queue
 
 import (
+	"iter"
 	"sync"
-	"container/list"
 )
 
-/* Implements a thread-safe queue using container/list */
+/* Implements queues of three kinds, chosen at construction:
+
+	NewOf[T](capacity)             // Locked with a mutex.
+	NewUnsynchronized[T](capacity) // No locking, for use by one goroutine.
+	NewConcurrent[T]()             // Lock-free, for heavy concurrent use.
+
+Queue is the older interface{} API, which is kept as a thin wrapper around a
+locked Of[interface{}]. */
+
+// Of is a first-in, first-out queue of values of type T backed by a slice.
+type Of[T any] struct {
+	// The values in the queue are xs[head:]. The dequeued prefix is
+	// reclaimed once it makes up half of the slice.
+	xs     []T
+	head   int
+	locked bool
+	m      sync.Mutex
+}
+
+// NewOf creates an empty thread-safe queue with room for capacity values
+// before it needs to grow.
+func NewOf[T any](capacity int) *Of[T] {
+	return &Of[T]{xs: make([]T, 0, capacity), locked: true}
+}
+
+// NewUnsynchronized creates an empty queue which takes no locks, with room
+// for capacity values before it needs to grow. It must not be used by more
+// than one goroutine at a time.
+func NewUnsynchronized[T any](capacity int) *Of[T] {
+	return &Of[T]{xs: make([]T, 0, capacity)}
+}
+
+// lock locks the queue if it is synchronized.
+func (q *Of[T]) lock() {
+	if q.locked { q.m.Lock() }
+}
+
+// unlock unlocks the queue if it is synchronized.
+func (q *Of[T]) unlock() {
+	if q.locked { q.m.Unlock() }
+}
+
+// Len returns the number of values in the queue.
+func (q *Of[T]) Len() int {
+	q.lock()
+	defer q.unlock()
+
+	return len(q.xs) - q.head
+}
+
+// Enq adds v to the back of the queue.
+func (q *Of[T]) Enq(v T) {
+	q.lock()
+	defer q.unlock()
+
+	q.xs = append(q.xs, v)
+}
+
+// EnqSlice adds each value in vs to the back of the queue in order.
+func (q *Of[T]) EnqSlice(vs []T) {
+	q.lock()
+	defer q.unlock()
+
+	q.xs = append(q.xs, vs...)
+}
+
+// Deq removes and returns the value at the front of the queue. ok is false
+// if the queue is empty.
+func (q *Of[T]) Deq() (v T, ok bool) {
+	q.lock()
+	defer q.unlock()
+
+	if q.head == len(q.xs) { return v, false }
+
+	v = q.xs[q.head]
+	var zero T
+	q.xs[q.head] = zero // Don't keep the value alive.
+	q.head++
+
+	if q.head == len(q.xs) {
+		q.xs, q.head = q.xs[:0], 0
+	} else if 2*q.head >= len(q.xs) {
+		n := copy(q.xs, q.xs[q.head:])
+		clear(q.xs[n:])
+		q.xs, q.head = q.xs[:n], 0
+	}
+
+	return v, true
+}
+
+// Peek returns the value at the front of the queue without removing it. ok
+// is false if the queue is empty.
+func (q *Of[T]) Peek() (v T, ok bool) {
+	q.lock()
+	defer q.unlock()
+
+	if q.head == len(q.xs) { return v, false }
+	return q.xs[q.head], true
+}
+
+// Clear removes every value from the queue, keeping its capacity.
+func (q *Of[T]) Clear() {
+	q.lock()
+	defer q.unlock()
+
+	clear(q.xs)
+	q.xs, q.head = q.xs[:0], 0
+}
+
+// All returns an iterator over the values in the queue from front to back,
+// which is the order they would be dequeued in. It iterates over a copy
+// taken when iteration starts, so the queue may be modified inside the loop.
+func (q *Of[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		q.lock()
+		xs := make([]T, len(q.xs) - q.head)
+		copy(xs, q.xs[q.head:])
+		q.unlock()
+
+		for _, v := range xs {
+			if !yield(v) { return }
+		}
+	}
+}
 
 type Queue interface {
 	Len() int
 
-	Enq(interface{}) 
+	Enq(interface{})
 	Deq() (interface{}, bool) // second argument is true if queue is empty
 
 	AddSlice([]interface{})
@@ -19,42 +142,20 @@ type Queue interface {
 var _ Queue = &queue{} // typechecking
 
 type queue struct {
-	xs *list.List
-	m *sync.Mutex
+	q *Of[interface{}]
 }
 
 func New() Queue {
-	return &queue{xs: list.New(), m: new(sync.Mutex)}
-}
-
-func (q *queue)Len() int {
-	q.m.Lock()
-	defer q.m.Unlock()
-
-	return q.xs.Len()
+	return &queue{NewOf[interface{}](0)}
 }
 
-func (q *queue)Enq(v interface{}) {
-	q.m.Lock()
-	defer q.m.Unlock()
+func (q *queue)Len() int { return q.q.Len() }
 
-	q.xs.PushFront(v)
-}
+func (q *queue)Enq(v interface{}) { q.q.Enq(v) }
 
 func (q *queue) Deq() (interface{}, bool) {
-	q.m.Lock()
-	defer q.m.Unlock()
-
-	if q.xs.Len() == 0 { return nil, true }
-
-	elem := q.xs.Back()
-	q.xs.Remove(elem)
-	return elem.Value, false
+	v, ok := q.q.Deq()
+	return v, !ok
 }
 
-func (q *queue)AddSlice(vs []interface{}) {
-	q.m.Lock()
-	defer q.m.Unlock()
-
-	for _, v := range vs { q.xs.PushFront(v) }
-}
\ No newline at end of file
+func (q *queue)AddSlice(vs []interface{}) { q.q.EnqSlice(vs) }
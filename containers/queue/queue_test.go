@@ -0,0 +1,221 @@
+package queue
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	q := NewOf[int](2)
+	if _, ok := q.Deq(); ok {
+		t.Errorf("Deq on an empty queue succeeded.")
+	} else if _, ok := q.Peek(); ok {
+		t.Errorf("Peek on an empty queue succeeded.")
+	}
+
+	// Interleave operations so that the dequeued prefix is reclaimed.
+	var want []int
+	next := 0
+	for i := 0; i < 100; i++ {
+		q.Enq(i)
+		want = append(want, i)
+		if i%3 == 2 {
+			v, ok := q.Deq()
+			if !ok || v != next {
+				t.Fatalf("Deq() = %d, %v instead of %d, true.", v, ok, next)
+			}
+			want = want[1:]
+			next++
+		}
+	}
+
+	if q.Len() != len(want) {
+		t.Errorf("Len() = %d instead of %d.", q.Len(), len(want))
+	}
+	if v, ok := q.Peek(); !ok || v != want[0] {
+		t.Errorf("Peek() = %d, %v instead of %d, true.", v, ok, want[0])
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, want) {
+		t.Errorf("All() = %v instead of %v.", got, want)
+	}
+
+	q.EnqSlice([]int{100, 101})
+	want = append(want, 100, 101)
+	for _, w := range want {
+		if v, ok := q.Deq(); !ok || v != w {
+			t.Fatalf("Deq() = %d, %v instead of %d, true.", v, ok, w)
+		}
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d after emptying the queue.", q.Len())
+	}
+
+	q.EnqSlice([]int{1, 2})
+	q.Clear()
+	if _, ok := q.Deq(); ok {
+		t.Errorf("Deq succeeded after Clear.")
+	}
+}
+
+func TestQueue(t *testing.T) {
+	q := New()
+	q.Enq("a")
+	q.AddSlice([]interface{}{"b", "c"})
+	if q.Len() != 3 {
+		t.Errorf("Len() = %d instead of 3.", q.Len())
+	}
+
+	for i, want := range []string{"a", "b", "c"} {
+		if v, empty := q.Deq(); empty || v != want {
+			t.Errorf("Deq %d: got %v, %v instead of %s, false.",
+				i, v, empty, want)
+		}
+	}
+	if _, empty := q.Deq(); !empty {
+		t.Errorf("Deq on an empty Queue did not report it was empty.")
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	q := NewConcurrent[int]()
+	if _, ok := q.Deq(); ok {
+		t.Errorf("Deq on an empty queue succeeded.")
+	} else if _, ok := q.Peek(); ok {
+		t.Errorf("Peek on an empty queue succeeded.")
+	}
+
+	for i := 1; i <= 4; i++ {
+		q.Enq(i)
+	}
+	if v, ok := q.Peek(); !ok || v != 1 {
+		t.Errorf("Peek() = %d, %v instead of 1, true.", v, ok)
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("All() = %v instead of [1 2 3 4].", got)
+	}
+	for i := 1; i <= 2; i++ {
+		if v, ok := q.Deq(); !ok || v != i {
+			t.Errorf("Deq() = %d, %v instead of %d, true.", v, ok, i)
+		}
+	}
+	if q.Len() != 2 {
+		t.Errorf("Len() = %d instead of 2.", q.Len())
+	}
+
+	q.Clear()
+	if _, ok := q.Deq(); ok || q.Len() != 0 {
+		t.Errorf("Deq succeeded or Len() = %d after Clear.", q.Len())
+	}
+}
+
+// concurrentQueue is the part of the queue API shared by every variant.
+type concurrentQueue interface {
+	Enq(int)
+	Deq() (int, bool)
+	Len() int
+}
+
+// TestConcurrentUse enqueues and dequeues from many goroutines at once and
+// checks that every value is dequeued exactly once, and that values from
+// each goroutine come out in the order they went in. It is most useful with
+// -race.
+func TestConcurrentUse(t *testing.T) {
+	const workers, perWorker = 8, 1000
+
+	tests := []struct {
+		name string
+		q    concurrentQueue
+	}{
+		{"Of", NewOf[int](0)},
+		{"Concurrent", NewConcurrent[int]()},
+	}
+
+	for _, test := range tests {
+		seen := make([][]int, workers)
+		wg := sync.WaitGroup{}
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					test.q.Enq(w*perWorker + i)
+					if v, ok := test.q.Deq(); ok {
+						seen[w] = append(seen[w], v)
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		var rest []int
+		for v, ok := test.q.Deq(); ok; v, ok = test.q.Deq() {
+			rest = append(rest, v)
+		}
+
+		last := make([]int, workers)
+		for w := range last {
+			last[w] = -1
+		}
+		for _, vs := range append(seen, rest) {
+			for _, v := range vs {
+				w := v / perWorker
+				if v <= last[w] {
+					t.Errorf("%s: %d was dequeued after %d.",
+						test.name, v, last[w])
+				}
+				last[w] = max(last[w], v)
+			}
+		}
+
+		all := slices.Concat(append(seen, rest)...)
+		slices.Sort(all)
+		if len(all) != workers*perWorker || test.q.Len() != 0 {
+			t.Errorf("%s: dequeued %d values instead of %d, with Len() = %d.",
+				test.name, len(all), workers*perWorker, test.q.Len())
+			continue
+		}
+		for i, v := range all {
+			if v != i {
+				t.Errorf("%s: value %d was lost or dequeued twice.",
+					test.name, i)
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkEnqDeq(b *testing.B) {
+	variants := []struct {
+		name string
+		new  func() concurrentQueue
+	}{
+		{"Unsynchronized", func() concurrentQueue { return NewUnsynchronized[int](0) }},
+		{"Of", func() concurrentQueue { return NewOf[int](0) }},
+		{"Concurrent", func() concurrentQueue { return NewConcurrent[int]() }},
+	}
+
+	for _, variant := range variants {
+		b.Run(variant.name, func(b *testing.B) {
+			q := variant.new()
+			for i := 0; i < b.N; i++ {
+				q.Enq(i)
+				q.Enq(i)
+				q.Deq()
+			}
+		})
+	}
+
+	// Unsynchronized queues can't be shared between goroutines.
+	for _, variant := range variants[1:] {
+		b.Run(variant.name+"/parallel", func(b *testing.B) {
+			q := variant.new()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					q.Enq(i)
+					q.Deq()
+				}
+			})
+		})
+	}
+}
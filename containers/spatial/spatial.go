@@ -0,0 +1,210 @@
+package spatial
+
+import (
+	"iter"
+)
+
+/* Implements a spatial index which answers "what is at (x, y)" and "what is
+near (x, y)" for values placed at integer coordinates, such as the actors and
+items on a map.
+
+Grid is a uniform grid hash: the plane is divided into square cells, and each
+value is stored in the cell containing it. A query only looks at the cells
+which overlap the queried region, so its cost depends on how many values are
+nearby rather than on how many values there are in total. The cell size
+should be close to the radius of typical queries.
+
+Grids take no locks, and must not be used by more than one goroutine at a
+time. The query iterators must not be used while the grid is being
+modified. */
+
+// Grid is a uniform grid hash of values of type T. Each value may be in the
+// grid at most once.
+type Grid[T comparable] struct {
+	cellSize int
+	cells    map[cell][]entry[T]
+	points   map[T]point
+}
+
+// cell is the coordinates of a grid cell.
+type cell struct{ cx, cy int }
+
+// point is a position on the map.
+type point struct{ x, y int }
+
+// entry is a value stored in a cell.
+type entry[T comparable] struct {
+	v T
+	p point
+}
+
+// NewGrid creates an empty Grid with square cells which are cellSize wide.
+func NewGrid[T comparable](cellSize int) *Grid[T] {
+	if cellSize <= 0 { panic("Grids need positive cell sizes.") }
+	return &Grid[T]{
+		cellSize: cellSize,
+		cells:    make(map[cell][]entry[T]),
+		points:   make(map[T]point),
+	}
+}
+
+// Len returns the number of values in the grid.
+func (g *Grid[T]) Len() int { return len(g.points) }
+
+// cellOf returns the cell containing (x, y). Division rounds towards
+// negative infinity so that negative coordinates work.
+func (g *Grid[T]) cellOf(x, y int) cell {
+	return cell{floorDiv(x, g.cellSize), floorDiv(y, g.cellSize)}
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a % b != 0 && a < 0 { q-- }
+	return q
+}
+
+// Insert adds v to the grid at (x, y). ok is false if v is already in the
+// grid, in which case nothing is changed.
+func (g *Grid[T]) Insert(v T, x, y int) (ok bool) {
+	if _, found := g.points[v]; found { return false }
+
+	p := point{x, y}
+	g.points[v] = p
+	c := g.cellOf(x, y)
+	g.cells[c] = append(g.cells[c], entry[T]{v, p})
+	return true
+}
+
+// Remove removes v from the grid. ok is false if v is not in the grid.
+func (g *Grid[T]) Remove(v T) (ok bool) {
+	p, found := g.points[v]
+	if !found { return false }
+
+	delete(g.points, v)
+	g.removeFromCell(g.cellOf(p.x, p.y), v)
+	return true
+}
+
+// removeFromCell removes v from the cell c.
+func (g *Grid[T]) removeFromCell(c cell, v T) {
+	entries := g.cells[c]
+	for i := range entries {
+		if entries[i].v != v { continue }
+
+		last := len(entries) - 1
+		entries[i] = entries[last]
+		entries[last] = entry[T]{}
+		if last == 0 {
+			delete(g.cells, c)
+		} else {
+			g.cells[c] = entries[:last]
+		}
+		return
+	}
+	panic("Grid value was not in the cell containing its position.")
+}
+
+// Move moves v to (x, y). ok is false if v is not in the grid.
+func (g *Grid[T]) Move(v T, x, y int) (ok bool) {
+	old, found := g.points[v]
+	if !found { return false }
+
+	p := point{x, y}
+	g.points[v] = p
+
+	oldCell, newCell := g.cellOf(old.x, old.y), g.cellOf(x, y)
+	if oldCell == newCell {
+		entries := g.cells[oldCell]
+		for i := range entries {
+			if entries[i].v == v {
+				entries[i].p = p
+				break
+			}
+		}
+		return true
+	}
+
+	g.removeFromCell(oldCell, v)
+	g.cells[newCell] = append(g.cells[newCell], entry[T]{v, p})
+	return true
+}
+
+// Position returns the position of v. ok is false if v is not in the grid.
+func (g *Grid[T]) Position(v T) (x, y int, ok bool) {
+	p, ok := g.points[v]
+	return p.x, p.y, ok
+}
+
+// At returns an iterator over the values at (x, y), in no particular order.
+func (g *Grid[T]) At(x, y int) iter.Seq[T] {
+	return g.InRect(x, y, x, y)
+}
+
+// InRect returns an iterator over the values in the rectangle with corners
+// (x0, y0) and (x1, y1), inclusive, in no particular order.
+func (g *Grid[T]) InRect(x0, y0, x1, y1 int) iter.Seq[T] {
+	x0, x1 = min(x0, x1), max(x0, x1)
+	y0, y1 = min(y0, y1), max(y0, y1)
+	return g.query(x0, y0, x1, y1, func(p point) bool {
+		return p.x >= x0 && p.x <= x1 && p.y >= y0 && p.y <= y1
+	})
+}
+
+// InRadius returns an iterator over the values within a Euclidean distance
+// of r from (x, y), inclusive, in no particular order.
+func (g *Grid[T]) InRadius(x, y, r int) iter.Seq[T] {
+	r2 := r*r
+	return g.query(x - r, y - r, x + r, y + r, func(p point) bool {
+		dx, dy := p.x - x, p.y - y
+		return dx*dx + dy*dy <= r2
+	})
+}
+
+// query returns an iterator over the values in the cells overlapping the
+// rectangle (x0, y0)-(x1, y1) whose positions satisfy inside.
+func (g *Grid[T]) query(
+	x0, y0, x1, y1 int, inside func(point) bool,
+) iter.Seq[T] {
+
+	return func(yield func(T) bool) {
+		if x0 > x1 || y0 > y1 { return }
+		c0, c1 := g.cellOf(x0, y0), g.cellOf(x1, y1)
+
+		// Looking up every cell in a huge region would be slower than
+		// checking every occupied cell. The spans are compared one at a
+		// time before multiplying them, since a huge region would overflow
+		// the product. Unsigned differences cannot overflow themselves.
+		n := uint64(len(g.cells))
+		w, h := uint64(c1.cx) - uint64(c0.cx), uint64(c1.cy) - uint64(c0.cy)
+		if w >= n || h >= n || (w + 1)*(h + 1) > n {
+			for c, entries := range g.cells {
+				if c.cx < c0.cx || c.cx > c1.cx ||
+					c.cy < c0.cy || c.cy > c1.cy { continue }
+				if !yieldInside(entries, inside, yield) { return }
+			}
+			return
+		}
+
+		// Counting offsets rather than coordinates keeps the loops from
+		// wrapping around at the largest int.
+		for i := uint64(0); i <= w; i++ {
+			for j := uint64(0); j <= h; j++ {
+				c := cell{c0.cx + int(i), c0.cy + int(j)}
+				entries := g.cells[c]
+				if !yieldInside(entries, inside, yield) { return }
+			}
+		}
+	}
+}
+
+// yieldInside yields the values of entries whose positions satisfy inside.
+// It returns false if yield asked to stop.
+func yieldInside[T comparable](
+	entries []entry[T], inside func(point) bool, yield func(T) bool,
+) bool {
+
+	for _, e := range entries {
+		if inside(e.p) && !yield(e.v) { return false }
+	}
+	return true
+}
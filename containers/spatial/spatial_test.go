@@ -0,0 +1,202 @@
+package spatial
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// bruteForce returns the ids in points which satisfy inside, sorted.
+func bruteForce(points map[int]point, inside func(p point) bool) []int {
+	var ids []int
+	for id, p := range points {
+		if inside(p) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// sorted collects and sorts the values yielded by seq.
+func sorted(seq func(func(int) bool)) []int {
+	var ids []int
+	seq(func(id int) bool {
+		ids = append(ids, id)
+		return true
+	})
+	slices.Sort(ids)
+	return ids
+}
+
+// TestGrid compares grid queries against brute force searches after random
+// inserts, moves and removals, including at negative coordinates.
+func TestGrid(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	g := NewGrid[int](4)
+	points := make(map[int]point)
+	coord := func() int { return r.Intn(60) - 30 }
+
+	for id := 0; id < 300; id++ {
+		x, y := coord(), coord()
+		if !g.Insert(id, x, y) {
+			t.Fatalf("Insert(%d) failed.", id)
+		}
+		points[id] = point{x, y}
+	}
+	if g.Insert(0, 0, 0) {
+		t.Errorf("Inserting a value twice succeeded.")
+	}
+
+	for i := 0; i < 500; i++ {
+		id := r.Intn(300)
+		_, present := points[id]
+		switch r.Intn(4) {
+		case 0:
+			if g.Remove(id) != present {
+				t.Fatalf("Remove(%d) returned %v.", id, !present)
+			}
+			delete(points, id)
+		case 1:
+			// Small moves usually stay in the same cell.
+			p := points[id]
+			p.x, p.y = p.x+r.Intn(3)-1, p.y+r.Intn(3)-1
+			if g.Move(id, p.x, p.y) != present {
+				t.Fatalf("Move(%d) returned %v.", id, !present)
+			} else if present {
+				points[id] = p
+			}
+		default:
+			x, y := coord(), coord()
+			if g.Move(id, x, y) != present {
+				t.Fatalf("Move(%d) returned %v.", id, !present)
+			} else if present {
+				points[id] = point{x, y}
+			}
+		}
+	}
+
+	if g.Len() != len(points) {
+		t.Errorf("Len() = %d instead of %d.", g.Len(), len(points))
+	}
+	for id, p := range points {
+		if x, y, ok := g.Position(id); !ok || x != p.x || y != p.y {
+			t.Errorf("Position(%d) = %d, %d, %v instead of %d, %d.",
+				id, x, y, ok, p.x, p.y)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		x, y, rad := coord(), coord(), r.Intn(12)
+
+		want := bruteForce(points, func(p point) bool {
+			return (p.x-x)*(p.x-x)+(p.y-y)*(p.y-y) <= rad*rad
+		})
+		if got := sorted(g.InRadius(x, y, rad)); !slices.Equal(got, want) {
+			t.Errorf("InRadius(%d, %d, %d) = %v instead of %v.",
+				x, y, rad, got, want)
+		}
+
+		x1, y1 := coord(), coord()
+		want = bruteForce(points, func(p point) bool {
+			return p.x >= min(x, x1) && p.x <= max(x, x1) &&
+				p.y >= min(y, y1) && p.y <= max(y, y1)
+		})
+		if got := sorted(g.InRect(x, y, x1, y1)); !slices.Equal(got, want) {
+			t.Errorf("InRect(%d, %d, %d, %d) = %v instead of %v.",
+				x, y, x1, y1, got, want)
+		}
+
+		want = bruteForce(points, func(p point) bool {
+			return p.x == x && p.y == y
+		})
+		if got := sorted(g.At(x, y)); !slices.Equal(got, want) {
+			t.Errorf("At(%d, %d) = %v instead of %v.", x, y, got, want)
+		}
+	}
+
+	// The whole map, which is checked by scanning occupied cells.
+	want := bruteForce(points, func(point) bool { return true })
+	if got := sorted(g.InRect(-1000, -1000, 1000, 1000)); !slices.Equal(got, want) {
+		t.Errorf("InRect over the whole map found %d values instead of %d.",
+			len(got), len(want))
+	}
+
+	// Regions whose area in cells overflows an int.
+	g1 := NewGrid[int](1)
+	g1.Insert(0, 0, 0)
+	g1.Insert(1, math.MaxInt, math.MinInt)
+	rects := []struct {
+		x0, y0, x1, y1 int
+		want           []int
+	}{
+		{math.MinInt, math.MinInt, math.MaxInt, math.MaxInt, []int{0, 1}},
+		{0, math.MinInt, 1 << 32, math.MaxInt, []int{0}},
+		{math.MaxInt, math.MinInt, math.MaxInt, math.MinInt + 1, []int{1}},
+	}
+	for i, rect := range rects {
+		got := sorted(g1.InRect(rect.x0, rect.y0, rect.x1, rect.y1))
+		if !slices.Equal(got, rect.want) {
+			t.Errorf("Huge rect %d found %v instead of %v.", i, got, rect.want)
+		}
+	}
+}
+
+const (
+	benchEntities = 5000
+	benchMapSize  = 500
+)
+
+// benchGrid returns a grid of benchEntities values scattered over a square
+// map, along with their positions.
+func benchGrid() (*Grid[int], []point) {
+	r := rand.New(rand.NewSource(1))
+	g := NewGrid[int](8)
+	points := make([]point, benchEntities)
+	for id := range points {
+		points[id] = point{r.Intn(benchMapSize), r.Intn(benchMapSize)}
+		g.Insert(id, points[id].x, points[id].y)
+	}
+	return g, points
+}
+
+// benchCount keeps the compiler from optimizing away benchmark queries.
+var benchCount int
+
+func BenchmarkInRadius(b *testing.B) {
+	g, points := benchGrid()
+	const rad = 8
+
+	b.Run("Grid", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			p := points[i%len(points)]
+			for range g.InRadius(p.x, p.y, rad) {
+				benchCount++
+			}
+		}
+	})
+
+	b.Run("bruteForce", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			p := points[i%len(points)]
+			for _, q := range points {
+				if (q.x-p.x)*(q.x-p.x)+(q.y-p.y)*(q.y-p.y) <= rad*rad {
+					benchCount++
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkMove(b *testing.B) {
+	g, points := benchGrid()
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < b.N; i++ {
+		id := i % len(points)
+		p := &points[id]
+		p.x += r.Intn(3) - 1
+		p.y += r.Intn(3) - 1
+		g.Move(id, p.x, p.y)
+	}
+}
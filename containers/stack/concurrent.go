@@ -0,0 +1,83 @@
+package stack
+
+import (
+	"iter"
+	"sync/atomic"
+)
+
+// Concurrent is a lock-free stack of values of type T, implemented as a
+// Treiber stack: a linked list whose top is replaced with compare-and-swap.
+// Every Push allocates, so it is slower than an Of when used by a single
+// goroutine, but it does not serialize goroutines which use it at once.
+type Concurrent[T any] struct {
+	top atomic.Pointer[node[T]]
+	n   atomic.Int64
+}
+
+// node is an element of a Concurrent stack. Nodes are never modified after
+// they are pushed.
+type node[T any] struct {
+	v    T
+	next *node[T]
+}
+
+// NewConcurrent creates an empty lock-free stack.
+func NewConcurrent[T any]() *Concurrent[T] {
+	return &Concurrent[T]{}
+}
+
+// Len returns the number of values in the stack. It may be out of date by
+// the time it returns if other goroutines are using the stack.
+func (s *Concurrent[T]) Len() int {
+	// Pop can decrement the count before the matching Push increments it.
+	return int(max(s.n.Load(), 0))
+}
+
+// Push adds v to the top of the stack.
+func (s *Concurrent[T]) Push(v T) {
+	n := &node[T]{v: v}
+	for {
+		n.next = s.top.Load()
+		if s.top.CompareAndSwap(n.next, n) { break }
+	}
+	s.n.Add(1)
+}
+
+// Pop removes and returns the value on top of the stack. ok is false if the
+// stack is empty.
+func (s *Concurrent[T]) Pop() (v T, ok bool) {
+	for {
+		top := s.top.Load()
+		if top == nil { return v, false }
+		if s.top.CompareAndSwap(top, top.next) {
+			s.n.Add(-1)
+			return top.v, true
+		}
+	}
+}
+
+// Peek returns the value on top of the stack without removing it. ok is
+// false if the stack is empty.
+func (s *Concurrent[T]) Peek() (v T, ok bool) {
+	top := s.top.Load()
+	if top == nil { return v, false }
+	return top.v, true
+}
+
+// Clear removes every value from the stack.
+func (s *Concurrent[T]) Clear() {
+	removed := int64(0)
+	for n := s.top.Swap(nil); n != nil; n = n.next { removed++ }
+	s.n.Add(-removed)
+}
+
+// All returns an iterator over the values in the stack from top to bottom.
+// It iterates over the stack as it was when iteration started, so the stack
+// may be modified inside the loop.
+func (s *Concurrent[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := s.top.Load(); n != nil; n = n.next {
+			if !yield(n.v) { return }
+		}
+	}
+}
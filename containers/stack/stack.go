@@ -1,59 +1,151 @@
 package stack
 
 import (
+	"iter"
 	"sync"
 )
 
-/* Implements a thread-safe stack using container/list */
+/* Implements stacks of three kinds, chosen at construction:
 
-type Stack interface {
-	Len() int
+	NewOf[T](capacity)             // Locked with a mutex.
+	NewUnsynchronized[T](capacity) // No locking, for use by one goroutine.
+	NewConcurrent[T]()             // Lock-free, for heavy concurrent use.
 
-	Push(interface{}) 
-	Pop() (interface{}, bool) // second argument is true if stack is empty
-	
-	AddSlice([]interface{})
+Stack is the older interface{} API, which is kept as a thin wrapper around a
+locked Of[interface{}]. */
+
+// Of is a stack of values of type T backed by a slice.
+type Of[T any] struct {
+	xs []T
+	locked bool
+	m  sync.Mutex
 }
 
-var _ Stack = &stack{} // typechecking
+// NewOf creates an empty thread-safe stack with room for capacity values
+// before it needs to grow.
+func NewOf[T any](capacity int) *Of[T] {
+	return &Of[T]{xs: make([]T, 0, capacity), locked: true}
+}
 
-type stack struct {
-	xs []interface{}
-	m *sync.Mutex
+// NewUnsynchronized creates an empty stack which takes no locks, with room
+// for capacity values before it needs to grow. It must not be used by more
+// than one goroutine at a time.
+func NewUnsynchronized[T any](capacity int) *Of[T] {
+	return &Of[T]{xs: make([]T, 0, capacity)}
 }
 
-func New() Stack {
-	return &stack{xs: make([]interface{}, 0), m: new(sync.Mutex)}
+// lock locks the stack if it is synchronized.
+func (s *Of[T]) lock() {
+	if s.locked { s.m.Lock() }
+}
+
+// unlock unlocks the stack if it is synchronized.
+func (s *Of[T]) unlock() {
+	if s.locked { s.m.Unlock() }
 }
 
-func (s *stack)Len() int {
-	s.m.Lock()
-	defer s.m.Unlock()
+// Len returns the number of values in the stack.
+func (s *Of[T]) Len() int {
+	s.lock()
+	defer s.unlock()
 
 	return len(s.xs)
 }
 
-func (s *stack)Push(v interface{}) {
-	s.m.Lock()
-	defer s.m.Unlock()
+// Push adds v to the top of the stack.
+func (s *Of[T]) Push(v T) {
+	s.lock()
+	defer s.unlock()
 
 	s.xs = append(s.xs, v)
 }
 
-func (s *stack)Pop() (interface{}, bool) {
-	s.m.Lock()
-	defer s.m.Unlock()
+// PushSlice pushes each value in vs in order, so the last one ends up on
+// top.
+func (s *Of[T]) PushSlice(vs []T) {
+	s.lock()
+	defer s.unlock()
+
+	s.xs = append(s.xs, vs...)
+}
 
-	if len(s.xs) == 0 { return nil, true }
+// Pop removes and returns the value on top of the stack. ok is false if the
+// stack is empty.
+func (s *Of[T]) Pop() (v T, ok bool) {
+	s.lock()
+	defer s.unlock()
 
-	v := s.xs[len(s.xs) - 1]
-	s.xs = s.xs[0: len(s.xs) - 1]
-	return v, false
+	if len(s.xs) == 0 { return v, false }
+
+	n := len(s.xs) - 1
+	v = s.xs[n]
+	var zero T
+	s.xs[n] = zero // Don't keep the value alive.
+	s.xs = s.xs[:n]
+	return v, true
 }
 
-func (s *stack)AddSlice(vs []interface{}) {
-	s.m.Lock()
-	defer s.m.Unlock()
+// Peek returns the value on top of the stack without removing it. ok is
+// false if the stack is empty.
+func (s *Of[T]) Peek() (v T, ok bool) {
+	s.lock()
+	defer s.unlock()
+
+	if len(s.xs) == 0 { return v, false }
+	return s.xs[len(s.xs) - 1], true
+}
+
+// Clear removes every value from the stack, keeping its capacity.
+func (s *Of[T]) Clear() {
+	s.lock()
+	defer s.unlock()
+
+	clear(s.xs)
+	s.xs = s.xs[:0]
+}
+
+// All returns an iterator over the values in the stack from top to bottom,
+// which is the order they would be popped in. It iterates over a copy taken
+// when iteration starts, so the stack may be modified inside the loop.
+func (s *Of[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		s.lock()
+		xs := make([]T, len(s.xs))
+		copy(xs, s.xs)
+		s.unlock()
+
+		for i := len(xs) - 1; i >= 0; i-- {
+			if !yield(xs[i]) { return }
+		}
+	}
+}
+
+type Stack interface {
+	Len() int
+
+	Push(interface{})
+	Pop() (interface{}, bool) // second argument is true if stack is empty
+
+	AddSlice([]interface{})
+}
+
+var _ Stack = &stack{} // typechecking
+
+type stack struct {
+	s *Of[interface{}]
+}
+
+func New() Stack {
+	return &stack{NewOf[interface{}](0)}
+}
+
+func (s *stack)Len() int { return s.s.Len() }
+
+func (s *stack)Push(v interface{}) { s.s.Push(v) }
+
+func (s *stack)Pop() (interface{}, bool) {
+	v, ok := s.s.Pop()
+	return v, !ok
+}
 
-	s.xs = append(s.xs, vs)
-}
\ No newline at end of file
+func (s *stack)AddSlice(vs []interface{}) { s.s.PushSlice(vs) }
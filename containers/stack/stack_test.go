@@ -0,0 +1,179 @@
+package stack
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	s := NewOf[int](2)
+	if _, ok := s.Pop(); ok {
+		t.Errorf("Pop on an empty stack succeeded.")
+	} else if _, ok := s.Peek(); ok {
+		t.Errorf("Peek on an empty stack succeeded.")
+	}
+
+	s.Push(1)
+	s.PushSlice([]int{2, 3, 4})
+	if v, ok := s.Peek(); !ok || v != 4 {
+		t.Errorf("Peek() = %d, %v instead of 4, true.", v, ok)
+	}
+	if got := slices.Collect(s.All()); !slices.Equal(got, []int{4, 3, 2, 1}) {
+		t.Errorf("All() = %v instead of [4 3 2 1].", got)
+	}
+
+	tests := []struct {
+		v  int
+		ok bool
+	}{
+		{4, true}, {3, true}, {2, true}, {1, true}, {0, false},
+	}
+	for i, test := range tests {
+		if v, ok := s.Pop(); v != test.v || ok != test.ok {
+			t.Errorf("Test %d: Pop() = %d, %v instead of %d, %v.",
+				i, v, ok, test.v, test.ok)
+		}
+	}
+
+	s.PushSlice([]int{5, 6})
+	s.Clear()
+	if s.Len() != 0 {
+		t.Errorf("Len() = %d after Clear.", s.Len())
+	}
+}
+
+func TestStack(t *testing.T) {
+	s := New()
+	s.Push("a")
+	s.AddSlice([]interface{}{"b", "c"})
+	if s.Len() != 3 {
+		t.Errorf("Len() = %d instead of 3.", s.Len())
+	}
+
+	for i, want := range []string{"c", "b", "a"} {
+		if v, empty := s.Pop(); empty || v != want {
+			t.Errorf("Pop %d: got %v, %v instead of %s, false.",
+				i, v, empty, want)
+		}
+	}
+	if _, empty := s.Pop(); !empty {
+		t.Errorf("Pop on an empty Stack did not report it was empty.")
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	s := NewConcurrent[int]()
+	if _, ok := s.Pop(); ok {
+		t.Errorf("Pop on an empty stack succeeded.")
+	}
+
+	for i := 1; i <= 4; i++ {
+		s.Push(i)
+	}
+	if v, ok := s.Peek(); !ok || v != 4 {
+		t.Errorf("Peek() = %d, %v instead of 4, true.", v, ok)
+	}
+	if got := slices.Collect(s.All()); !slices.Equal(got, []int{4, 3, 2, 1}) {
+		t.Errorf("All() = %v instead of [4 3 2 1].", got)
+	}
+	if v, ok := s.Pop(); !ok || v != 4 || s.Len() != 3 {
+		t.Errorf("Pop() = %d, %v with Len() = %d.", v, ok, s.Len())
+	}
+
+	s.Clear()
+	if _, ok := s.Pop(); ok || s.Len() != 0 {
+		t.Errorf("Pop succeeded or Len() = %d after Clear.", s.Len())
+	}
+}
+
+// concurrentStack is the part of the stack API shared by every variant.
+type concurrentStack interface {
+	Push(int)
+	Pop() (int, bool)
+	Len() int
+}
+
+// TestConcurrentUse pushes and pops from many goroutines at once and checks
+// that every value is popped exactly once. It is most useful with -race.
+func TestConcurrentUse(t *testing.T) {
+	const workers, perWorker = 8, 1000
+
+	tests := []struct {
+		name string
+		s    concurrentStack
+	}{
+		{"Of", NewOf[int](0)},
+		{"Concurrent", NewConcurrent[int]()},
+	}
+
+	for _, test := range tests {
+		seen := make([][]int, workers)
+		wg := sync.WaitGroup{}
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					test.s.Push(w*perWorker + i)
+					if v, ok := test.s.Pop(); ok {
+						seen[w] = append(seen[w], v)
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		all := slices.Concat(seen...)
+		for v, ok := test.s.Pop(); ok; v, ok = test.s.Pop() {
+			all = append(all, v)
+		}
+		slices.Sort(all)
+		if len(all) != workers*perWorker || test.s.Len() != 0 {
+			t.Errorf("%s: popped %d values instead of %d, with Len() = %d.",
+				test.name, len(all), workers*perWorker, test.s.Len())
+			continue
+		}
+		for i, v := range all {
+			if v != i {
+				t.Errorf("%s: value %d was lost or popped twice.", test.name, i)
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkPushPop(b *testing.B) {
+	variants := []struct {
+		name string
+		new  func() concurrentStack
+	}{
+		{"Unsynchronized", func() concurrentStack { return NewUnsynchronized[int](0) }},
+		{"Of", func() concurrentStack { return NewOf[int](0) }},
+		{"Concurrent", func() concurrentStack { return NewConcurrent[int]() }},
+	}
+
+	for _, variant := range variants {
+		b.Run(variant.name, func(b *testing.B) {
+			s := variant.new()
+			for i := 0; i < b.N; i++ {
+				s.Push(i)
+				s.Push(i)
+				s.Pop()
+			}
+		})
+	}
+
+	// Unsynchronized stacks can't be shared between goroutines.
+	for _, variant := range variants[1:] {
+		b.Run(variant.name+"/parallel", func(b *testing.B) {
+			s := variant.new()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					s.Push(i)
+					s.Pop()
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,276 @@
+/*Package crash writes crash reports when the game cannot continue.
+
+A crash report is a text file containing the error or recovered panic which
+ended the game, the stacks of every goroutine, the RNG seed, the most recent
+player input and the active configuration. Reports are written to a
+timestamped file in the crash directory, and the path of the file is printed
+once the terminal has been restored, since anything printed while curses is
+active is immediately overwritten.
+
+A Reporter should be created as early as possible and kept up to date as the
+game starts:
+
+	reporter := crash.New(crash.DefaultDir(os.Environ()))
+	defer reporter.Recover()
+	reporter.SetConfig(info)
+	reporter.SetRestore(view.Close)
+*/
+package crash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phil-mansfield/rogue/config"
+	"github.com/phil-mansfield/rogue/error"
+)
+
+const (
+	// InputLogSize is the number of input entries kept for crash reports.
+	InputLogSize = 64
+
+	defaultStackSize = 1 << 16
+	timestampFormat  = "20060102-150405"
+)
+
+// exit is called after a crash report is written. It is a variable so that
+// tests can replace it.
+var exit = os.Exit
+
+// Reporter collects the state needed for crash reports. Its methods may be
+// called from any goroutine.
+type Reporter struct {
+	dir string
+
+	mu      sync.Mutex
+	restore func()
+	info    *config.Info
+	seed    int64
+	hasSeed bool
+	inputs  []string
+	next    int
+}
+
+// New creates a Reporter which writes crash reports to dir.
+func New(dir string) *Reporter {
+	return &Reporter{dir: dir, inputs: make([]string, 0, InputLogSize)}
+}
+
+// DefaultDir returns the directory crash reports are written to given the
+// environment env: rogue/crashes in $XDG_STATE_HOME, in $HOME/.local/state
+// if it is not set, or in the system temporary directory if neither is.
+func DefaultDir(env []string) string {
+	dir := lookupEnv(env, "XDG_STATE_HOME")
+	if dir == "" {
+		if home := lookupEnv(env, "HOME"); home != "" {
+			dir = filepath.Join(home, ".local", "state")
+		} else {
+			dir = os.TempDir()
+		}
+	}
+	return filepath.Join(dir, "rogue", "crashes")
+}
+
+// lookupEnv returns the value of the variable key in env, or the empty
+// string if it is not set.
+func lookupEnv(env []string, key string) string {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			return kv[len(key)+1:]
+		}
+	}
+	return ""
+}
+
+// SetRestore sets the function used to return the terminal to its normal
+// state before the crash message is printed.
+func (r *Reporter) SetRestore(restore func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.restore = restore
+}
+
+// SetConfig sets the configuration included in crash reports.
+func (r *Reporter) SetConfig(info *config.Info) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.info = info
+}
+
+// SetSeed sets the RNG seed included in crash reports.
+func (r *Reporter) SetSeed(seed int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seed, r.hasSeed = seed, true
+}
+
+// LogInput records a description of player input. Only the most recent
+// InputLogSize entries are kept.
+func (r *Reporter) LogInput(entry string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.inputs) < InputLogSize {
+		r.inputs = append(r.inputs, entry)
+	} else {
+		r.inputs[r.next] = entry
+	}
+	r.next = (r.next + 1) % InputLogSize
+}
+
+// Crash logs err, restores the terminal, writes a crash report for it, tells
+// the player where to find it, and exits the program.
+func (r *Reporter) Crash(err *error.Error) {
+	error.Log(err)
+	r.crash(err.Chain(), err.Stack)
+}
+
+// Recover writes a crash report and exits the program if the calling
+// goroutine is panicking. It must be called directly by a deferred call.
+func (r *Reporter) Recover() {
+	val := recover()
+	if val == nil {
+		return
+	}
+
+	if err, ok := val.(*error.Error); ok {
+		r.crash("panic: "+err.Chain(), err.Stack)
+	} else {
+		r.crash(fmt.Sprintf("panic: %v", val), "")
+	}
+}
+
+// crash does the work of Crash and Recover. desc describes what went wrong
+// and stack is the stack trace of where it was first detected, if known.
+func (r *Reporter) crash(desc, stack string) {
+	r.runRestore()
+
+	path, writeErr := r.Write(desc, stack)
+
+	fmt.Fprintln(os.Stderr, "A fatal error has occured.")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, desc)
+	fmt.Fprintln(os.Stderr)
+	if writeErr != nil {
+		fmt.Fprintln(os.Stderr, "The crash report could not be written:")
+		fmt.Fprintln(os.Stderr, writeErr.Error())
+	} else {
+		fmt.Fprintf(os.Stderr, "A crash report was written to '%s'.\n", path)
+	}
+
+	exit(1)
+}
+
+// runRestore calls the restore function, ignoring any panic it causes: the
+// terminal may be in no state to be restored.
+func (r *Reporter) runRestore() {
+	r.mu.Lock()
+	restore := r.restore
+	r.restore = nil
+	r.mu.Unlock()
+
+	if restore == nil {
+		return
+	}
+	defer func() { recover() }()
+	restore()
+}
+
+// Write writes a crash report to a new file in the crash directory and
+// returns its path. desc describes what went wrong and stack is the stack
+// trace of where it was first detected, or the empty string if unknown.
+//
+// Write can return Library errors.
+func (r *Reporter) Write(desc, stack string) (string, *error.Error) {
+	now := time.Now()
+	text := r.report(desc, stack, now)
+
+	if mkErr := os.MkdirAll(r.dir, 0755); mkErr != nil {
+		return "", error.Wrap(error.Library, mkErr, "Could not create "+
+			"crash directory.").With("path", r.dir)
+	}
+
+	base := filepath.Join(r.dir, "crash-"+now.Format(timestampFormat))
+	path := base + ".txt"
+	for i := 1; ; i++ {
+		f, openErr := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(openErr) {
+			path = fmt.Sprintf("%s-%d.txt", base, i)
+			continue
+		} else if openErr != nil {
+			return "", error.Wrap(error.Library, openErr, "Could not create "+
+				"crash report.").With("path", path)
+		}
+
+		_, writeErr := f.WriteString(text)
+		if closeErr := f.Close(); writeErr == nil {
+			writeErr = closeErr
+		}
+		if writeErr != nil {
+			return "", error.Wrap(error.Library, writeErr, "Could not write "+
+				"crash report.").With("path", path)
+		}
+		return path, nil
+	}
+}
+
+// report returns the contents of a crash report.
+func (r *Reporter) report(desc, stack string, now time.Time) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sections := []string{
+		fmt.Sprintf("rogue crash report\n\nTime: %s\nGo: %s %s/%s",
+			now.Format(time.RFC3339), runtime.Version(),
+			runtime.GOOS, runtime.GOARCH),
+	}
+
+	if r.hasSeed {
+		sections = append(sections, fmt.Sprintf("Seed: %d", r.seed))
+	} else {
+		sections = append(sections, "Seed: unknown")
+	}
+
+	sections = append(sections, "== Error ==\n\n"+desc)
+	if stack != "" {
+		sections = append(sections, "== Error Stack ==\n\n"+stack)
+	}
+	sections = append(sections, "== Goroutines ==\n\n"+allStacks())
+
+	inputs := make([]string, 0, len(r.inputs))
+	if len(r.inputs) == InputLogSize {
+		inputs = append(inputs, r.inputs[r.next:]...)
+		inputs = append(inputs, r.inputs[:r.next]...)
+	} else {
+		inputs = append(inputs, r.inputs...)
+	}
+	sections = append(sections,
+		"== Recent Input ==\n\n"+strings.Join(inputs, "\n"))
+
+	if r.info != nil {
+		text, err := config.Dump(r.info)
+		if err != nil {
+			text = err.Error()
+		}
+		sections = append(sections, "== Configuration ==\n\n"+text)
+	}
+
+	return strings.Join(sections, "\n\n") + "\n"
+}
+
+// allStacks returns the stack traces of every goroutine.
+func allStacks() string {
+	buf := make([]byte, defaultStackSize)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,94 @@
+package crash
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phil-mansfield/rogue/config"
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// crashTest runs f with exit replaced and returns the contents of the single
+// crash report it writes, along with the exit code.
+func crashTest(t *testing.T, f func(r *Reporter)) (report string, code int) {
+	dir := t.TempDir()
+	r := New(filepath.Join(dir, "crashes"))
+
+	info, _ := config.Default()
+	r.SetConfig(info)
+	r.SetSeed(1729)
+	restored := false
+	r.SetRestore(func() { restored = true })
+
+	code = -1
+	exit = func(c int) { code = c }
+	defer func() { exit = os.Exit }()
+
+	f(r)
+
+	if !restored {
+		t.Errorf("Terminal was not restored.")
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "crashes", "crash-*.txt"))
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 crash report, found %d.", len(matches))
+	}
+	bytes, readErr := ioutil.ReadFile(matches[0])
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	return string(bytes), code
+}
+
+func TestCrash(t *testing.T) {
+	report, code := crashTest(t, func(r *Reporter) {
+		for i := 0; i < InputLogSize+2; i++ {
+			r.LogInput(fmt.Sprintf("key %d", i))
+		}
+		r.Crash(error.New(error.Sanity, "Impossible state.").With("x", 3))
+	})
+
+	if code != 1 {
+		t.Errorf("Exit code was %d, not 1.", code)
+	}
+
+	for i, part := range []string{
+		"Seed: 1729",
+		"Sanity Error: Impossible state. [x=3]",
+		"== Error Stack ==",
+		"goroutine ",
+		"key 2\n",
+		fmt.Sprintf("key %d\n", InputLogSize+1),
+		"FramesPerSecond = 20",
+	} {
+		if !strings.Contains(report, part) {
+			t.Errorf("Test %d: Report does not contain '%s':\n%s",
+				i, part, report)
+		}
+	}
+
+	if strings.Contains(report, "key 1\n") {
+		t.Errorf("Report contains input older than InputLogSize entries.")
+	} else if strings.Index(report, "key 2\n") >
+		strings.Index(report, "key 3\n") {
+		t.Errorf("Input log is out of order.")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	report, _ := crashTest(t, func(r *Reporter) {
+		defer r.Recover()
+		panic("out of potions")
+	})
+
+	if !strings.Contains(report, "panic: out of potions") {
+		t.Errorf("Report does not contain the panic value:\n%s", report)
+	} else if !strings.Contains(report, "TestRecover") {
+		t.Errorf("Report does not contain the panicking stack:\n%s", report)
+	}
+}
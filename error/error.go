@@ -46,12 +46,25 @@ package functions may return these errors at the programmer's discretion. If
 input is in the form of labeled integers (like ErrorCodes) and there is no
 other potentially erroneous input, value errors don't need to be returned and
 you may panic instead.
+
+Errors can record the error which caused them with Wrap and carry key-value
+context with With. The standard errors.Is and errors.As functions search the
+whole chain of causes and joined errors, and ErrorCodes are valid targets for
+errors.Is, so
+
+	errors.Is(err, error.MissingFile)
+
+is true if err or any of its causes is a missing file error. The Code of an
+Error is never changed by wrapping, so checks of err.Code continue to see only
+the outermost error.
 */
 package error
 
 import (
 	"fmt"
 	"runtime"
+	"strings"
+	"sync"
 )
 
 // Type ErrorCode represents the error type of an Error instance.
@@ -61,6 +74,21 @@ type Error struct {
 	Code        ErrorCode
 	Description string
 	Stack       string
+	// Errors holds the individual errors combined by Join. It is nil for
+	// errors created by New.
+	Errors      []*Error
+	// Cause is the error which led to this one, if any. It may be another
+	// *Error or an error from the standard library.
+	Cause       error
+	// Fields holds key-value pairs describing the context the error
+	// occured in, like the file being read.
+	Fields      []Field
+}
+
+// Field is a single key-value pair of context attached to an Error.
+type Field struct {
+	Key   string
+	Value interface{}
 }
 
 const (
@@ -92,30 +120,165 @@ func (code ErrorCode) String() string {
 	return fmt.Sprintf("Unrecognized Error Code %d", code)
 }
 
-// Error returns a string describing the error. It is not
-// newline-terminaled.
+// Error returns the same string as String, which allows ErrorCodes to be
+// used as targets for errors.Is:
+//
+//	if errors.Is(err, error.MissingFile) { ... }
+func (code ErrorCode) Error() string {
+	return code.String()
+}
+
+// Error returns a string describing the error, its context fields, and its
+// chain of causes. It is not newline-terminaled.
 func (err *Error) Error() string {
 	if err == nil {
 		return "Value Error: Error() called on nil pointer."
 	}
 
-	return fmt.Sprintf("%s: %s", err.Code.String(), err.Description)
+	str := fmt.Sprintf("%s: %s", err.Code.String(), err.Description)
+	if len(err.Fields) > 0 {
+		str = fmt.Sprintf("%s [%s]", str, err.formatFields())
+	}
+	if err.Cause != nil {
+		str = fmt.Sprintf("%s: %s", str, err.Cause.Error())
+	}
+	return str
 }
 
 // VerboseError returns a string containing both a stack trace and a string
-// describing the error. It is not newline-terminated.
+// describing the error. Each cause in the chain is shown on its own line. It
+// is not newline-terminated.
 func (err *Error) VerboseError() string {
 	if err == nil {
 		return "Value Error: VerboseError() called on nil pointer."
 	}
 
-	return fmt.Sprintf("%s\n\n%s", err.Stack, err.Error())
+	return fmt.Sprintf("%s\n\n%s", err.Stack, err.Chain())
+}
+
+// Chain returns a description of the error followed by each of its causes,
+// one per line. Unlike Error, it does not repeat the descriptions of causes
+// on the same line.
+func (err *Error) Chain() string {
+	lines := []string{}
+	prefix := ""
+
+	var curr error = err
+	for curr != nil {
+		next, ok := curr.(*Error)
+		if !ok {
+			lines = append(lines, prefix + curr.Error())
+			break
+		}
+
+		line := fmt.Sprintf("%s%s: %s", prefix, next.Code, next.Description)
+		if len(next.Fields) > 0 {
+			line = fmt.Sprintf("%s [%s]", line, next.formatFields())
+		}
+		lines = append(lines, line)
+
+		prefix = "Caused by "
+		curr = next.Cause
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatFields returns the context fields of err as space-separated
+// key=value pairs.
+func (err *Error) formatFields() string {
+	pairs := make([]string, len(err.Fields))
+	for i, field := range err.Fields {
+		pairs[i] = fmt.Sprintf("%s=%v", field.Key, field.Value)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// Unwrap returns the cause of err followed by the errors combined by Join,
+// which allows the standard errors.Is and errors.As functions to search the
+// whole chain. A nil err has nothing to unwrap.
+func (err *Error) Unwrap() []error {
+	if err == nil {
+		return nil
+	}
+
+	errs := make([]error, 0, len(err.Errors) + 1)
+	if err.Cause != nil {
+		errs = append(errs, err.Cause)
+	}
+	for _, child := range err.Errors {
+		errs = append(errs, child)
+	}
+	return errs
+}
+
+// Is reports whether err has the ErrorCode target. It is used by errors.Is.
+func (err *Error) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	return ok && err.Code == code
+}
+
+// With adds a key-value pair of context to err and returns err, so that
+// calls may be chained:
+//
+//	return error.New(error.Library, desc).With("path", path)
+func (err *Error) With(key string, value interface{}) *Error {
+	err.Fields = append(err.Fields, Field{key, value})
+	return err
+}
+
+// Field returns the value of the context field with the given key, searching
+// the chain of causes if err does not have it. ok is false if no error in
+// the chain has the field.
+func (err *Error) Field(key string) (value interface{}, ok bool) {
+	for curr := err; curr != nil; {
+		for _, field := range curr.Fields {
+			if field.Key == key {
+				return field.Value, true
+			}
+		}
+
+		next, isError := curr.Cause.(*Error)
+		if !isError {
+			break
+		}
+		curr = next
+	}
+	return nil, false
+}
+
+var (
+	hookMutex sync.RWMutex
+	logHook   func(*Error)
+)
+
+// SetLogHook sets a function which is called with every Error passed to
+// Log, such as one which writes them to a log file. A nil hook removes the
+// current one. The hook must not call Log itself.
+func SetLogHook(hook func(*Error)) {
+	hookMutex.Lock()
+	defer hookMutex.Unlock()
+	logHook = hook
+}
+
+// Log passes err to the current log hook, if there is one. It should be
+// called where err is handled rather than where it is created, so that the
+// context fields added with With on the way are logged with it. Report
+// calls Log itself.
+func Log(err *Error) {
+	hookMutex.RLock()
+	hook := logHook
+	hookMutex.RUnlock()
+
+	if hook != nil {
+		hook(err)
+	}
 }
 
 // New creates a new Error corresponding to type code which is
 // described by the string desc.
 func New(code ErrorCode, desc string) *Error {
-	err := &Error{code, desc, ""}
+	err := &Error{code, desc, "", nil, nil, nil}
 
 	bytesRead, stackSize := defaultStackSize + 1, defaultStackSize
 	var stackBuf []byte
@@ -129,10 +292,41 @@ func New(code ErrorCode, desc string) *Error {
 	return err
 }
 
+// Wrap creates a new Error of type code, described by desc, which was caused
+// by cause. The code of the new Error is independent of the cause, so
+// existing checks on Code see only the outermost error.
+func Wrap(code ErrorCode, cause error, desc string) *Error {
+	err := New(code, desc)
+	err.Cause = cause
+	return err
+}
+
+// Join creates a new Error of type code which combines errs, for use when a
+// function finds several independent problems at once. Its Description is
+// summary followed by the Description of each error in errs on its own line.
+// nil errors in errs are skipped.
+func Join(code ErrorCode, summary string, errs []*Error) *Error {
+	lines := make([]string, 0, len(errs) + 1)
+	lines = append(lines, summary)
+	children := make([]*Error, 0, len(errs))
+	for _, child := range errs {
+		if child == nil {
+			continue
+		}
+		lines = append(lines, "    " + child.Description)
+		children = append(children, child)
+	}
+
+	err := New(code, strings.Join(lines, "\n"))
+	err.Errors = children
+	return err
+}
+
 // Report prints an Error to stdout along with whatever other formatting is
 // neccesary. This should only be used either as a last-ditch resort, like when
 // setup of the GUI fails.
 func Report(err *Error) {
+	Log(err)
 	fmt.Println("A fatal error has occured.")
 	fmt.Println()
 	fmt.Println(err.VerboseError())
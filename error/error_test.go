@@ -0,0 +1,86 @@
+package error
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	_, osErr := os.Open("/nonexistent/file")
+	missing := Wrap(MissingFile, osErr, "File is missing.").With("path", "x")
+	config := Wrap(Configuration, missing, "Bad include.").With("line", 3)
+
+	if config.Code != Configuration {
+		t.Errorf("Wrap changed the Code to %s.", config.Code)
+	}
+
+	tests := []struct {
+		target error
+		is     bool
+	}{
+		{Configuration, true},
+		{MissingFile, true},
+		{Sanity, false},
+		{os.ErrNotExist, true},
+	}
+	for i, test := range tests {
+		if errors.Is(config, test.target) != test.is {
+			t.Errorf("Test %d: errors.Is(err, %v) = %v.",
+				i, test.target, !test.is)
+		}
+	}
+
+	var pathErr *os.PathError
+	if !errors.As(config, &pathErr) {
+		t.Errorf("errors.As did not find the *os.PathError.")
+	}
+	var inner *Error
+	if !errors.As(error(missing), &inner) || inner != missing {
+		t.Errorf("errors.As did not find the *Error.")
+	}
+
+	if value, ok := config.Field("path"); !ok || value != "x" {
+		t.Errorf("Field('path') = %v, %v.", value, ok)
+	} else if _, ok := config.Field("size"); ok {
+		t.Errorf("Field('size') found a missing field.")
+	}
+
+	str := config.Error()
+	for _, part := range []string{
+		"Configuration Error: Bad include. [line=3]",
+		"Missing File Error: File is missing. [path=x]",
+		"no such file or directory",
+	} {
+		if !strings.Contains(str, part) {
+			t.Errorf("Error() = '%s' does not contain '%s'.", str, part)
+		}
+	}
+
+	chain := strings.Split(config.Chain(), "\n")
+	if len(chain) != 3 || !strings.HasPrefix(chain[1], "Caused by Missing") {
+		t.Errorf("Chain() gave unexpected lines:\n%s", config.Chain())
+	}
+}
+
+func TestJoinIs(t *testing.T) {
+	errs := []*Error{New(Value, "a"), New(Sanity, "b")}
+	joined := Join(Configuration, "Two problems:", errs)
+
+	if !errors.Is(joined, Sanity) || errors.Is(joined, Library) {
+		t.Errorf("errors.Is does not search joined errors.")
+	}
+
+	joined = Join(Configuration, "One problem:", []*Error{nil, errs[0], nil})
+	if len(joined.Errors) != 1 || joined.Description != "One problem:\n    a" {
+		t.Errorf("Join kept nil errors: %q, %v.",
+			joined.Description, joined.Errors)
+	} else if errors.Is(joined, Sanity) {
+		t.Errorf("errors.Is found an error which was not joined.")
+	}
+	var nilErr *Error
+	if unwrapped := nilErr.Unwrap(); unwrapped != nil {
+		t.Errorf("Unwrap of a nil Error gave %v.", unwrapped)
+	}
+}
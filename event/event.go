@@ -1,7 +1,28 @@
 package event
 
+import (
+	"github.com/phil-mansfield/rogue/error"
+)
+
 type Event interface { }
 
 type Message struct {
 	Str string
-}
\ No newline at end of file
+}
+
+// String returns the text of the message.
+func (m Message) String() string {
+	return m.Str
+}
+
+// RecoverableError reports an error which the game has recovered from, so
+// that the View can show it to the player without ending the game.
+type RecoverableError struct {
+	Code        error.ErrorCode
+	Description string
+}
+
+// String returns a description of the error suitable for the player.
+func (e RecoverableError) String() string {
+	return e.Code.String() + ": " + e.Description
+}
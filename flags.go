@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+)
+
+// stringList is a flag.Value which collects every value given for a
+// repeatable flag. It lives in its own file because main.go imports the
+// error package, which shadows the builtin error type that Set returns.
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ", ")
+}
+
+func (list *stringList) Set(str string) error {
+	*list = append(*list, str)
+	return nil
+}
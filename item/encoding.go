@@ -0,0 +1,199 @@
+package item
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// The binary encoding of a ListBuffer is a fixed-size header, followed by
+// one fixed-size record per Node in the buffer, followed by a CRC-32 (IEEE)
+// checksum of everything before it. All values are little-endian.
+//
+//	header:   magic [4]byte, version uint16,
+//	          FreeHead int16, Count int16, length uint16
+//	node:     Item (see Item.Marshal), Next int16, Prev int16
+//	trailer:  checksum uint32
+//
+// Whenever the layout changes, EncodingVersion must be incremented and
+// Unmarshal must continue to accept every older version.
+
+const (
+	// EncodingVersion is the version of the binary format written by
+	// ListBuffer.Marshal.
+	EncodingVersion = 1
+	// ItemEncodingSize is the number of bytes in an encoded Item.
+	ItemEncodingSize = 4 + 4 + 6
+
+	nodeEncodingSize   = ItemEncodingSize + 2 + 2
+	headerEncodingSize = 4 + 2 + 2 + 2 + 2
+	checksumSize       = 4
+)
+
+var encodingMagic = [4]byte{'R', 'G', 'L', 'B'}
+
+// Marshal returns the binary encoding of the item.
+func (item Item) Marshal() []byte {
+	data := make([]byte, ItemEncodingSize)
+	item.put(data)
+	return data
+}
+
+// Unmarshal sets item to the Item encoded in data.
+//
+// An error is returned if data is not ItemEncodingSize bytes long or if the
+// encoded Item fails Item.Check. item is not modified if an error is
+// returned.
+func (item *Item) Unmarshal(data []byte) *error.Error {
+	if len(data) != ItemEncodingSize {
+		desc := fmt.Sprintf(
+			"Encoded Item has length %d instead of %d.",
+			len(data), ItemEncodingSize,
+		)
+		return error.New(error.Value, desc)
+	}
+
+	var decoded Item
+	decoded.get(data)
+	if err := decoded.Check(); err != nil {
+		return error.Wrap(error.Value, err, "Decoded Item is invalid.")
+	}
+
+	*item = decoded
+	return nil
+}
+
+func (item *Item) put(data []byte) {
+	binary.LittleEndian.PutUint32(data[0:4], item.Count)
+	binary.LittleEndian.PutUint32(data[4:8], uint32(item.Type))
+	for i, x := range item.Data {
+		data[8+i] = byte(x)
+	}
+}
+
+func (item *Item) get(data []byte) {
+	item.Count = binary.LittleEndian.Uint32(data[0:4])
+	item.Type = Type(binary.LittleEndian.Uint32(data[4:8]))
+	for i := range item.Data {
+		item.Data[i] = int8(data[8+i])
+	}
+}
+
+// Marshal returns the binary encoding of the buffer, including its free
+// list.
+//
+// A Sanity error is returned if the buffer fails ListBuffer.Check, since an
+// inconsistent buffer should never be written to disk.
+func (buf *ListBuffer) Marshal() ([]byte, *error.Error) {
+	if err := buf.Check(); err != nil {
+		return nil, err
+	}
+
+	n := len(buf.Buffer)
+	data := make([]byte, headerEncodingSize + n*nodeEncodingSize + checksumSize)
+
+	copy(data[0:4], encodingMagic[:])
+	binary.LittleEndian.PutUint16(data[4:6], EncodingVersion)
+	binary.LittleEndian.PutUint16(data[6:8], uint16(buf.FreeHead))
+	binary.LittleEndian.PutUint16(data[8:10], uint16(buf.Count))
+	binary.LittleEndian.PutUint16(data[10:12], uint16(n))
+
+	for i := range buf.Buffer {
+		node := &buf.Buffer[i]
+		rec := data[headerEncodingSize + i*nodeEncodingSize:]
+
+		node.Item.put(rec)
+		binary.LittleEndian.PutUint16(
+			rec[ItemEncodingSize:ItemEncodingSize+2], uint16(node.Next),
+		)
+		binary.LittleEndian.PutUint16(
+			rec[ItemEncodingSize+2:ItemEncodingSize+4], uint16(node.Prev),
+		)
+	}
+
+	body := data[:len(data)-checksumSize]
+	binary.LittleEndian.PutUint32(data[len(body):], crc32.ChecksumIEEE(body))
+
+	return data, nil
+}
+
+// Unmarshal replaces the contents of buf with the ListBuffer encoded in data.
+//
+// A Value error is returned if data is truncated, has the wrong magic number,
+// was written by an unsupported version, has an invalid checksum, or decodes
+// to a buffer which fails ListBuffer.Check. buf is not modified if an error
+// is returned.
+func (buf *ListBuffer) Unmarshal(data []byte) *error.Error {
+	if len(data) < headerEncodingSize + checksumSize {
+		desc := fmt.Sprintf(
+			"Encoded ListBuffer has length %d, which is too short for a " +
+			"header.", len(data),
+		)
+		return error.New(error.Value, desc)
+	}
+
+	var magic [4]byte
+	copy(magic[:], data[0:4])
+	if magic != encodingMagic {
+		desc := fmt.Sprintf("Encoded ListBuffer has bad magic %q.", magic[:])
+		return error.New(error.Value, desc)
+	}
+
+	version := binary.LittleEndian.Uint16(data[4:6])
+	switch version {
+	case 1:
+		return buf.unmarshalV1(data)
+	}
+
+	desc := fmt.Sprintf(
+		"Encoded ListBuffer has version %d, but only versions up to %d are " +
+		"supported.", version, EncodingVersion,
+	)
+	return error.New(error.Value, desc)
+}
+
+func (buf *ListBuffer) unmarshalV1(data []byte) *error.Error {
+	n := int(binary.LittleEndian.Uint16(data[10:12]))
+	size := headerEncodingSize + n*nodeEncodingSize + checksumSize
+	if len(data) != size {
+		desc := fmt.Sprintf(
+			"Encoded ListBuffer with %d nodes has length %d instead of %d.",
+			n, len(data), size,
+		)
+		return error.New(error.Value, desc)
+	}
+
+	body := data[:len(data)-checksumSize]
+	sum := binary.LittleEndian.Uint32(data[len(body):])
+	if sum != crc32.ChecksumIEEE(body) {
+		return error.New(error.Value, "Encoded ListBuffer has bad checksum.")
+	}
+
+	decoded := &ListBuffer{
+		FreeHead: BufferIndex(binary.LittleEndian.Uint16(data[6:8])),
+		Count:    BufferIndex(binary.LittleEndian.Uint16(data[8:10])),
+		Buffer:   make([]Node, n),
+	}
+
+	for i := range decoded.Buffer {
+		node := &decoded.Buffer[i]
+		rec := data[headerEncodingSize + i*nodeEncodingSize:]
+
+		node.Item.get(rec)
+		node.Next = BufferIndex(binary.LittleEndian.Uint16(
+			rec[ItemEncodingSize:ItemEncodingSize+2],
+		))
+		node.Prev = BufferIndex(binary.LittleEndian.Uint16(
+			rec[ItemEncodingSize+2:ItemEncodingSize+4],
+		))
+	}
+
+	if err := decoded.Check(); err != nil {
+		return error.Wrap(error.Value, err, "Decoded ListBuffer is invalid.")
+	}
+
+	*buf = *decoded
+	return nil
+}
@@ -0,0 +1,315 @@
+package item
+
+import (
+	"fmt"
+
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// Slot is a location on an actor's body where an item can be equipped.
+type Slot uint8
+
+const (
+	MainHand Slot = iota
+	OffHand
+	Body
+	Head
+	LeftRing
+	RightRing
+	Neck
+	slotLimit
+
+	// SlotCount is the number of distinct equipment slots.
+	SlotCount = int(slotLimit)
+)
+
+// String returns a human-readable name for the slot.
+func (slot Slot) String() string {
+	switch slot {
+	case MainHand:
+		return "main hand"
+	case OffHand:
+		return "off hand"
+	case Body:
+		return "body"
+	case Head:
+		return "head"
+	case LeftRing:
+		return "left ring"
+	case RightRing:
+		return "right ring"
+	case Neck:
+		return "neck"
+	}
+
+	return fmt.Sprintf("unrecognized slot %d", slot)
+}
+
+// Fits returns true if items of the given Class may be equipped in slot.
+func (slot Slot) Fits(class Class) bool {
+	switch class {
+	case WeaponClass:
+		return slot == MainHand
+	case ShieldClass:
+		return slot == OffHand
+	case ArmorClass:
+		return slot == Body
+	case HelmetClass:
+		return slot == Head
+	case RingClass:
+		return slot == LeftRing || slot == RightRing
+	case AmuletClass:
+		return slot == Neck
+	}
+
+	return false
+}
+
+// Modifiers are the stat bonuses granted by equipped items.
+type Modifiers struct {
+	Attack, Accuracy, Defense, Speed int
+}
+
+// Add returns the sum of two sets of Modifiers.
+func (mod Modifiers) Add(other Modifiers) Modifiers {
+	return Modifiers{
+		mod.Attack + other.Attack,
+		mod.Accuracy + other.Accuracy,
+		mod.Defense + other.Defense,
+		mod.Speed + other.Speed,
+	}
+}
+
+// Modifiers returns the stat bonuses the item grants while equipped. The
+// item's enchantment is added to the primary stat of its Class.
+func (item *Item) Modifiers() Modifiers {
+	if item.Type >= typeLimit {
+		return Modifiers{}
+	}
+
+	mod := typeInfos[item.Type].modifiers
+	enchant := int(item.Data[EnchantmentData])
+
+	switch item.Type.Class() {
+	case WeaponClass:
+		mod.Attack += enchant
+	case ShieldClass, ArmorClass, HelmetClass:
+		mod.Defense += enchant
+	case RingClass, AmuletClass:
+		switch {
+		case mod.Accuracy != 0:
+			mod.Accuracy += enchant
+		case mod.Speed != 0:
+			mod.Speed += enchant
+		default:
+			mod.Defense += enchant
+		}
+	}
+
+	return mod
+}
+
+// Equipment records which items an actor has equipped. Each slot holds the
+// index of an unlinked node in a ListBuffer, or NilIndex if it is empty.
+//
+// Equipped items stay in the same ListBuffer as the actor's inventory; they
+// are only moved out of the inventory list.
+type Equipment struct {
+	Slots [SlotCount]BufferIndex
+}
+
+// NewEquipment creates a new Equipment instance with every slot empty.
+func NewEquipment() *Equipment {
+	eq := new(Equipment)
+	eq.Init()
+	return eq
+}
+
+// Init empties every slot.
+func (eq *Equipment) Init() {
+	for i := range eq.Slots {
+		eq.Slots[i] = NilIndex
+	}
+}
+
+// Equip moves the item at idx out of the inventory list starting at inv and
+// into slot. The index of the new head of the inventory list is returned.
+//
+// An error is returned if slot is invalid or already occupied, if idx is not
+// an initialized item, if the item does not fit in slot, or if idx is not in
+// the inventory list.
+func (eq *Equipment) Equip(
+	buf *ListBuffer, inv, idx BufferIndex, slot Slot,
+) (BufferIndex, *error.Error) {
+
+	if slot >= slotLimit {
+		desc := fmt.Sprintf("slot, %d, is not a valid Slot.", slot)
+		return inv, error.New(error.Value, desc)
+	} else if eq.Slots[slot] != NilIndex {
+		desc := fmt.Sprintf(
+			"Slot %s is already occupied by the item at %d.",
+			slot, eq.Slots[slot],
+		)
+		return inv, error.New(error.Value, desc)
+	}
+
+	if _, err := buf.Get(idx); err != nil {
+		return inv, err
+	}
+
+	it, err := buf.Iterator(inv)
+	if err != nil {
+		return inv, err
+	}
+	found := false
+	for it.Next() {
+		if it.Index() == idx {
+			found = true
+			break
+		}
+	}
+	if err = it.Err(); err != nil {
+		return inv, err
+	} else if !found {
+		desc := fmt.Sprintf(
+			"Item at idx, %d, is not in the inventory list starting at %d.",
+			idx, inv,
+		)
+		return inv, error.New(error.Value, desc)
+	}
+
+	item := buf.Buffer[idx].Item
+	if !slot.Fits(item.Type.Class()) {
+		desc := fmt.Sprintf(
+			"A %s cannot be equipped in the %s slot.", item.Type.Name(), slot,
+		)
+		return inv, error.New(error.Value, desc)
+	}
+
+	if idx == inv {
+		inv = buf.Buffer[idx].Next
+	}
+	buf.internalUnlink(idx)
+	eq.Slots[slot] = idx
+
+	return inv, nil
+}
+
+// Unequip moves the item in slot to the end of the inventory list starting
+// at inv. The index of the new head of the inventory list is returned.
+//
+// An error is returned if slot is invalid or empty, if slot does not hold an
+// initialized item, if the item in slot is Cursed, or if inv is not the head
+// of a valid list or is the item in slot itself.
+func (eq *Equipment) Unequip(
+	buf *ListBuffer, inv BufferIndex, slot Slot,
+) (BufferIndex, *error.Error) {
+
+	if slot >= slotLimit {
+		desc := fmt.Sprintf("slot, %d, is not a valid Slot.", slot)
+		return inv, error.New(error.Value, desc)
+	} else if eq.Slots[slot] == NilIndex {
+		desc := fmt.Sprintf("Slot %s is empty.", slot)
+		return inv, error.New(error.Value, desc)
+	}
+
+	idx := eq.Slots[slot]
+	item, err := buf.Get(idx)
+	if err != nil {
+		return inv, err
+	} else if item.HasFlag(Cursed) {
+		desc := fmt.Sprintf(
+			"The %s in slot %s is cursed and cannot be removed.",
+			item.Type.Name(), slot,
+		)
+		return inv, error.New(error.Value, desc)
+	}
+
+	if inv == NilIndex {
+		eq.Slots[slot] = NilIndex
+		return idx, nil
+	} else if inv == idx {
+		desc := fmt.Sprintf(
+			"inv, %d, is the item being unequipped from slot %s.",
+			inv, slot,
+		)
+		return inv, error.New(error.Value, desc)
+	}
+
+	tail, err := buf.Tail(inv)
+	if err != nil {
+		return inv, err
+	}
+
+	buf.internalLink(tail, idx)
+	eq.Slots[slot] = NilIndex
+
+	return inv, nil
+}
+
+// Modifiers returns the total stat bonuses granted by every equipped item.
+//
+// An error is returned if any slot references an index which is not an
+// initialized item in buf.
+func (eq *Equipment) Modifiers(buf *ListBuffer) (Modifiers, *error.Error) {
+	total := Modifiers{}
+	for _, idx := range eq.Slots {
+		if idx == NilIndex {
+			continue
+		}
+
+		item, err := buf.Get(idx)
+		if err != nil {
+			return Modifiers{}, err
+		}
+		total = total.Add(item.Modifiers())
+	}
+
+	return total, nil
+}
+
+// Check performs consistency checks on the equipment against the buffer its
+// items are stored in. An error is returned describing the first failed
+// check. If all checks pass, nil is returned.
+func (eq *Equipment) Check(buf *ListBuffer) *error.Error {
+	for i, idx := range eq.Slots {
+		if idx == NilIndex {
+			continue
+		}
+		slot := Slot(i)
+
+		inRange, initialized := buf.legalIndex(idx)
+		if !inRange || !initialized {
+			desc := fmt.Sprintf(
+				"Slot %s references %d, which is not an item.", slot, idx,
+			)
+			return error.New(error.Sanity, desc)
+		}
+
+		node := &buf.Buffer[idx]
+		if node.Next != NilIndex || node.Prev != NilIndex {
+			desc := fmt.Sprintf(
+				"Item at %d in slot %s is linked into a list.", idx, slot,
+			)
+			return error.New(error.Sanity, desc)
+		} else if !slot.Fits(node.Item.Type.Class()) {
+			desc := fmt.Sprintf(
+				"Item at %d is a %s, which does not fit in slot %s.",
+				idx, node.Item.Type.Name(), slot,
+			)
+			return error.New(error.Sanity, desc)
+		}
+
+		for j := i + 1; j < len(eq.Slots); j++ {
+			if eq.Slots[j] == idx {
+				desc := fmt.Sprintf(
+					"Item at %d is in both slot %s and slot %s.",
+					idx, slot, Slot(j),
+				)
+				return error.New(error.Sanity, desc)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,524 @@
+package item
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"strings"
+
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// Appearance is what an unidentified item looks like to the player. Color is
+// the name of the term.Color the item should be drawn with.
+type Appearance struct {
+	Name  string
+	Color string
+}
+
+// appearancePools lists the possible appearances of every Class whose Types
+// must be identified. Each pool must be at least as long as the number of
+// Types in its Class. Scroll appearances are generated from scrollSyllables
+// instead.
+var appearancePools = map[Class][]Appearance{
+	PotionClass: {
+		{"bubbling red potion", "Red"},
+		{"murky green potion", "Green"},
+		{"fizzy blue potion", "Blue"},
+		{"smoky gray potion", "Gray"},
+		{"glowing yellow potion", "Yellow"},
+		{"viscous purple potion", "Purple"},
+		{"cloudy white potion", "White"},
+		{"oily brown potion", "Brown"},
+		{"sparkling cyan potion", "Cyan"},
+		{"effervescent orange potion", "Orange"},
+	},
+	RingClass: {
+		{"ruby ring", "Red"},
+		{"emerald ring", "Green"},
+		{"sapphire ring", "Blue"},
+		{"iron ring", "Gray"},
+		{"gold ring", "Yellow"},
+		{"amethyst ring", "Purple"},
+		{"wooden ring", "Brown"},
+		{"coral ring", "Pink"},
+	},
+	AmuletClass: {
+		{"tin amulet", "Gray"},
+		{"copper amulet", "Orange"},
+		{"jade amulet", "Green"},
+		{"bone amulet", "White"},
+	},
+}
+
+// scrollColor is the Color of every scroll.
+const scrollColor = "White"
+
+var scrollSyllables = []string{
+	"ab", "zo", "xu", "qel", "fa", "nir", "oth", "pra", "kli", "mu",
+	"veh", "dor", "ys", "tan", "grim", "ul", "ae", "zim", "rok", "lo",
+}
+
+// Knowledge tracks the randomized appearance of every Type which must be
+// identified and which of those Types the player has identified. It should
+// be created once per game and saved alongside it.
+type Knowledge struct {
+	seed        int64
+	appearances [typeLimit]Appearance
+	identified  [typeLimit]bool
+}
+
+// NewKnowledge creates a new Knowledge instance whose appearances are
+// randomized according to seed. The same seed always produces the same
+// appearances. No Types start out identified.
+//
+// A Sanity error is returned if some Class does not have enough appearances
+// for its Types.
+func NewKnowledge(seed int64) (*Knowledge, *error.Error) {
+	k := &Knowledge{seed: seed}
+	r := rand.New(rand.NewSource(seed))
+
+	for class := Class(0); class < classLimit; class++ {
+		if !class.unidentified() {
+			continue
+		}
+		err := k.assign(r, class, typesOfClass(class), map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return k, nil
+}
+
+// assign gives each of types, which all belong to class, a random
+// appearance whose name is not in used.
+//
+// A Sanity error is returned if the Class does not have enough appearances
+// left.
+func (k *Knowledge) assign(
+	r *rand.Rand, class Class, types []Type, used map[string]bool,
+) *error.Error {
+
+	var pool []Appearance
+	if class == ScrollClass {
+		pool = scrollAppearances(r, len(types), used)
+	} else {
+		for _, app := range appearancePools[class] {
+			if !used[app.Name] {
+				pool = append(pool, app)
+			}
+		}
+	}
+
+	if len(pool) < len(types) {
+		desc := fmt.Sprintf(
+			"Class %d has %d Types, but only %d appearances.",
+			class, len(types) + len(used), len(pool) + len(used),
+		)
+		return error.New(error.Sanity, desc)
+	}
+
+	perm := r.Perm(len(pool))
+	for i, typ := range types {
+		k.appearances[typ] = pool[perm[i]]
+	}
+	return nil
+}
+
+// unidentified returns true if items of the given Class start out
+// unidentified.
+func (class Class) unidentified() bool {
+	switch class {
+	case PotionClass, ScrollClass, RingClass, AmuletClass:
+		return true
+	}
+	return false
+}
+
+// typesOfClass returns every Type of the given Class in increasing order.
+func typesOfClass(class Class) []Type {
+	types := []Type{}
+	for typ := Type(0); typ < typeLimit; typ++ {
+		if typ.Class() == class && typ != Uninitialized {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
+
+// scrollAppearances generates n distinct scroll labels using r, none of
+// which are named in used. The new names are added to used.
+func scrollAppearances(
+	r *rand.Rand, n int, used map[string]bool,
+) []Appearance {
+
+	pool := make([]Appearance, 0, n)
+
+	for len(pool) < n {
+		words := make([]string, 1 + r.Intn(2))
+		for i := range words {
+			syllables := 1 + r.Intn(2)
+			for j := 0; j < syllables; j++ {
+				words[i] += scrollSyllables[r.Intn(len(scrollSyllables))]
+			}
+		}
+
+		name := "scroll labeled " + strings.ToUpper(strings.Join(words, " "))
+		if used[name] {
+			continue
+		}
+		used[name] = true
+
+		pool = append(pool, Appearance{name, scrollColor})
+	}
+
+	return pool
+}
+
+// Seed returns the seed used to generate the appearances.
+func (k *Knowledge) Seed() int64 {
+	return k.seed
+}
+
+// Appearance returns the appearance of typ while it is unidentified. ok is
+// false if items of that Type never need to be identified.
+func (k *Knowledge) Appearance(typ Type) (app Appearance, ok bool) {
+	if typ >= typeLimit || !typ.Class().unidentified() {
+		return Appearance{}, false
+	}
+	return k.appearances[typ], true
+}
+
+// IsIdentified returns true if the player knows the true name of typ.
+func (k *Knowledge) IsIdentified(typ Type) bool {
+	if typ >= typeLimit {
+		return false
+	}
+	return k.identified[typ] || !typ.Class().unidentified()
+}
+
+// Identify marks typ as known to the player.
+func (k *Knowledge) Identify(typ Type) {
+	if typ < typeLimit {
+		k.identified[typ] = true
+	}
+}
+
+// DisplayName returns the name of the item as it should be shown to the
+// player, taking into account which Types are identified and which of the
+// item's Flags are set.
+func (k *Knowledge) DisplayName(item Item) string {
+	var name string
+	if app, ok := k.Appearance(item.Type); ok && !k.IsIdentified(item.Type) {
+		name = app.Name
+	} else {
+		name = item.Type.Name()
+	}
+
+	if item.HasFlag(EnchantmentKnown) && item.Type.Class().equippable() {
+		name = fmt.Sprintf("%+d %s", item.Data[EnchantmentData], name)
+	}
+
+	if item.HasFlag(CurseKnown) {
+		if item.HasFlag(Cursed) {
+			name = "cursed " + name
+		} else {
+			name = "uncursed " + name
+		}
+	}
+
+	if item.Count > 1 {
+		name = fmt.Sprintf("%s (x%d)", name, item.Count)
+	}
+
+	return name
+}
+
+// equippable returns true if items of the given Class fit in some Slot.
+func (class Class) equippable() bool {
+	for slot := Slot(0); slot < slotLimit; slot++ {
+		if slot.Fits(class) {
+			return true
+		}
+	}
+	return false
+}
+
+// The binary encoding of Knowledge records the appearance of every Type
+// which has one, so that adding Types or appearances does not change the
+// appearances in existing saves:
+//
+//	magic [4]byte, version uint16, seed int64,
+//	labels uint16, then for each scroll label:
+//	    length uint8, name [length]byte
+//	count uint16, then for each Type which has an appearance or has been
+//	identified:
+//	    type uint32, appearance uint16, identified uint8
+//	checksum uint32
+//
+// appearance is an index into the labels for scrolls, an index into
+// appearancePools for other Classes which must be identified and
+// noAppearance for the rest. Types which have been added since the
+// encoding was written are given unused appearances when it is decoded.
+//
+// Version 1 only recorded the seed and the identified Types, and the
+// appearances were regenerated from the seed:
+//
+//	magic [4]byte, version uint16, seed int64,
+//	count uint16, identified [count]uint32, checksum uint32
+//
+// The version is independent of the ListBuffer EncodingVersion.
+
+// knowledgeEncodingVersion is the version of the binary format written by
+// Knowledge.Marshal.
+const knowledgeEncodingVersion = 2
+
+const (
+	knowledgeHeaderSize = 4 + 2 + 8
+	knowledgeEntrySize  = 4 + 2 + 1
+	noAppearance        = 0xffff
+)
+
+var knowledgeMagic = [4]byte{'R', 'G', 'I', 'D'}
+
+// Marshal returns the binary encoding of the knowledge table.
+func (k *Knowledge) Marshal() []byte {
+	labels := []string{}
+	entries := []byte{}
+	count := 0
+	for typ := Type(0); typ < typeLimit; typ++ {
+		app, ok := k.Appearance(typ)
+		if !ok && !k.identified[typ] {
+			continue
+		}
+
+		index := noAppearance
+		if ok && typ.Class() == ScrollClass {
+			index = len(labels)
+			labels = append(labels, app.Name)
+		} else if ok {
+			for i, poolApp := range appearancePools[typ.Class()] {
+				if poolApp == app {
+					index = i
+				}
+			}
+		}
+
+		var entry [knowledgeEntrySize]byte
+		binary.LittleEndian.PutUint32(entry[0:4], uint32(typ))
+		binary.LittleEndian.PutUint16(entry[4:6], uint16(index))
+		if k.identified[typ] {
+			entry[6] = 1
+		}
+		entries = append(entries, entry[:]...)
+		count++
+	}
+
+	data := make([]byte, knowledgeHeaderSize)
+	copy(data[0:4], knowledgeMagic[:])
+	binary.LittleEndian.PutUint16(data[4:6], knowledgeEncodingVersion)
+	binary.LittleEndian.PutUint64(data[6:14], uint64(k.seed))
+
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(labels)))
+	for _, label := range labels {
+		data = append(data, uint8(len(label)))
+		data = append(data, label...)
+	}
+	data = binary.LittleEndian.AppendUint16(data, uint16(count))
+	data = append(data, entries...)
+
+	return binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+}
+
+// Unmarshal replaces the contents of k with the knowledge table encoded in
+// data. Both the current encoding and version 1 can be read.
+//
+// A Value error is returned if data is truncated, has the wrong magic
+// number, was written by an unsupported version, has an invalid checksum, or
+// references invalid Types or appearances. A Sanity error is returned if
+// some Class does not have enough appearances for its Types. k is not
+// modified if an error is returned.
+func (k *Knowledge) Unmarshal(data []byte) *error.Error {
+	if len(data) < knowledgeHeaderSize + 2 + checksumSize {
+		desc := fmt.Sprintf(
+			"Encoded Knowledge has length %d, which is too short for a " +
+			"header.", len(data),
+		)
+		return error.New(error.Value, desc)
+	}
+
+	var magic [4]byte
+	copy(magic[:], data[0:4])
+	if magic != knowledgeMagic {
+		desc := fmt.Sprintf("Encoded Knowledge has bad magic %q.", magic[:])
+		return error.New(error.Value, desc)
+	}
+
+	version := binary.LittleEndian.Uint16(data[4:6])
+	if version == 0 || version > knowledgeEncodingVersion {
+		desc := fmt.Sprintf(
+			"Encoded Knowledge has version %d, but only versions up to %d " +
+			"are supported.", version, knowledgeEncodingVersion,
+		)
+		return error.New(error.Value, desc)
+	}
+
+	body := data[:len(data)-checksumSize]
+	sum := binary.LittleEndian.Uint32(data[len(body):])
+	if sum != crc32.ChecksumIEEE(body) {
+		return error.New(error.Value, "Encoded Knowledge has bad checksum.")
+	}
+
+	seed := int64(binary.LittleEndian.Uint64(data[6:14]))
+	var decoded *Knowledge
+	var err *error.Error
+	if version == 1 {
+		decoded, err = unmarshalKnowledgeV1(seed, body[knowledgeHeaderSize:])
+	} else {
+		decoded, err = unmarshalKnowledge(seed, body[knowledgeHeaderSize:])
+	}
+	if err != nil {
+		return err
+	}
+
+	*k = *decoded
+	return nil
+}
+
+// unmarshalKnowledgeV1 decodes the body of a version 1 encoding, which
+// follows the header and precedes the checksum.
+func unmarshalKnowledgeV1(seed int64, body []byte) (*Knowledge, *error.Error) {
+	n := int(binary.LittleEndian.Uint16(body[0:2]))
+	if len(body) != 2 + 4*n {
+		desc := fmt.Sprintf(
+			"Encoded Knowledge with %d identified Types has length %d " +
+			"instead of %d.", n, knowledgeHeaderSize + len(body) +
+			checksumSize, knowledgeHeaderSize + 2 + 4*n + checksumSize,
+		)
+		return nil, error.New(error.Value, desc)
+	}
+
+	decoded, err := NewKnowledge(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < n; i++ {
+		typ := Type(binary.LittleEndian.Uint32(body[2+4*i : 6+4*i]))
+		if typ >= typeLimit || typ == Uninitialized {
+			desc := fmt.Sprintf(
+				"Encoded Knowledge identifies invalid Type %d.", typ,
+			)
+			return nil, error.New(error.Value, desc)
+		}
+		decoded.identified[typ] = true
+	}
+
+	return decoded, nil
+}
+
+// unmarshalKnowledge decodes the body of a current encoding, which follows
+// the header and precedes the checksum.
+func unmarshalKnowledge(seed int64, body []byte) (*Knowledge, *error.Error) {
+	truncated := error.New(error.Value, "Encoded Knowledge is truncated.")
+
+	labels := make([]string, binary.LittleEndian.Uint16(body[0:2]))
+	body = body[2:]
+	for i := range labels {
+		if len(body) < 1 || len(body) < 1 + int(body[0]) {
+			return nil, truncated
+		}
+		labels[i], body = string(body[1:1+body[0]]), body[1+body[0]:]
+	}
+
+	if len(body) < 2 {
+		return nil, truncated
+	}
+	n := int(binary.LittleEndian.Uint16(body[0:2]))
+	body = body[2:]
+	if len(body) != knowledgeEntrySize*n {
+		desc := fmt.Sprintf(
+			"Encoded Knowledge has %d bytes for %d Types instead of %d.",
+			len(body), n, knowledgeEntrySize*n,
+		)
+		return nil, error.New(error.Value, desc)
+	}
+
+	decoded := &Knowledge{seed: seed}
+	saved := [typeLimit]bool{}
+	used := map[Class]map[string]bool{}
+	for i := 0; i < n; i++ {
+		entry := body[knowledgeEntrySize*i : knowledgeEntrySize*(i+1)]
+		typ := Type(binary.LittleEndian.Uint32(entry[0:4]))
+		index := int(binary.LittleEndian.Uint16(entry[4:6]))
+		if typ >= typeLimit || typ == Uninitialized || saved[typ] {
+			desc := fmt.Sprintf(
+				"Encoded Knowledge has invalid or repeated Type %d.", typ,
+			)
+			return nil, error.New(error.Value, desc)
+		}
+		saved[typ] = true
+		decoded.identified[typ] = entry[6] != 0
+
+		class := typ.Class()
+		app, ok := appearanceAt(class, labels, index)
+		if !ok || used[class][app.Name] {
+			desc := fmt.Sprintf(
+				"Encoded Knowledge gives Type %d invalid or repeated " +
+				"appearance %d.", typ, index,
+			)
+			return nil, error.New(error.Value, desc)
+		} else if index == noAppearance {
+			continue
+		}
+
+		decoded.appearances[typ] = app
+		if used[class] == nil {
+			used[class] = map[string]bool{}
+		}
+		used[class][app.Name] = true
+	}
+
+	// Types added since the encoding was written get unused appearances.
+	r := rand.New(rand.NewSource(seed))
+	for class := Class(0); class < classLimit; class++ {
+		if !class.unidentified() {
+			continue
+		}
+
+		missing := []Type{}
+		for _, typ := range typesOfClass(class) {
+			if !saved[typ] {
+				missing = append(missing, typ)
+			}
+		}
+		if used[class] == nil {
+			used[class] = map[string]bool{}
+		}
+		if err := decoded.assign(r, class, missing, used[class]); err != nil {
+			return nil, err
+		}
+	}
+
+	return decoded, nil
+}
+
+// appearanceAt returns the appearance with the given index in an encoded
+// Knowledge for a Type of the given Class. ok is false if the index is
+// invalid for the Class.
+func appearanceAt(
+	class Class, labels []string, index int,
+) (app Appearance, ok bool) {
+
+	switch {
+	case !class.unidentified():
+		return Appearance{}, index == noAppearance
+	case class == ScrollClass && index < len(labels):
+		return Appearance{labels[index], scrollColor}, true
+	case class != ScrollClass && index < len(appearancePools[class]):
+		return appearancePools[class][index], true
+	}
+	return Appearance{}, false
+}
@@ -30,6 +30,42 @@ type Item struct {
 	Data [6]int8
 }
 
+// Indices into Item.Data which have the same meaning for every Type.
+const (
+	// EnchantmentData is the index of the item's enchantment level. It is
+	// added to the primary stat of any equipped item.
+	EnchantmentData = 0
+	// FlagsData is the index of the item's Flag bits.
+	FlagsData = 1
+)
+
+// Flag is a single bit of per-instance state stored in Item.Data[FlagsData].
+type Flag uint8
+
+const (
+	// Cursed items cannot be unequipped.
+	Cursed Flag = 1 << iota
+	// CurseKnown is set once the player knows whether the item is Cursed.
+	CurseKnown
+	// EnchantmentKnown is set once the player knows the item's enchantment.
+	EnchantmentKnown
+)
+
+// HasFlag returns true if every bit in flag is set for the item.
+func (item *Item) HasFlag(flag Flag) bool {
+	return Flag(item.Data[FlagsData]) & flag == flag
+}
+
+// SetFlag sets every bit in flag for the item.
+func (item *Item) SetFlag(flag Flag) {
+	item.Data[FlagsData] = int8(Flag(item.Data[FlagsData]) | flag)
+}
+
+// ClearFlag clears every bit in flag for the item.
+func (item *Item) ClearFlag(flag Flag) {
+	item.Data[FlagsData] = int8(Flag(item.Data[FlagsData]) &^ flag)
+}
+
 // Clear removes all data from the item and marks it as being uninitialized.
 func (item *Item) Clear() {
 	item.Count = 0
@@ -1,7 +1,11 @@
 package item
 
 import (
+	"encoding/binary"
+	"hash/crc32"
 	"testing"
+
+	"github.com/phil-mansfield/rogue/error"
 )
 
 // Note that occasionally when a garbage index needs to be chosen, certain
@@ -468,4 +472,654 @@ func benchmarkSingleton(b *testing.B, count int) {
 
 		buf.Singleton(item)
 	}
-}
\ No newline at end of file
+}
+// makeList creates a list in buf containing items with the given counts and
+// returns the indices of its nodes in list order.
+func makeList(buf *ListBuffer, counts []uint32) []BufferIndex {
+	indices := make([]BufferIndex, len(counts))
+	for i, count := range counts {
+		indices[i], _ = buf.Singleton(Item{count, TestItem, [6]int8{}})
+		if i > 0 { buf.Link(indices[i - 1], indices[i]) }
+	}
+	return indices
+}
+
+func TestIterator(t *testing.T) {
+	tests := []struct {
+		counts []uint32
+	} {
+		{[]uint32{}},
+		{[]uint32{1}},
+		{[]uint32{1, 2, 3}},
+		{[]uint32{5, 4, 3, 2, 1}},
+	}
+
+	for i, test := range tests {
+		buf := New()
+		indices := makeList(buf, test.counts)
+
+		head, tail := BufferIndex(NilIndex), BufferIndex(NilIndex)
+		if len(indices) > 0 {
+			head, tail = indices[0], indices[len(indices) - 1]
+		}
+
+		it, err := buf.Iterator(head)
+		if err != nil {
+			t.Fatalf("Test %d: %s", i, err.Error())
+		}
+		fwd := []BufferIndex{}
+		for it.Next() {
+			if it.Item().Count != test.counts[len(fwd)] {
+				t.Errorf(
+					"Test %d: Item %d has count %d instead of %d.",
+					i, len(fwd), it.Item().Count, test.counts[len(fwd)],
+				)
+			}
+			fwd = append(fwd, it.Index())
+		}
+		if it.Err() != nil {
+			t.Errorf("Test %d: %s", i, it.Err().Error())
+		} else if !sliceEq(fwd, indices) {
+			t.Errorf("Test %d: Iterator gave %v, not %v.", i, fwd, indices)
+		}
+
+		it, err = buf.ReverseIterator(tail)
+		if err != nil {
+			t.Fatalf("Test %d: %s", i, err.Error())
+		}
+		bkd := []BufferIndex{}
+		for it.Next() {
+			bkd = append([]BufferIndex{it.Index()}, bkd...)
+		}
+		if it.Err() != nil {
+			t.Errorf("Test %d: %s", i, it.Err().Error())
+		} else if !sliceEq(bkd, indices) {
+			t.Errorf(
+				"Test %d: ReverseIterator gave %v, not reversed %v.",
+				i, bkd, indices,
+			)
+		}
+
+		if n, err := buf.Len(head); err != nil {
+			t.Errorf("Test %d: %s", i, err.Error())
+		} else if n != len(indices) {
+			t.Errorf("Test %d: Len() = %d, not %d.", i, n, len(indices))
+		}
+
+		if len(indices) == 0 { continue }
+
+		mid := indices[len(indices) / 2]
+		if h, err := buf.Head(mid); err != nil || h != head {
+			t.Errorf("Test %d: Head(%d) = %d, not %d.", i, mid, h, head)
+		}
+		if tl, err := buf.Tail(mid); err != nil || tl != tail {
+			t.Errorf("Test %d: Tail(%d) = %d, not %d.", i, mid, tl, tail)
+		}
+	}
+
+	// Deleting during iteration
+
+	buf := New()
+	indices := makeList(buf, []uint32{1, 2, 3, 4})
+	it, _ := buf.Iterator(indices[0])
+	for it.Next() {
+		if it.Item().Count % 2 == 0 { buf.Delete(it.Index()) }
+	}
+	if n, _ := buf.Len(indices[0]); n != 2 {
+		t.Errorf("Len() after deleting during iteration is %d, not 2.", n)
+	}
+
+	// Invalid usage
+
+	buf = New()
+	if _, err := buf.Iterator(0); err == nil {
+		t.Errorf("Iterator() on uninitialized Item succeeded.")
+	}
+	if _, err := buf.ReverseIterator(defaultBufferLength); err == nil {
+		t.Errorf("ReverseIterator() on invalid index succeeded.")
+	}
+
+	indices = makeList(buf, []uint32{1, 2, 3})
+	buf.Buffer[indices[2]].Next = indices[0]
+	if _, err := buf.Len(indices[0]); err == nil {
+		t.Errorf("Len() of circular list succeeded.")
+	}
+}
+
+func TestFindFilter(t *testing.T) {
+	buf := New()
+	indices := makeList(buf, []uint32{1, 2, 3, 2})
+
+	idx, err := buf.Find(indices[0], func(item Item) bool {
+		return item.Count == 2
+	})
+	if err != nil {
+		t.Error(err)
+	} else if idx != indices[1] {
+		t.Errorf("Find() returned %d instead of %d.", idx, indices[1])
+	}
+
+	idx, err = buf.Find(indices[0], func(item Item) bool {
+		return item.Count == 4
+	})
+	if err != nil {
+		t.Error(err)
+	} else if idx != NilIndex {
+		t.Errorf("Find() returned %d for missing Item.", idx)
+	}
+
+	matches, err := buf.Filter(indices[0], TestItem)
+	if err != nil {
+		t.Error(err)
+	} else if !sliceEq(matches, indices) {
+		t.Errorf("Filter() returned %v instead of %v.", matches, indices)
+	}
+
+	matches, err = buf.Filter(indices[0], Uninitialized)
+	if err != nil {
+		t.Error(err)
+	} else if len(matches) != 0 {
+		t.Errorf("Filter() returned %v for Uninitialized.", matches)
+	}
+}
+
+func TestInsertSorted(t *testing.T) {
+	less := func(a, b Item) bool { return a.Count < b.Count }
+
+	tests := []struct {
+		counts []uint32
+		sorted []uint32
+	} {
+		{[]uint32{1}, []uint32{1}},
+		{[]uint32{3, 1, 2}, []uint32{1, 2, 3}},
+		{[]uint32{1, 2, 3}, []uint32{1, 2, 3}},
+		{[]uint32{3, 2, 1}, []uint32{1, 2, 3}},
+		{[]uint32{2, 1, 2, 1}, []uint32{1, 1, 2, 2}},
+	}
+
+	for i, test := range tests {
+		buf := New()
+		head := BufferIndex(NilIndex)
+
+		for _, count := range test.counts {
+			idx, _ := buf.Singleton(Item{count, TestItem, [6]int8{}})
+			newHead, err := buf.InsertSorted(head, idx, less)
+			if err != nil {
+				t.Fatalf("Test %d: %s", i, err.Error())
+			}
+			head = newHead
+		}
+
+		if err := buf.Check(); err != nil {
+			t.Fatalf("Test %d: %s", i, err.Error())
+		}
+
+		counts := []uint32{}
+		it, _ := buf.Iterator(head)
+		for it.Next() { counts = append(counts, it.Item().Count) }
+
+		if len(counts) != len(test.sorted) {
+			t.Errorf("Test %d: Got %v, not %v.", i, counts, test.sorted)
+			continue
+		}
+		for j := range counts {
+			if counts[j] != test.sorted[j] {
+				t.Errorf("Test %d: Got %v, not %v.", i, counts, test.sorted)
+				break
+			}
+		}
+	}
+
+	// Invalid usage
+
+	buf := New()
+	indices := makeList(buf, []uint32{1, 2})
+	if _, err := buf.InsertSorted(indices[0], indices[1], less); err == nil {
+		t.Errorf("InsertSorted() of linked Item succeeded.")
+	}
+	if _, err := buf.InsertSorted(indices[0], NilIndex, less); err == nil {
+		t.Errorf("InsertSorted() of NilIndex succeeded.")
+	}
+	idx, _ := buf.Singleton(Item{3, TestItem, [6]int8{}})
+	if _, err := buf.InsertSorted(indices[1], idx, less); err == nil {
+		t.Errorf("InsertSorted() into the middle of a list succeeded.")
+	}
+	if _, err := buf.InsertSorted(idx, idx, less); err == nil {
+		t.Errorf("InsertSorted() of an Item into its own list succeeded.")
+	} else if err.Code != error.Value {
+		t.Errorf("InsertSorted() of an Item into its own list gave a %s.",
+			err.Code)
+	} else if err := buf.Check(); err != nil {
+		t.Errorf("Failed InsertSorted() corrupted buffer: %s", err.Error())
+	}
+}
+
+func TestItemMarshal(t *testing.T) {
+	item := Item{1 << 20, TestItem, [6]int8{-128, -1, 0, 1, 2, 127}}
+
+	var decoded Item
+	if err := decoded.Unmarshal(item.Marshal()); err != nil {
+		t.Fatal(err)
+	} else if decoded != item {
+		t.Errorf("Item %v decoded as %v.", item, decoded)
+	}
+
+	bad := Item{1, typeLimit, [6]int8{}}
+	if err := decoded.Unmarshal(bad.Marshal()); err == nil {
+		t.Errorf("Unmarshal() of invalid Type succeeded.")
+	} else if decoded != item {
+		t.Errorf("Failed Unmarshal() modified Item to %v.", decoded)
+	}
+
+	if err := decoded.Unmarshal(item.Marshal()[1:]); err == nil {
+		t.Errorf("Unmarshal() of truncated Item succeeded.")
+	}
+}
+
+// marshalTestBuffers returns a collection of buffers with varied list and
+// free list structure.
+func marshalTestBuffers() []*ListBuffer {
+	bufs := []*ListBuffer{New()}
+
+	buf := New()
+	makeList(buf, []uint32{1, 2, 3})
+	bufs = append(bufs, buf)
+
+	buf = New()
+	indices := makeList(buf, []uint32{1, 2, 3, 4, 5})
+	buf.Delete(indices[1])
+	buf.Delete(indices[3])
+	makeList(buf, []uint32{6})
+	bufs = append(bufs, buf)
+
+	buf = New()
+	for i := 0; i < defaultBufferLength + 10; i++ {
+		buf.Singleton(Item{uint32(i), TestItem, [6]int8{int8(i)}})
+	}
+	bufs = append(bufs, buf)
+
+	return bufs
+}
+
+func TestListBufferMarshal(t *testing.T) {
+	for i, buf := range marshalTestBuffers() {
+		data, err := buf.Marshal()
+		if err != nil {
+			t.Fatalf("Test %d: %s", i, err.Error())
+		}
+
+		decoded := new(ListBuffer)
+		if err := decoded.Unmarshal(data); err != nil {
+			t.Fatalf("Test %d: %s", i, err.Error())
+		}
+
+		if decoded.FreeHead != buf.FreeHead || decoded.Count != buf.Count ||
+			len(decoded.Buffer) != len(buf.Buffer) {
+			t.Fatalf(
+				"Test %d: Decoded header (%d, %d, %d) instead of (%d, %d, %d).",
+				i, decoded.FreeHead, decoded.Count, len(decoded.Buffer),
+				buf.FreeHead, buf.Count, len(buf.Buffer),
+			)
+		}
+		for j := range buf.Buffer {
+			if decoded.Buffer[j] != buf.Buffer[j] {
+				t.Errorf(
+					"Test %d: Node %d decoded as %v instead of %v.",
+					i, j, decoded.Buffer[j], buf.Buffer[j],
+				)
+				break
+			}
+		}
+
+		// Every single-byte corruption must be caught by the checksum.
+		for j := 0; j < len(data); j += 7 {
+			data[j] ^= 0x10
+			if err := decoded.Unmarshal(data); err == nil {
+				t.Errorf("Test %d: Corrupting byte %d went undetected.", i, j)
+			}
+			data[j] ^= 0x10
+		}
+	}
+
+	// Invalid usages
+
+	buf := New()
+	data, _ := buf.Marshal()
+
+	if err := buf.Unmarshal(data[:len(data) - 1]); err == nil {
+		t.Errorf("Unmarshal() of truncated data succeeded.")
+	}
+	if err := buf.Unmarshal(nil); err == nil {
+		t.Errorf("Unmarshal() of empty data succeeded.")
+	}
+
+	data[4] = EncodingVersion + 1
+	if err := buf.Unmarshal(data); err == nil {
+		t.Errorf("Unmarshal() of unsupported version succeeded.")
+	}
+
+	buf.Buffer[0].Next = NilIndex
+	if _, err := buf.Marshal(); err == nil {
+		t.Errorf("Marshal() of inconsistent buffer succeeded.")
+	}
+}
+
+func FuzzListBufferUnmarshal(f *testing.F) {
+	for _, buf := range marshalTestBuffers() {
+		data, _ := buf.Marshal()
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzUnmarshal(t, data)
+
+		// Random input will almost never have a valid checksum, so also
+		// try it with a corrected one to exercise the checks behind it.
+		if len(data) >= headerEncodingSize + checksumSize {
+			fixed := append([]byte{}, data...)
+			body := fixed[:len(fixed) - checksumSize]
+			binary.LittleEndian.PutUint32(
+				fixed[len(body):], crc32.ChecksumIEEE(body),
+			)
+			fuzzUnmarshal(t, fixed)
+		}
+	})
+}
+
+func fuzzUnmarshal(t *testing.T, data []byte) {
+	buf := New()
+	if err := buf.Unmarshal(data); err != nil {
+		if err := buf.Check(); err != nil {
+			t.Fatalf("Failed Unmarshal() corrupted buffer: %s", err.Error())
+		}
+		return
+	}
+
+	if err := buf.Check(); err != nil {
+		t.Fatalf("Unmarshal() accepted invalid buffer: %s", err.Error())
+	}
+
+	reencoded, err := buf.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	} else if string(reencoded) != string(data) {
+		t.Fatalf("Re-encoding does not match accepted input.")
+	}
+}
+
+func TestEquip(t *testing.T) {
+	buf := New()
+	eq := NewEquipment()
+
+	types := []Type{Sword, ChainMail, RingOfProtection, RingOfProtection}
+	indices := make([]BufferIndex, len(types))
+	for i, typ := range types {
+		indices[i], _ = buf.Singleton(Item{1, typ, [6]int8{}})
+		if i > 0 { buf.Link(indices[i - 1], indices[i]) }
+	}
+	buf.Buffer[indices[0]].Item.Data[EnchantmentData] = 2
+
+	inv := indices[0]
+	steps := []struct {
+		idx BufferIndex
+		slot Slot
+	} {
+		{indices[0], MainHand},
+		{indices[2], LeftRing},
+		{indices[3], RightRing},
+		{indices[1], Body},
+	}
+
+	for i, step := range steps {
+		var err *error.Error
+		inv, err = eq.Equip(buf, inv, step.idx, step.slot)
+		if err != nil {
+			t.Fatalf("Step %d: %s", i, err.Error())
+		} else if err = buf.Check(); err != nil {
+			t.Fatalf("Step %d: %s", i, err.Error())
+		} else if err = eq.Check(buf); err != nil {
+			t.Fatalf("Step %d: %s", i, err.Error())
+		}
+
+		if n, _ := buf.Len(inv); n != len(types) - i - 1 {
+			t.Errorf("Step %d: Inventory has %d items, not %d.",
+				i, n, len(types) - i - 1)
+		}
+	}
+
+	if inv != NilIndex {
+		t.Errorf("Inventory head is %d after equipping everything.", inv)
+	}
+
+	mod, err := eq.Modifiers(buf)
+	ref := Modifiers{Attack: 7, Defense: 6, Speed: -1}
+	if err != nil {
+		t.Error(err)
+	} else if mod != ref {
+		t.Errorf("Modifiers are %v instead of %v.", mod, ref)
+	}
+
+	inv, err = eq.Unequip(buf, inv, Body)
+	if err != nil {
+		t.Fatal(err)
+	} else if inv != indices[1] {
+		t.Errorf("Inventory head is %d instead of %d.", inv, indices[1])
+	}
+	inv, err = eq.Unequip(buf, inv, MainHand)
+	if err != nil {
+		t.Fatal(err)
+	} else if tail, _ := buf.Tail(inv); tail != indices[0] {
+		t.Errorf("Unequipped item at %d is not at inventory tail.", tail)
+	}
+
+	// Invalid usages
+
+	if _, err := eq.Equip(buf, inv, indices[1], Head); err == nil {
+		t.Errorf("Equipping armor on head succeeded.")
+	}
+	if _, err := eq.Equip(buf, inv, indices[0], LeftRing); err == nil {
+		t.Errorf("Equipping into occupied slot succeeded.")
+	}
+	if _, err := eq.Equip(buf, inv, indices[2], Body); err == nil {
+		t.Errorf("Equipping item outside of inventory succeeded.")
+	}
+	if _, err := eq.Equip(buf, inv, indices[0], slotLimit); err == nil {
+		t.Errorf("Equipping into invalid slot succeeded.")
+	}
+	if _, err := eq.Unequip(buf, inv, Neck); err == nil {
+		t.Errorf("Unequipping empty slot succeeded.")
+	}
+	if _, err := eq.Equip(buf, inv, NilIndex, Body); err == nil {
+		t.Errorf("Equipping NilIndex succeeded.")
+	}
+	outOfRange := BufferIndex(len(buf.Buffer))
+	if _, err := eq.Equip(buf, inv, outOfRange, Body); err == nil {
+		t.Errorf("Equipping out of range index succeeded.")
+	}
+	if _, err := eq.Unequip(buf, inv, slotLimit); err == nil {
+		t.Errorf("Unequipping invalid slot succeeded.")
+	}
+	if _, err := eq.Unequip(buf, indices[2], LeftRing); err == nil {
+		t.Errorf("Unequipping into a list headed by the item succeeded.")
+	} else if err.Code != error.Value {
+		t.Errorf("Unequipping into a list headed by the item gave a %s.",
+			err.Code)
+	} else if err := buf.Check(); err != nil {
+		t.Errorf("Failed Unequip() corrupted buffer: %s", err.Error())
+	} else if eq.Slots[LeftRing] != indices[2] {
+		t.Errorf("Failed Unequip() emptied the slot.")
+	}
+	eq.Slots[Neck] = outOfRange
+	if _, err := eq.Unequip(buf, inv, Neck); err == nil {
+		t.Errorf("Unequipping out of range index succeeded.")
+	}
+}
+
+func TestKnowledge(t *testing.T) {
+	k1, err := NewKnowledge(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, _ := NewKnowledge(42)
+
+	potions := typesOfClass(PotionClass)
+	seen := make(map[string]bool)
+	for _, typ := range append(potions, typesOfClass(ScrollClass)...) {
+		app1, ok1 := k1.Appearance(typ)
+		app2, _ := k2.Appearance(typ)
+		if !ok1 {
+			t.Errorf("Type %s has no appearance.", typ.Name())
+		} else if app1 != app2 {
+			t.Errorf(
+				"Seed 42 gave %s appearances %v and %v.",
+				typ.Name(), app1, app2,
+			)
+		} else if seen[app1.Name] {
+			t.Errorf("Appearance %v used twice.", app1)
+		}
+		seen[app1.Name] = true
+	}
+
+	if _, ok := k1.Appearance(Sword); ok {
+		t.Errorf("Sword has an unidentified appearance.")
+	}
+	if !k1.IsIdentified(Sword) {
+		t.Errorf("Sword is not identified by default.")
+	}
+
+	potion := Item{1, PotionOfHealing, [6]int8{}}
+	app, _ := k1.Appearance(PotionOfHealing)
+	if name := k1.DisplayName(potion); name != app.Name {
+		t.Errorf("Unidentified potion displayed as '%s'.", name)
+	}
+
+	k1.Identify(PotionOfHealing)
+	potion.Count = 3
+	if name := k1.DisplayName(potion); name != "potion of healing (x3)" {
+		t.Errorf("Identified potions displayed as '%s'.", name)
+	}
+	if name := k2.DisplayName(potion); name == "potion of healing (x3)" {
+		t.Errorf("Identify() changed a different Knowledge instance.")
+	}
+
+	data := k1.Marshal()
+	decoded := new(Knowledge)
+	if err := decoded.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	} else if *decoded != *k1 {
+		t.Errorf("Knowledge did not survive Marshal() and Unmarshal().")
+	}
+
+	data[len(data) - 1] ^= 1
+	if err := decoded.Unmarshal(data); err == nil {
+		t.Errorf("Unmarshal() of corrupted Knowledge succeeded.")
+	}
+
+	// Appearances are saved rather than regenerated from the seed.
+	swapped := *k1
+	swapped.appearances[PotionOfHealing], swapped.appearances[potions[1]] =
+		k1.appearances[potions[1]], k1.appearances[PotionOfHealing]
+	if err := decoded.Unmarshal(swapped.Marshal()); err != nil {
+		t.Fatal(err)
+	} else if *decoded != swapped {
+		t.Errorf("Unmarshal() did not restore the saved appearances.")
+	}
+
+	// A Type missing from the encoding, as if it had been added since it was
+	// written, gets an unused appearance.
+	if err := decoded.Unmarshal(
+		withoutType(k1.Marshal(), PotionOfHealing),
+	); err != nil {
+		t.Fatal(err)
+	}
+	used := make(map[Appearance]bool)
+	for _, typ := range potions {
+		app, _ := decoded.Appearance(typ)
+		want, _ := k1.Appearance(typ)
+		if typ != PotionOfHealing && app != want {
+			t.Errorf("Appearance of %s changed to %v.", typ.Name(), app)
+		} else if used[app] {
+			t.Errorf("Appearance %v used twice after decoding.", app)
+		}
+		used[app] = true
+	}
+
+	// Version 1 encodings regenerate the appearances from the seed.
+	v1 := make([]byte, 16 + 4)
+	copy(v1[0:4], knowledgeMagic[:])
+	binary.LittleEndian.PutUint16(v1[4:6], 1)
+	binary.LittleEndian.PutUint64(v1[6:14], 42)
+	binary.LittleEndian.PutUint16(v1[14:16], 1)
+	binary.LittleEndian.PutUint32(v1[16:20], uint32(PotionOfHealing))
+	v1 = binary.LittleEndian.AppendUint32(v1, crc32.ChecksumIEEE(v1))
+	if err := decoded.Unmarshal(v1); err != nil {
+		t.Fatal(err)
+	} else if *decoded != *k1 {
+		t.Errorf("Version 1 Knowledge was not decoded.")
+	}
+
+	data = k1.Marshal()
+	if binary.LittleEndian.Uint16(data[4:6]) != knowledgeEncodingVersion {
+		t.Errorf("Knowledge was not written with knowledgeEncodingVersion.")
+	}
+	binary.LittleEndian.PutUint16(data[4:6], knowledgeEncodingVersion + 1)
+	if err := decoded.Unmarshal(data); err == nil {
+		t.Errorf("Unmarshal() of Knowledge from a newer version succeeded.")
+	}
+}
+
+// withoutType removes the entry for typ from an encoded Knowledge.
+func withoutType(data []byte, typ Type) []byte {
+	start := knowledgeHeaderSize + 2
+	labels := int(binary.LittleEndian.Uint16(data[knowledgeHeaderSize:]))
+	for i := 0; i < labels; i++ {
+		start += 1 + int(data[start])
+	}
+
+	n := binary.LittleEndian.Uint16(data[start:])
+	out := append([]byte{}, data[:start]...)
+	out = binary.LittleEndian.AppendUint16(out, n - 1)
+	entries := data[start+2 : len(data)-checksumSize]
+	for i := 0; i < len(entries); i += knowledgeEntrySize {
+		entry := entries[i : i+knowledgeEntrySize]
+		if Type(binary.LittleEndian.Uint32(entry)) != typ {
+			out = append(out, entry...)
+		}
+	}
+
+	return binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(out))
+}
+
+func TestDisplayNameFlags(t *testing.T) {
+	k, _ := NewKnowledge(0)
+
+	tests := []struct {
+		item Item
+		name string
+	} {
+		{Item{1, Sword, [6]int8{2, 0}}, "sword"},
+		{Item{1, Sword, [6]int8{2, int8(EnchantmentKnown)}}, "+2 sword"},
+		{Item{1, Sword, [6]int8{-1, int8(EnchantmentKnown)}}, "-1 sword"},
+		{Item{1, Sword, [6]int8{0, int8(Cursed)}}, "sword"},
+		{Item{1, Sword, [6]int8{0, int8(Cursed | CurseKnown)}},
+			"cursed sword"},
+		{Item{1, Sword, [6]int8{1, int8(CurseKnown | EnchantmentKnown)}},
+			"uncursed +1 sword"},
+	}
+
+	for i, test := range tests {
+		if name := k.DisplayName(test.item); name != test.name {
+			t.Errorf("Test %d: Expected '%s', got '%s'.", i, test.name, name)
+		}
+	}
+
+	// Cursed items cannot be removed.
+
+	buf := New()
+	eq := NewEquipment()
+	idx, _ := buf.Singleton(tests[3].item)
+	inv, _ := eq.Equip(buf, idx, idx, MainHand)
+	if _, err := eq.Unequip(buf, inv, MainHand); err == nil {
+		t.Errorf("Unequipping cursed item succeeded.")
+	}
+}
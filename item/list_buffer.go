@@ -344,6 +344,26 @@ func (buf *ListBuffer) Check() *error.Error {
 		return error.New(error.Sanity, desc)
 	}
 
+	// Check that all indices are in range.
+	if !buf.validIndex(buf.FreeHead) {
+		desc := fmt.Sprintf(
+			"FreeHead index %d is out of range for buffer of length %d.",
+			buf.FreeHead, len(buf.Buffer),
+		)
+		return error.New(error.Sanity, desc)
+	}
+
+	for i := 0; i < len(buf.Buffer); i++ {
+		node := &buf.Buffer[i]
+		if !buf.validIndex(node.Next) || !buf.validIndex(node.Prev) {
+			desc := fmt.Sprintf(
+				"Item %d has Next index %d and Prev index %d, but buffer " +
+				"length is %d.", i, node.Next, node.Prev, len(buf.Buffer),
+			)
+			return error.New(error.Sanity, desc)
+		}
+	}
+
 	// Check all Prev indices.
 	for i := 0; i < len(buf.Buffer); i++ {
 		prev := buf.Buffer[i].Prev
@@ -377,9 +397,41 @@ func (buf *ListBuffer) Check() *error.Error {
 		}
 	}
 
+	// Check that the free list holds exactly the uninitialized items.
+	if buf.FreeHead != NilIndex && buf.Buffer[buf.FreeHead].Prev != NilIndex {
+		desc := fmt.Sprintf(
+			"FreeHead %d has Prev index %d.",
+			buf.FreeHead, buf.Buffer[buf.FreeHead].Prev,
+		)
+		return error.New(error.Sanity, desc)
+	}
+
+	freeCount := 0
+	for idx := buf.FreeHead; idx != NilIndex; idx = buf.Incr(idx) {
+		if buf.Buffer[idx].Item.Type != Uninitialized {
+			desc := fmt.Sprintf("Free list contains initialized item %d.", idx)
+			return error.New(error.Sanity, desc)
+		}
+		freeCount++
+	}
+
+	if freeCount != len(buf.Buffer) - int(buf.Count) {
+		desc := fmt.Sprintf(
+			"Free list has length %d, but there are %d uninitialized items.",
+			freeCount, len(buf.Buffer) - int(buf.Count),
+		)
+		return error.New(error.Sanity, desc)
+	}
+
 	return nil
 }
 
+// validIndex returns true if idx is either NilIndex or within the range of
+// the buffer.
+func (buf *ListBuffer) validIndex(idx BufferIndex) bool {
+	return idx == NilIndex || (idx >= 0 && idx < BufferIndex(len(buf.Buffer)))
+}
+
 // hasCycle returns true if there is a cycle after in the list following the
 // given index and that cycle has not already been detected.
 func (buf *ListBuffer) hasCycle(idx BufferIndex, checkBuffer []bool) bool {
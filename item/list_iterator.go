@@ -0,0 +1,328 @@
+package item
+
+import (
+	"fmt"
+
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// Iterator walks over the items of a single list within a ListBuffer.
+//
+// The index of the following node is read before the current node is
+// returned, so it is safe to Unlink or Delete the node at it.Index() during
+// iteration. Any other modification of the list invalidates the Iterator.
+//
+// Typical usage is:
+//
+//	it, err := buf.Iterator(head)
+//	for it.Next() {
+//		item := it.Item()
+//		...
+//	}
+//	if err := it.Err(); err != nil { ... }
+type Iterator struct {
+	buf        *ListBuffer
+	curr, next BufferIndex
+	reverse    bool
+	steps      int
+	maxSteps   int
+	err        *error.Error
+}
+
+// Iterator returns an Iterator which moves forward through the list starting
+// at head. A head of NilIndex is treated as an empty list.
+//
+// An error is returned if head is not a valid index into the buffer or if it
+// represents an uninitialized item.
+func (buf *ListBuffer) Iterator(head BufferIndex) (*Iterator, *error.Error) {
+	if err := buf.checkListIndex("head", head); err != nil {
+		return nil, err
+	}
+	return &Iterator{buf, NilIndex, head, false, 0, int(buf.Count), nil}, nil
+}
+
+// ReverseIterator returns an Iterator which moves backward through the list
+// starting at tail. A tail of NilIndex is treated as an empty list.
+//
+// An error is returned if tail is not a valid index into the buffer or if it
+// represents an uninitialized item.
+func (buf *ListBuffer) ReverseIterator(tail BufferIndex) (*Iterator, *error.Error) {
+	if err := buf.checkListIndex("tail", tail); err != nil {
+		return nil, err
+	}
+	return &Iterator{buf, NilIndex, tail, true, 0, int(buf.Count), nil}, nil
+}
+
+// Next advances the iterator and returns true if there is a node at the new
+// position. Next returns false at the end of the list or if the list was
+// found to be corrupted, in which case it.Err() will be non-nil.
+func (it *Iterator) Next() bool {
+	if it.err != nil || it.next == NilIndex {
+		it.curr = NilIndex
+		return false
+	}
+
+	if it.steps >= it.maxSteps {
+		desc := fmt.Sprintf(
+			"List containing index %d has more than %d items, so it must " +
+			"contain a cycle.", it.next, it.maxSteps,
+		)
+		it.err = error.New(error.Sanity, desc)
+		it.curr = NilIndex
+		return false
+	}
+
+	inRange, initialized := it.buf.legalIndex(it.next)
+	if !inRange || !initialized {
+		desc := fmt.Sprintf(
+			"List reached index %d, which is not a valid item in a buffer " +
+			"of length %d.", it.next, len(it.buf.Buffer),
+		)
+		it.err = error.New(error.Sanity, desc)
+		it.curr = NilIndex
+		return false
+	}
+
+	it.curr = it.next
+	if it.reverse {
+		it.next = it.buf.Buffer[it.curr].Prev
+	} else {
+		it.next = it.buf.Buffer[it.curr].Next
+	}
+	it.steps++
+
+	return true
+}
+
+// Index returns the buffer index of the current node. NilIndex is returned if
+// Next has not been called or if the iterator is exhausted.
+func (it *Iterator) Index() BufferIndex {
+	return it.curr
+}
+
+// Item returns the item stored at the current node. The zero Item is returned
+// if Next has not been called or if the iterator is exhausted.
+func (it *Iterator) Item() Item {
+	if it.curr == NilIndex {
+		return Item{}
+	}
+	return it.buf.Buffer[it.curr].Item
+}
+
+// Err returns the Sanity error which stopped iteration, if any.
+func (it *Iterator) Err() *error.Error {
+	return it.err
+}
+
+// Head returns the index of the first item in the list containing idx.
+//
+// An error is returned if idx is not a valid index into the buffer, if it
+// represents an uninitialized item, or if the list contains a cycle.
+func (buf *ListBuffer) Head(idx BufferIndex) (BufferIndex, *error.Error) {
+	return buf.end(idx, true)
+}
+
+// Tail returns the index of the last item in the list containing idx.
+//
+// An error is returned if idx is not a valid index into the buffer, if it
+// represents an uninitialized item, or if the list contains a cycle.
+func (buf *ListBuffer) Tail(idx BufferIndex) (BufferIndex, *error.Error) {
+	return buf.end(idx, false)
+}
+
+func (buf *ListBuffer) end(idx BufferIndex, toHead bool) (BufferIndex, *error.Error) {
+	var (
+		it  *Iterator
+		err *error.Error
+	)
+
+	if toHead {
+		it, err = buf.ReverseIterator(idx)
+	} else {
+		it, err = buf.Iterator(idx)
+	}
+	if err != nil {
+		return NilIndex, err
+	}
+
+	end := idx
+	for it.Next() {
+		end = it.Index()
+	}
+	if err = it.Err(); err != nil {
+		return NilIndex, err
+	}
+
+	return end, nil
+}
+
+// Len returns the number of items in the list starting at head. A head of
+// NilIndex is treated as an empty list.
+//
+// An error is returned if head is not a valid index into the buffer, if it
+// represents an uninitialized item, or if the list contains a cycle.
+func (buf *ListBuffer) Len(head BufferIndex) (int, *error.Error) {
+	it, err := buf.Iterator(head)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if err = it.Err(); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// Find returns the index of the first item in the list starting at head for
+// which pred returns true. NilIndex is returned if there is no such item.
+//
+// An error is returned if head is not a valid index into the buffer, if it
+// represents an uninitialized item, or if the list contains a cycle.
+func (buf *ListBuffer) Find(
+	head BufferIndex, pred func(Item) bool,
+) (BufferIndex, *error.Error) {
+
+	it, err := buf.Iterator(head)
+	if err != nil {
+		return NilIndex, err
+	}
+
+	for it.Next() {
+		if pred(it.Item()) {
+			return it.Index(), nil
+		}
+	}
+	if err = it.Err(); err != nil {
+		return NilIndex, err
+	}
+
+	return NilIndex, nil
+}
+
+// Filter returns the indices of every item in the list starting at head with
+// the given Type, in list order.
+//
+// An error is returned if head is not a valid index into the buffer, if it
+// represents an uninitialized item, or if the list contains a cycle.
+func (buf *ListBuffer) Filter(
+	head BufferIndex, typ Type,
+) ([]BufferIndex, *error.Error) {
+
+	it, err := buf.Iterator(head)
+	if err != nil {
+		return nil, err
+	}
+
+	indices := []BufferIndex{}
+	for it.Next() {
+		if it.Item().Type == typ {
+			indices = append(indices, it.Index())
+		}
+	}
+	if err = it.Err(); err != nil {
+		return nil, err
+	}
+
+	return indices, nil
+}
+
+// InsertSorted links the unlinked item at idx into the list starting at head
+// so that it precedes the first item, b, for which less(item, b) is true. If
+// the list was sorted according to less, it remains sorted and idx is placed
+// after all items it compares equal to. The index of the new head of the list
+// is returned. A head of NilIndex is treated as an empty list.
+//
+// An error is returned if head or idx are not valid indices into the buffer,
+// if they represent uninitialized items, if idx is already linked to another
+// item or is head itself, or if the list contains a cycle.
+func (buf *ListBuffer) InsertSorted(
+	head, idx BufferIndex, less func(a, b Item) bool,
+) (BufferIndex, *error.Error) {
+
+	if idx == NilIndex {
+		return NilIndex, error.New(error.Value, "idx is NilIndex.")
+	} else if err := buf.checkListIndex("idx", idx); err != nil {
+		return NilIndex, err
+	}
+
+	node := &buf.Buffer[idx]
+	if node.Next != NilIndex || node.Prev != NilIndex {
+		desc := fmt.Sprintf(
+			"ItemNode at idx, %d, is already linked into a list.", idx,
+		)
+		return NilIndex, error.New(error.Value, desc)
+	} else if idx == head {
+		desc := fmt.Sprintf(
+			"idx, %d, is head, so it is already in the list.", idx,
+		)
+		return NilIndex, error.New(error.Value, desc)
+	}
+
+	it, err := buf.Iterator(head)
+	if err != nil {
+		return NilIndex, err
+	} else if head != NilIndex && buf.Buffer[head].Prev != NilIndex {
+		desc := fmt.Sprintf(
+			"head, %d, is preceded by the item at %d.",
+			head, buf.Buffer[head].Prev,
+		)
+		return NilIndex, error.New(error.Value, desc)
+	}
+
+	prev := BufferIndex(NilIndex)
+	for it.Next() {
+		if less(node.Item, it.Item()) {
+			break
+		}
+		prev = it.Index()
+	}
+	if err = it.Err(); err != nil {
+		return NilIndex, err
+	}
+
+	var next BufferIndex
+	if prev == NilIndex {
+		next = head
+	} else {
+		next = buf.Buffer[prev].Next
+		buf.internalLink(prev, idx)
+	}
+	if next != NilIndex {
+		buf.internalLink(idx, next)
+	}
+
+	if prev == NilIndex {
+		return idx, nil
+	}
+	return head, nil
+}
+
+// checkListIndex returns an error if idx is neither NilIndex nor the index of
+// an initialized item. name is the name of the parameter used in the error
+// description.
+func (buf *ListBuffer) checkListIndex(name string, idx BufferIndex) *error.Error {
+	if idx == NilIndex {
+		return nil
+	}
+
+	inRange, initialized := buf.legalIndex(idx)
+	if !inRange {
+		desc := fmt.Sprintf(
+			"%s, %d, is out of range for IndexBuffer of length %d.",
+			name, idx, len(buf.Buffer),
+		)
+		return error.New(error.Value, desc)
+	} else if !initialized {
+		desc := fmt.Sprintf(
+			"Item at %s, %d, has the Type value Uninitialized.", name, idx,
+		)
+		return error.New(error.Value, desc)
+	}
+
+	return nil
+}
@@ -1,7 +1,101 @@
 package item
 
+import (
+	"strings"
+)
+
 const (
 	Uninitialized Type = iota
 	TestItem
+
+	Dagger
+	Sword
+	Buckler
+	LeatherArmor
+	ChainMail
+	Helmet
+	RingOfProtection
+	RingOfAccuracy
+	AmuletOfSpeed
+	PotionOfHealing
+	PotionOfSpeed
+	PotionOfPoison
+	ScrollOfIdentify
+	ScrollOfTeleportation
+	ScrollOfEnchantment
+
 	typeLimit
 )
+
+// Class is the broad category an item Type belongs to. It determines which
+// equipment slots an item can be placed in.
+type Class uint8
+
+const (
+	Misc Class = iota
+	WeaponClass
+	ShieldClass
+	ArmorClass
+	HelmetClass
+	RingClass
+	AmuletClass
+	PotionClass
+	ScrollClass
+	classLimit
+)
+
+// typeInfo contains the static data associated with a single Type.
+type typeInfo struct {
+	name      string
+	class     Class
+	modifiers Modifiers
+}
+
+var typeInfos = [typeLimit]typeInfo{
+	Uninitialized: {"uninitialized item", Misc, Modifiers{}},
+	TestItem:      {"test item", Misc, Modifiers{}},
+
+	Dagger:           {"dagger", WeaponClass, Modifiers{Attack: 2}},
+	Sword:            {"sword", WeaponClass, Modifiers{Attack: 5}},
+	Buckler:          {"buckler", ShieldClass, Modifiers{Defense: 1}},
+	LeatherArmor:     {"leather armor", ArmorClass, Modifiers{Defense: 2}},
+	ChainMail:        {"chain mail", ArmorClass, Modifiers{Defense: 4, Speed: -1}},
+	Helmet:           {"helmet", HelmetClass, Modifiers{Defense: 1}},
+	RingOfProtection: {"ring of protection", RingClass, Modifiers{Defense: 1}},
+	RingOfAccuracy:   {"ring of accuracy", RingClass, Modifiers{Accuracy: 1}},
+	AmuletOfSpeed:    {"amulet of speed", AmuletClass, Modifiers{Speed: 1}},
+
+	PotionOfHealing:       {"potion of healing", PotionClass, Modifiers{}},
+	PotionOfSpeed:         {"potion of speed", PotionClass, Modifiers{}},
+	PotionOfPoison:        {"potion of poison", PotionClass, Modifiers{}},
+	ScrollOfIdentify:      {"scroll of identify", ScrollClass, Modifiers{}},
+	ScrollOfTeleportation: {"scroll of teleportation", ScrollClass, Modifiers{}},
+	ScrollOfEnchantment:   {"scroll of enchantment", ScrollClass, Modifiers{}},
+}
+
+// Name returns the true name of the Type.
+func (typ Type) Name() string {
+	if typ >= typeLimit {
+		return "unknown item"
+	}
+	return typeInfos[typ].name
+}
+
+// TypeByName returns the Type whose true name is name, ignoring case. ok is
+// false if there is no such Type.
+func TypeByName(name string) (typ Type, ok bool) {
+	for typ = TestItem; typ < typeLimit; typ++ {
+		if strings.EqualFold(typeInfos[typ].name, name) {
+			return typ, true
+		}
+	}
+	return Uninitialized, false
+}
+
+// Class returns the Class of the Type.
+func (typ Type) Class() Class {
+	if typ >= typeLimit {
+		return Misc
+	}
+	return typeInfos[typ].class
+}
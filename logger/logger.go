@@ -0,0 +1,284 @@
+/*Package logger writes leveled log messages to a rotating log file, so that
+runs can be inspected after the fact without disturbing the terminal.
+
+Each package creates its own Logger:
+
+	var log = logger.For("loot")
+
+	log.Debugf("Rolled %d items on table '%s'.", n, name)
+
+Nothing is written until Configure is called with the [logging] section of
+the config file, which sets the least severe level that is logged, the
+packages whose messages are logged, and the log file. Configure also logs
+every Error passed to error.Log, along with its context fields, under the
+package name "error" and at a level given by its ErrorCode. Errors are
+logged whichever packages are selected.
+*/
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phil-mansfield/rogue/config"
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// Level is the severity of a log message.
+type Level uint8
+
+const (
+	Debug Level = iota
+	Info
+	Warning
+	Error
+	// Off is above every level, and disables logging when used as the
+	// minimum level.
+	Off
+)
+
+var levelNames = [...]string{"debug", "info", "warning", "error", "off"}
+
+// String returns the lower-case name of the level.
+func (level Level) String() string {
+	if int(level) >= len(levelNames) {
+		return fmt.Sprintf("Level(%d)", level)
+	}
+	return levelNames[level]
+}
+
+// ParseLevel returns the Level with the given name, ignoring case.
+func ParseLevel(name string) (Level, bool) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), true
+		}
+	}
+	return Off, false
+}
+
+// CodeLevel returns the level at which Errors with the given code are
+// logged. Sanity and Library errors mean the program itself has failed,
+// while the others are caused by input and are often recovered from.
+func CodeLevel(code error.ErrorCode) Level {
+	switch code {
+	case error.Sanity, error.Library:
+		return Error
+	}
+	return Warning
+}
+
+// Logger writes messages on behalf of a single package.
+type Logger struct {
+	pkg string
+}
+
+// For returns the Logger for the package with the given name.
+func For(pkg string) *Logger {
+	return &Logger{pkg}
+}
+
+// Enabled returns true if messages at the given level would be written.
+// It can be used to avoid building expensive messages.
+func (log *Logger) Enabled(level Level) bool {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	return state.enabled(log.pkg, level)
+}
+
+// Debugf logs a message at the Debug level. Arguments are handled in the
+// manner of fmt.Printf.
+func (log *Logger) Debugf(format string, args ...interface{}) {
+	log.logf(Debug, format, args...)
+}
+
+// Infof logs a message at the Info level.
+func (log *Logger) Infof(format string, args ...interface{}) {
+	log.logf(Info, format, args...)
+}
+
+// Warningf logs a message at the Warning level.
+func (log *Logger) Warningf(format string, args ...interface{}) {
+	log.logf(Warning, format, args...)
+}
+
+// Errorf logs a message at the Error level.
+func (log *Logger) Errorf(format string, args ...interface{}) {
+	log.logf(Error, format, args...)
+}
+
+// logf formats and writes a message if its level and package are enabled.
+func (log *Logger) logf(level Level, format string, args ...interface{}) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if !state.enabled(log.pkg, level) {
+		return
+	}
+	state.write(log.pkg, level, fmt.Sprintf(format, args...))
+}
+
+// logState is the configuration and output shared by every Logger.
+type logState struct {
+	mutex    sync.Mutex
+	level    Level
+	packages map[string]bool
+	out      *rotatingFile
+	// now returns the time messages are stamped with. It is a field so that
+	// tests can replace it.
+	now func() time.Time
+}
+
+var state = &logState{level: Off, now: time.Now}
+
+// enabled returns true if messages from pkg at level should be written.
+func (s *logState) enabled(pkg string, level Level) bool {
+	return s.levelEnabled(level) && (len(s.packages) == 0 || s.packages[pkg])
+}
+
+// levelEnabled returns true if messages at the given level are logged by
+// some package.
+func (s *logState) levelEnabled(level Level) bool {
+	return s.out != nil && level >= s.level && level != Off
+}
+
+// write writes a single line to the log file. Errors are ignored, since
+// there is nowhere left to report them.
+func (s *logState) write(pkg string, level Level, msg string) {
+	msg = strings.Replace(msg, "\n", "\n    ", -1)
+	line := fmt.Sprintf("%s %-7s [%s] %s\n", s.now().Format(time.RFC3339),
+		strings.ToUpper(level.String()), pkg, msg)
+	s.out.write(line)
+}
+
+// logError is installed as the error package's log hook by Configure.
+// Errors are not filtered by package, since selecting a few packages to
+// debug should not hide them.
+func logError(err *error.Error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	level := CodeLevel(err.Code)
+	if state.levelEnabled(level) {
+		state.write("error", level, err.Error())
+	}
+}
+
+// DefaultFile returns the log file used when none is configured, given the
+// environment env: rogue/rogue.log in $XDG_STATE_HOME, in
+// $HOME/.local/state if it is not set, or in the system temporary directory
+// if neither is.
+func DefaultFile(env []string) string {
+	dir := lookupEnv(env, "XDG_STATE_HOME")
+	if dir == "" {
+		if home := lookupEnv(env, "HOME"); home != "" {
+			dir = filepath.Join(home, ".local", "state")
+		} else {
+			dir = os.TempDir()
+		}
+	}
+	return filepath.Join(dir, "rogue", "rogue.log")
+}
+
+// lookupEnv returns the value of the variable key in env, or the empty
+// string if it is not set.
+func lookupEnv(env []string, key string) string {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			return kv[len(key)+1:]
+		}
+	}
+	return ""
+}
+
+// Configure sets up logging according to the [logging] section of info and
+// starts logging Errors. It may be called again when the configuration is
+// reloaded, in which case the log file is reopened only if it has changed.
+//
+// Configure can return Library and Sanity errors.
+func Configure(info *config.Info) *error.Error {
+	level, ok := ParseLevel(info.Logging.Level)
+	if !ok {
+		desc := fmt.Sprintf("Logging level '%s' was accepted by the config "+
+			"package but is unknown.", info.Logging.Level)
+		return error.New(error.Sanity, desc)
+	}
+
+	path := info.Logging.File
+	if path == "" {
+		path = DefaultFile(os.Environ())
+	}
+
+	packages := make(map[string]bool)
+	for _, pkg := range info.Logging.Packages {
+		packages[pkg] = true
+	}
+
+	maxSize := int64(info.Logging.MaxSize) * 1024
+
+	// The file is opened before the lock is taken, so that an error opening
+	// it can be logged without deadlocking in logError.
+	state.mutex.Lock()
+	reopen := level != Off && (state.out == nil || state.out.path != path)
+	state.mutex.Unlock()
+
+	var out *rotatingFile
+	if reopen {
+		var err *error.Error
+		out, err = openLog(path, maxSize, info.Logging.MaxFiles)
+		if err != nil {
+			return err
+		}
+	}
+
+	state.mutex.Lock()
+	state.level, state.packages = level, packages
+	if level == Off {
+		state.closeOutput()
+	} else if out != nil {
+		state.closeOutput()
+		state.out = out
+	} else {
+		state.out.maxSize, state.out.maxFiles = maxSize, info.Logging.MaxFiles
+	}
+	state.mutex.Unlock()
+
+	error.SetLogHook(logError)
+	return nil
+}
+
+// openLog opens the log file at path.
+//
+// openLog can return Library errors.
+func openLog(
+	path string, maxSize int64, maxFiles int,
+) (*rotatingFile, *error.Error) {
+
+	out, openErr := openRotatingFile(path, maxSize, maxFiles)
+	if openErr != nil {
+		return nil, error.Wrap(error.Library, openErr, "Could not open log "+
+			"file.").With("path", path)
+	}
+	return out, nil
+}
+
+// Close stops logging and closes the log file.
+func Close() {
+	error.SetLogHook(nil)
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.closeOutput()
+}
+
+// closeOutput closes the log file, if it is open.
+func (s *logState) closeOutput() {
+	if s.out != nil {
+		s.out.close()
+		s.out = nil
+	}
+}
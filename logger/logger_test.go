@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phil-mansfield/rogue/config"
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// configure calls Configure with a log file in a temporary directory and
+// returns the file's path.
+func configure(t *testing.T, level string, packages ...string) string {
+	info, _ := config.Default()
+	info.Logging.Level = level
+	info.Logging.Packages = packages
+	info.Logging.File = filepath.Join(t.TempDir(), "logs", "rogue.log")
+
+	state.now = func() time.Time { return time.Unix(0, 0).UTC() }
+	if err := Configure(info); err != nil {
+		t.Fatalf("Configure failed: %s", err.VerboseError())
+	}
+	t.Cleanup(Close)
+
+	return info.Logging.File
+}
+
+func readLog(t *testing.T, path string) string {
+	bytes, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	return string(bytes)
+}
+
+func TestLevels(t *testing.T) {
+	path := configure(t, "info", "loot", "error")
+
+	loot, world := For("loot"), For("world")
+	loot.Debugf("hidden %d", 1)
+	loot.Infof("shown %d", 2)
+	loot.Errorf("two\nlines")
+	world.Errorf("hidden %d", 3)
+
+	if loot.Enabled(Debug) || !loot.Enabled(Warning) || world.Enabled(Error) {
+		t.Errorf("Enabled does not match the configuration.")
+	}
+
+	expected := "1970-01-01T00:00:00Z INFO    [loot] shown 2\n" +
+		"1970-01-01T00:00:00Z ERROR   [loot] two\n    lines\n"
+	if log := readLog(t, path); log != expected {
+		t.Errorf("Log is:\n%s\nExpected:\n%s", log, expected)
+	}
+}
+
+func TestErrorHook(t *testing.T) {
+	// Errors are logged even when the package filter leaves out "error".
+	path := configure(t, "warning", "loot")
+
+	error.New(error.Value, "Not logged.")
+	error.Log(error.New(error.Sanity, "Impossible."))
+	error.Log(error.New(error.Value, "Out of range.").With("x", -1))
+
+	log := readLog(t, path)
+	for _, line := range []string{
+		"ERROR   [error] Sanity Error: Impossible.\n",
+		"WARNING [error] Value Error: Out of range. [x=-1]\n",
+	} {
+		if !strings.Contains(log, line) {
+			t.Errorf("Log does not contain '%s':\n%s", line, log)
+		}
+	}
+	if strings.Contains(log, "Not logged.") {
+		t.Errorf("An Error was logged when it was created.")
+	}
+
+	Close()
+	error.Log(error.New(error.Sanity, "After close."))
+	if strings.Contains(readLog(t, path), "After close.") {
+		t.Errorf("Errors were logged after Close.")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	info, _ := config.Default()
+	info.Logging.Level = "debug"
+	info.Logging.File = filepath.Join(t.TempDir(), "rogue.log")
+	info.Logging.MaxSize = 1
+	info.Logging.MaxFiles = 3
+	if err := Configure(info); err != nil {
+		t.Fatalf("Configure failed: %s", err.VerboseError())
+	}
+	defer Close()
+
+	log := For("test")
+	line := strings.Repeat("x", 300)
+	for i := 0; i < 20; i++ {
+		log.Debugf("%s", line)
+	}
+
+	matches, _ := filepath.Glob(info.Logging.File + "*")
+	if len(matches) != 3 {
+		t.Errorf("Expected 3 log files, found %v.", matches)
+	}
+	for _, match := range matches {
+		if fileInfo, _ := os.Stat(match); fileInfo.Size() > 1024 {
+			t.Errorf("Log file '%s' has size %d.", match, fileInfo.Size())
+		}
+	}
+}
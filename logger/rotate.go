@@ -0,0 +1,103 @@
+package logger
+
+// This file does not import the error package, since its errors occur while
+// the logger's mutex is held. Passing an *error.Error from there to
+// error.Log would call the logger's own hook and deadlock.
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// rotatingFile is an append-only file which is rotated when it grows past
+// maxSize bytes. Rotation renames path to path.1, path.1 to path.2 and so on,
+// keeping at most maxFiles files including the current one.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	file *os.File
+	size int64
+}
+
+// openRotatingFile opens the file at path for appending, creating it and
+// its directory if needed.
+func openRotatingFile(
+	path string, maxSize int64, maxFiles int,
+) (*rotatingFile, error) {
+
+	f := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the current log file.
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	f.file, f.size = file, 0
+	if fileInfo, err := file.Stat(); err == nil {
+		f.size = fileInfo.Size()
+	}
+	return nil
+}
+
+// write appends line to the file, rotating first if the line would take it
+// past its maximum size. Errors are ignored, since there is nowhere left to
+// report them.
+func (f *rotatingFile) write(line string) {
+	if f.file == nil {
+		return
+	}
+
+	if f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		f.rotate()
+		if f.file == nil {
+			return
+		}
+	}
+
+	n, _ := f.file.WriteString(line)
+	f.size += int64(n)
+}
+
+// rotate closes the current file, shifts the old files along, and opens a
+// new, empty file.
+func (f *rotatingFile) rotate() {
+	f.close()
+
+	os.Remove(f.backup(f.maxFiles - 1))
+	for i := f.maxFiles - 2; i >= 0; i-- {
+		os.Rename(f.backup(i), f.backup(i+1))
+	}
+
+	f.open()
+}
+
+// backup returns the path of the i-th most recent file. The current file is
+// backup 0.
+func (f *rotatingFile) backup(i int) string {
+	if i == 0 {
+		return f.path
+	}
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
+
+// close closes the current file.
+func (f *rotatingFile) close() {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
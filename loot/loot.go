@@ -0,0 +1,368 @@
+/*Package loot generates random items from weighted loot tables.
+
+Loot tables are read from files which use the same "field = value" syntax as
+config files. A "table" assignment starts a new table and each following
+"entry" assignment adds an entry to it:
+
+	table = floor
+	entry = sword, 10, 1-5
+	entry = @potions, 20, 1-20, 1-3
+	entry = nothing, 50
+
+	table = potions
+	entry = potion of healing, 5
+	entry = potion of speed, 2, 3-20
+
+An entry is a comma-separated list of up to four values:
+
+	result, weight, depth range, count range
+
+result is either the true name of an item Type, the name of another table
+prefixed by '@', or "nothing". weight is a positive integer. The entry can
+only be chosen on dungeon depths within the depth range. For items, the
+count range gives the size of the generated stack; for tables, it gives the
+number of times the nested table is rolled. Ranges are either a single
+integer or two integers separated by '-'. Omitted values default to a
+weight of 1, all depths, and a count of 1.
+*/
+package loot
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/phil-mansfield/rogue/config"
+	"github.com/phil-mansfield/rogue/error"
+	"github.com/phil-mansfield/rogue/item"
+	"github.com/phil-mansfield/rogue/rng"
+)
+
+// Range is an inclusive range of integers.
+type Range struct {
+	Low, High int
+}
+
+// Contains returns true if x is within the range.
+func (r Range) Contains(x int) bool {
+	return x >= r.Low && x <= r.High
+}
+
+// Entry is a single weighted result within a Table. Exactly one of Type and
+// Table is set, unless the entry produces nothing, in which case neither is.
+type Entry struct {
+	Type   item.Type
+	Table  string
+	Weight int
+	Depth  Range
+	Count  Range
+}
+
+// Table is a named list of weighted entries.
+type Table struct {
+	Name    string
+	Entries []Entry
+}
+
+// Tables is a collection of loot tables indexed by name.
+type Tables map[string]*Table
+
+var (
+	allDepths = Range{0, math.MaxInt32}
+	oneCount  = Range{1, 1}
+)
+
+// Load reads the loot tables in the file at filePath.
+//
+// Load can return Configuration, Library and MissingFile errors.
+// Configuration errors are returned for malformed entries, references to
+// unknown tables or item Types, and tables which contain themselves.
+func Load(filePath string) (Tables, *error.Error) {
+	assignments, err := config.ReadAssignments(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make(Tables)
+	var curr *Table
+	refs := make(map[string]config.Assignment)
+
+	for _, a := range assignments {
+		switch a.Field {
+		case "table":
+			if a.Value == "" {
+				return nil, config.AssignmentError(a, "Table name is empty.")
+			} else if _, ok := tables[a.Value]; ok {
+				desc := fmt.Sprintf("Table '%s' is defined twice.", a.Value)
+				return nil, config.AssignmentError(a, desc)
+			}
+			curr = &Table{a.Value, []Entry{}}
+			tables[a.Value] = curr
+
+		case "entry":
+			if curr == nil {
+				desc := "Entry appears before any table."
+				return nil, config.AssignmentError(a, desc)
+			}
+
+			entry, desc := parseEntry(a.Value)
+			if desc != "" {
+				return nil, config.AssignmentError(a, desc)
+			}
+			if entry.Table != "" {
+				if _, ok := refs[entry.Table]; !ok {
+					refs[entry.Table] = a
+				}
+			}
+			curr.Entries = append(curr.Entries, entry)
+
+		default:
+			desc := fmt.Sprintf("Unknown variable '%s'.", a.Field)
+			return nil, config.AssignmentError(a, desc)
+		}
+	}
+
+	for name, a := range refs {
+		if _, ok := tables[name]; !ok {
+			desc := fmt.Sprintf("Reference to unknown table '%s'.", name)
+			return nil, config.AssignmentError(a, desc)
+		}
+	}
+
+	if name, ok := tables.findCycle(); ok {
+		desc := fmt.Sprintf(
+			"Loot table '%s' in '%s' contains itself.", name, filePath,
+		)
+		return nil, error.New(error.Configuration, desc)
+	}
+
+	return tables, nil
+}
+
+// parseEntry converts the value of an "entry" assignment to an Entry. An
+// empty string in the desc return value indicates no errors.
+func parseEntry(value string) (entry Entry, desc string) {
+	fields := strings.Split(value, ",")
+	for i := range fields {
+		fields[i] = strings.Trim(fields[i], " \t")
+	}
+	if len(fields) > 4 {
+		return entry, fmt.Sprintf(
+			"Entry has %d values, but at most 4 are allowed.", len(fields),
+		)
+	}
+
+	entry.Weight, entry.Depth, entry.Count = 1, allDepths, oneCount
+
+	result := fields[0]
+	switch {
+	case result == "":
+		return entry, "Entry has no result."
+	case result == "nothing":
+	case strings.HasPrefix(result, "@"):
+		entry.Table = result[1:]
+		if entry.Table == "" {
+			return entry, "Table reference is empty."
+		}
+	default:
+		typ, ok := item.TypeByName(result)
+		if !ok {
+			return entry, fmt.Sprintf("Unknown item type '%s'.", result)
+		}
+		entry.Type = typ
+	}
+
+	if len(fields) > 1 {
+		weight, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return entry, err.Error()
+		} else if weight <= 0 {
+			return entry, fmt.Sprintf("Weight %d is not positive.", weight)
+		}
+		entry.Weight = weight
+	}
+
+	if len(fields) > 2 {
+		if entry.Depth, desc = parseRange(fields[2]); desc != "" {
+			return entry, desc
+		}
+	}
+
+	if len(fields) > 3 {
+		if entry.Count, desc = parseRange(fields[3]); desc != "" {
+			return entry, desc
+		} else if entry.Count.Low < 0 {
+			return entry, fmt.Sprintf(
+				"Count range %d-%d is negative.",
+				entry.Count.Low, entry.Count.High,
+			)
+		}
+	}
+
+	return entry, ""
+}
+
+// parseRange converts a string of the form "low-high" or "n" to a Range. An
+// empty string in the desc return value indicates no errors.
+func parseRange(str string) (r Range, desc string) {
+	subs := strings.SplitN(str, "-", 2)
+
+	low, err := strconv.Atoi(strings.Trim(subs[0], " \t"))
+	if err != nil {
+		return r, err.Error()
+	}
+	high := low
+	if len(subs) == 2 {
+		if high, err = strconv.Atoi(strings.Trim(subs[1], " \t")); err != nil {
+			return r, err.Error()
+		}
+	}
+
+	if low > high {
+		return r, fmt.Sprintf("Range %d-%d is empty.", low, high)
+	}
+	return Range{low, high}, ""
+}
+
+// findCycle returns the name of a table which can reach itself through
+// nested table entries. ok is false if there are no such tables.
+func (tables Tables) findCycle() (name string, ok bool) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		switch state[name] {
+		case visiting:
+			return true
+		case visited:
+			return false
+		}
+
+		state[name] = visiting
+		for _, entry := range tables[name].Entries {
+			if entry.Table != "" && visit(entry.Table) {
+				return true
+			}
+		}
+		state[name] = visited
+
+		return false
+	}
+
+	for name := range tables {
+		if visit(name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Generator rolls items from loot tables. Generators whose RNGs are in the
+// same state produce the same sequence of items from the same calls.
+type Generator struct {
+	tables Tables
+	rng    *rng.RNG
+}
+
+// NewGenerator creates a Generator which rolls on tables using r. r should
+// be the game's rng.Loot stream, so that the state of the Generator is saved
+// with the game.
+func NewGenerator(tables Tables, r *rng.RNG) *Generator {
+	return &Generator{tables, r}
+}
+
+// Generate rolls once on the named table for the given dungeon depth and
+// appends the resulting items to the end of the pile list starting at pile.
+// The index of the head of the pile is returned. A pile of item.NilIndex is
+// treated as an empty pile.
+//
+// An error is returned if there is no table with the given name or if buf
+// does not have room for the generated items. Items generated before the
+// buffer filled remain in the pile.
+func (gen *Generator) Generate(
+	name string, depth int, buf *item.ListBuffer, pile item.BufferIndex,
+) (item.BufferIndex, *error.Error) {
+
+	table, ok := gen.tables[name]
+	if !ok {
+		desc := fmt.Sprintf("There is no loot table named '%s'.", name)
+		return pile, error.New(error.Value, desc)
+	}
+
+	tail := item.BufferIndex(item.NilIndex)
+	if pile != item.NilIndex {
+		var err *error.Error
+		if tail, err = buf.Tail(pile); err != nil {
+			return pile, err
+		}
+	}
+
+	return gen.roll(table, depth, buf, pile, &tail)
+}
+
+// roll performs a single roll on table, appending results after *tail.
+func (gen *Generator) roll(
+	table *Table, depth int,
+	buf *item.ListBuffer, pile item.BufferIndex, tail *item.BufferIndex,
+) (item.BufferIndex, *error.Error) {
+
+	total := 0
+	for _, entry := range table.Entries {
+		if entry.Depth.Contains(depth) {
+			total += entry.Weight
+		}
+	}
+	if total == 0 {
+		return pile, nil
+	}
+
+	x := gen.rng.Intn(total)
+	var chosen Entry
+	for _, entry := range table.Entries {
+		if !entry.Depth.Contains(depth) {
+			continue
+		}
+		if x < entry.Weight {
+			chosen = entry
+			break
+		}
+		x -= entry.Weight
+	}
+
+	count := chosen.Count.Low
+	if chosen.Count.High > chosen.Count.Low {
+		count += gen.rng.Intn(chosen.Count.High - chosen.Count.Low + 1)
+	}
+
+	switch {
+	case chosen.Table != "":
+		var err *error.Error
+		for i := 0; i < count; i++ {
+			nested := gen.tables[chosen.Table]
+			if pile, err = gen.roll(nested, depth, buf, pile, tail); err != nil {
+				return pile, err
+			}
+		}
+
+	case chosen.Type != item.Uninitialized && count > 0:
+		idx, err := buf.Singleton(item.Item{Count: uint32(count), Type: chosen.Type})
+		if err != nil {
+			return pile, err
+		}
+
+		if *tail == item.NilIndex {
+			pile = idx
+		} else if err = buf.Link(*tail, idx); err != nil {
+			return pile, err
+		}
+		*tail = idx
+	}
+
+	return pile, nil
+}
@@ -0,0 +1,152 @@
+package loot
+
+import (
+	"testing"
+
+	"github.com/phil-mansfield/rogue/error"
+	"github.com/phil-mansfield/rogue/item"
+	"github.com/phil-mansfield/rogue/rng"
+)
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		in      string
+		entry   Entry
+		isValid bool
+	}{
+		{"sword", Entry{item.Sword, "", 1, allDepths, oneCount}, true},
+		{"sword, 3", Entry{item.Sword, "", 3, allDepths, oneCount}, true},
+		{"@t, 2, 4-6, 0-3", Entry{0, "t", 2, Range{4, 6}, Range{0, 3}}, true},
+		{"nothing, 5, 2", Entry{0, "", 5, Range{2, 2}, oneCount}, true},
+		{"Potion Of Healing", Entry{item.PotionOfHealing, "", 1,
+			allDepths, oneCount}, true},
+		{"", Entry{}, false},
+		{"@", Entry{}, false},
+		{"sword, 0", Entry{}, false},
+		{"sword, x", Entry{}, false},
+		{"sword, 1, 3-2", Entry{}, false},
+		{"sword, 1, 1, -1", Entry{}, false},
+		{"sword, 1, 1, 1, 1", Entry{}, false},
+		{"vorpal sword", Entry{}, false},
+	}
+
+	for i, test := range tests {
+		entry, desc := parseEntry(test.in)
+		if test.isValid && desc != "" {
+			t.Errorf("Test %d: parseEntry('%s') gave error '%s'.",
+				i, test.in, desc)
+		} else if !test.isValid && desc == "" {
+			t.Errorf("Test %d: parseEntry('%s') gave %v instead of an error.",
+				i, test.in, entry)
+		} else if test.isValid && entry != test.entry {
+			t.Errorf("Test %d: parseEntry('%s') gave %v instead of %v.",
+				i, test.in, entry, test.entry)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		filePath string
+		isValid  bool
+		code     error.ErrorCode
+	}{
+		{"test_loot_files/valid.txt", true, 0},
+		{"test_loot_files/does_not_exist.txt", false, error.MissingFile},
+		{"test_loot_files/cycle.txt", false, error.Configuration},
+		{"test_loot_files/unknown_table.txt", false, error.Configuration},
+		{"test_loot_files/unknown_type.txt", false, error.Configuration},
+		{"test_loot_files/bad_range.txt", false, error.Configuration},
+		{"test_loot_files/orphan_entry.txt", false, error.Configuration},
+	}
+
+	for i, test := range tests {
+		tables, err := Load(test.filePath)
+		if test.isValid && err != nil {
+			t.Errorf("Test %d: Expected '%s' to be valid, but got %s.",
+				i, test.filePath, err.Error())
+		} else if !test.isValid && err == nil {
+			t.Errorf("Test %d: Expected '%s' to give %s, but got %v.",
+				i, test.filePath, test.code, tables)
+		} else if !test.isValid && err.Code != test.code {
+			t.Errorf("Test %d: Expected '%s' to give %s, but got %s.",
+				i, test.filePath, test.code, err.Error())
+		}
+	}
+
+	tables, _ := Load("test_loot_files/valid.txt")
+	if len(tables) != 2 {
+		t.Fatalf("Loaded %d tables instead of 2.", len(tables))
+	} else if n := len(tables["floor"].Entries); n != 3 {
+		t.Errorf("Table 'floor' has %d entries instead of 3.", n)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tables, err := Load("test_loot_files/valid.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen1 := NewGenerator(tables, rng.NewStreams(7).Get(rng.Loot))
+	gen2 := NewGenerator(tables, rng.NewStreams(7).Get(rng.Loot))
+	buf1, buf2 := item.New(), item.New()
+	pile1, pile2 := item.BufferIndex(item.NilIndex), item.BufferIndex(item.NilIndex)
+
+	for depth := 1; depth <= 20; depth++ {
+		for i := 0; i < 10; i++ {
+			if pile1, err = gen1.Generate("floor", depth, buf1, pile1); err != nil {
+				t.Fatal(err)
+			}
+			pile2, _ = gen2.Generate("floor", depth, buf2, pile2)
+		}
+	}
+
+	if err = buf1.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	it1, _ := buf1.Iterator(pile1)
+	it2, _ := buf2.Iterator(pile2)
+	n := 0
+	for it1.Next() {
+		if !it2.Next() || it1.Item() != it2.Item() {
+			t.Fatalf("Generators with equal seeds diverged at item %d.", n)
+		}
+
+		switch typ := it1.Item().Type; typ {
+		case item.Sword, item.PotionOfHealing:
+			if it1.Item().Count != 1 {
+				t.Errorf("Generated %d %s instead of 1.",
+					it1.Item().Count, typ.Name())
+			}
+		case item.PotionOfSpeed:
+			if c := it1.Item().Count; c < 2 || c > 4 {
+				t.Errorf("Generated %d %s, outside of 2-4.", c, typ.Name())
+			}
+		default:
+			t.Errorf("Generated unexpected item %s.", typ.Name())
+		}
+		n++
+	}
+	if it2.Next() {
+		t.Errorf("Generators with equal seeds made different numbers of items.")
+	} else if n == 0 {
+		t.Errorf("No items were generated.")
+	}
+
+	// Depth ranges exclude every entry.
+
+	gen := NewGenerator(tables, rng.New(7))
+	buf := item.New()
+	pile, err := gen.Generate("floor", 100, buf, item.NilIndex)
+	if err != nil {
+		t.Error(err)
+	} else if pile != item.NilIndex {
+		t.Errorf("Generated items outside of every depth range.")
+	}
+
+	if _, err := gen.Generate("ceiling", 1, buf, item.NilIndex); err == nil {
+		t.Errorf("Generating from unknown table succeeded.")
+	}
+}
@@ -2,133 +2,595 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/phil-mansfield/rogue/config"
+	"github.com/phil-mansfield/rogue/crash"
 	"github.com/phil-mansfield/rogue/error"
+	"github.com/phil-mansfield/rogue/event"
+	"github.com/phil-mansfield/rogue/logger"
 	"github.com/phil-mansfield/rogue/mvc"
+	"github.com/phil-mansfield/rogue/replay"
+	"github.com/phil-mansfield/rogue/save"
 )
 
 var (
-	configPtr = flag.String("config", "", "Configuration file location. " + 
-		"Empty string indicates that the default values will be used.")
+	configPtr = flag.String("config", "", "User configuration file " +
+		"location. Empty string indicates that the file in the XDG config " +
+		"directory will be used, if it exists.")
+	helpConfigPtr = flag.Bool("help-config", false, "Print a description " +
+		"of every configuration variable and exit.")
+	showConfigPtr = flag.Bool("show-config", false, "Print the value of " +
+		"every configuration variable and where it was set, then exit.")
+	dumpConfigPtr = flag.Bool("dump-config", false, "Print the effective " +
+		"configuration in config file format and exit.")
+	initConfigPtr = flag.Bool("init-config", false, "Write a commented " +
+		"starter config file to the user configuration file location and " +
+		"exit.")
+	seedPtr = flag.Int64("seed", 0, "Seed for the random number " +
+		"generator of a new game. Zero indicates that a seed based on the " +
+		"time will be used.")
+	newGamePtr = flag.Bool("new-game", false, "Start a new game even if a " +
+		"save file exists. The old save is replaced when the game is saved.")
+	recordPtr = flag.String("record", "", "Record the game's input to a " +
+		"replay file at this location.")
+	replayPtr = flag.String("replay", "", "Play back the replay file at " +
+		"this location instead of playing, and check that the game ends in " +
+		"the recorded state. The save file is not touched.")
+	replaySpeedPtr = flag.Float64("replay-speed", 1, "Speed at which -replay " +
+		"plays back, as a multiple of the frame rate. Zero plays back " +
+		"instantly without drawing anything.")
+	overrides stringList
+
+	// reporter writes crash reports for fatal errors and panics.
+	reporter *crash.Reporter
+
+	log = logger.For("main")
+
+	// policy decides how errors in the main loop are handled.
+	policy = mvc.DefaultPolicy()
+
+	// recorder records input for -record, and is nil otherwise.
+	recorder *replay.Recorder
+	// replaying is true if the game's input comes from a replay file, in
+	// which case the keys go straight from the Controller to the Model.
+	replaying bool
 )
 
+func init() {
+	flag.Var(&overrides, "set", "Override a configuration variable, as in " +
+		"-set FramesPerSecond=30. May be given multiple times.")
+}
+
 func main() {
 
 	// Setup
 
 	flag.Parse()
 
-	info := getConfigInfo()
+	if *helpConfigPtr {
+		printConfigHelp()
+		return
+	} else if *initConfigPtr {
+		initConfig()
+		return
+	}
 
-	model, view, controller, mvcErr := mvc.New(info)
-	if mvcErr != nil {
-		error.Report(mvcErr)
+	reporter = crash.New(crash.DefaultDir(os.Environ()))
+	defer reporter.Recover()
+
+	seed := *seedPtr
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	reporter.SetSeed(seed)
+
+	watcher, info, origins := getConfigInfo()
+	reporter.SetConfig(info)
+
+	if *showConfigPtr {
+		printConfigReport(info, origins)
+		return
+	} else if *dumpConfigPtr {
+		dumpConfig(info)
+		return
+	}
+
+	if err := logger.Configure(info); err != nil {
+		error.Report(err)
 		os.Exit(1)
 	}
+	defer logger.Close()
 
-	// Draw starting screen
+	if *replayPtr != "" {
+		playBack(info, origins)
+		return
+	}
 
-	events, err := model.Respond([]mvc.Key{})
-	if err != nil { drawError(model, view, err) }
+	game, savePath := loadGame(info, seed)
+	reporter.SetSeed(game.RNG.Seed())
+	if *recordPtr != "" {
+		startRecording(game)
+	}
 
-	err = view.Draw(model.Map(), model.Player(), events)
-	if err != nil { drawError(model, view, err) }
+	model, view, controller, mvcErr := mvc.New(
+		info, origins, configSources().UserFile(), game, savePath,
+	)
+	if mvcErr != nil {
+		reporter.Crash(mvcErr)
+	}
+	reporter.SetRestore(view.Close)
+
+	if err := save.Consume(savePath, game); err != nil {
+		reporter.Crash(err)
+	}
+
+	// Draw starting screen
+
+	runFrame(model, view, controller, 0)
 
 	// mainloop
 
-	mainloop(model, view, controller, info)
+	mainloop(model, view, controller, watcher, info)
+
+	recordErr := writeRecording(model)
+	saveErr := model.Save()
 
 	model.Close()
 	view.Close()
 	controller.Close()
+
+	if recordErr != nil {
+		error.Report(recordErr)
+	}
+	if saveErr != nil {
+		error.Report(saveErr)
+	}
+	if recordErr != nil || saveErr != nil {
+		os.Exit(1)
+	}
 }
 
-// getConfigInfo returns the the config.Info instance corresponding to the
-// user-provided config file location. If a fatal error occurs, it is reported
-// using the low level tools provided in the error package and the program
-// terminates.
-//
-// TODO: Consider propogating Configuration errors to the the point where they
-// can be reported with the standard mvc apparatus.
-func getConfigInfo() *config.Info {
-	configPath := *configPtr
+// startRecording starts recording game's input for -record. If recording
+// cannot start, the error is reported and the program terminates.
+func startRecording(game *save.Game) {
+	var err *error.Error
+	if recorder, err = replay.NewRecorder(game); err != nil {
+		error.Report(err)
+		os.Exit(1)
+	}
+}
 
-	var (
-		info *config.Info
-		err *error.Error
+// writeRecording writes the replay file for -record, if it was given.
+func writeRecording(model mvc.Model) *error.Error {
+	if recorder == nil {
+		return nil
+	}
+	rep, err := recorder.Finish(model.Game())
+	if err != nil {
+		return err
+	}
+	return replay.Write(*recordPtr, rep)
+}
+
+// playBack plays back the replay file given by -replay, and exits with an
+// error if the game does not end in the recorded state.
+func playBack(info *config.Info, origins config.Origins) {
+	rep, err := replay.Read(*replayPtr)
+	if err != nil {
+		error.Report(err)
+		os.Exit(1)
+	}
+	game, err := rep.Game()
+	if err != nil {
+		error.Report(err)
+		os.Exit(1)
+	}
+	reporter.SetSeed(rep.Seed)
+	replaying = true
+
+	model, view, dudController, mvcErr := mvc.New(
+		info, origins, configSources().UserFile(), game, "",
 	)
+	if mvcErr != nil {
+		reporter.Crash(mvcErr)
+	}
+	dudController.Close()
+	controller := replay.NewController(rep)
 
-	if configPath == "" {
-		info, err = config.Default()
-	} else {
-		info, err = config.Parse(configPath)
+	speed := *replaySpeedPtr
+	if speed <= 0 {
+		view.Close()
+		view = replay.HeadlessView{}
 	}
+	reporter.SetRestore(view.Close)
 
-	if err != nil {
-		if err.Code == error.Sanity || err.Code == error.Library {
-			error.Report(err)
-			os.Exit(1)
-		} else {
+	var delay time.Duration
+	if speed > 0 {
+		delay = time.Duration(float64(frameDuration(info)) / speed)
+	}
+	checkErr := runReplay(rep, model, view, controller, delay)
+
+	model.Close()
+	view.Close()
+	controller.Close()
+
+	if checkErr != nil {
+		error.Report(checkErr)
+		os.Exit(1)
+	}
+	fmt.Printf("Replay of %d frames ended in the recorded state.\n",
+		rep.Frames)
+}
+
+// runReplay plays back every frame of rep, waiting delay between frames,
+// and returns a Sanity error if the game does not end in the recorded state.
+func runReplay(
+	rep *replay.Replay, model mvc.Model, view mvc.View,
+	controller *replay.Controller, delay time.Duration,
+) *error.Error {
+
+	for frame := 0; !controller.Done() && !model.GameOver(); frame++ {
+		runFrame(model, view, controller, frame)
+		time.Sleep(delay)
+	}
+
+	if err := rep.Check(model.Game()); err != nil {
+		return err
+	} else if !controller.Done() {
+		desc := fmt.Sprintf("The game ended after fewer frames than the "+
+			"%d which were recorded.", rep.Frames)
+		return error.New(error.Sanity, desc)
+	}
+	return nil
+}
+
+// loadGame returns the saved game and the path of its save file. A new game
+// started with seed is returned instead if there is no save file, if the
+// player in the saved game has died, or if -new-game was given. If the save
+// file cannot be read, the error is reported and the program terminates.
+func loadGame(info *config.Info, seed int64) (*save.Game, string) {
+	savePath := info.Gameplay.SaveFile
+	if savePath == "" {
+		savePath = save.DefaultPath(os.Environ())
+	}
+
+	// Checking first avoids logging a MissingFile error on every new game.
+	if _, statErr := os.Stat(savePath); statErr == nil && !*newGamePtr {
+		game, err := save.Read(savePath)
+		if err != nil {
 			error.Report(err)
+			fmt.Fprintln(os.Stderr, "Run with -new-game to start a new game "+
+				"instead.")
 			os.Exit(1)
 		}
+		if !game.Dead {
+			log.Infof("Loaded save file '%s' at turn %d.", savePath,
+				game.Turn)
+			return game, savePath
+		}
+		log.Infof("The player in save file '%s' has died.", savePath)
 	}
 
-	return info
+	game, err := save.New(seed, info.Gameplay.Permadeath)
+	if err != nil {
+		error.Report(err)
+		os.Exit(1)
+	}
+	log.Infof("Starting a new game with seed %d.", seed)
+	return game, savePath
+}
+
+// getConfigInfo returns the the config.Info instance given by layering the
+// system and user config files, ROGUE_* environment variables and -set flags
+// on top of the default values, along with where each value came from and a
+// config.Watcher which reloads it when the files change.
+//
+// Configuration errors describe invalid assignments, which config.Load skips
+// while keeping every valid one, so they are printed as warnings and the game
+// starts with the remaining configuration. Any other error is fatal: it is
+// reported using the low level tools provided in the error package and the
+// program terminates.
+func getConfigInfo() (*config.Watcher, *config.Info, config.Origins) {
+	watcher, info, origins, err := config.NewWatcher(configSources())
+
+	if err != nil && err.Code == error.Configuration {
+		fmt.Fprintln(os.Stderr, err.Error())
+		fmt.Fprintln(os.Stderr, "The invalid settings were ignored.")
+	} else if err != nil {
+		error.Report(err)
+		os.Exit(1)
+	}
+
+	return watcher, info, origins
+}
+
+// configSources returns the config.Sources given by the command line flags.
+func configSources() config.Sources {
+	src := config.DefaultSources()
+	src.UserPath = *configPtr
+	src.Overrides = overrides
+	return src
+}
+
+// printConfigReport prints the value of every configuration variable and
+// the layer it was set in, as given by config.Report.
+func printConfigReport(info *config.Info, origins config.Origins) {
+	text, err := config.Report(info, origins)
+	if err != nil {
+		error.Report(err)
+		os.Exit(1)
+	}
+	fmt.Println(text)
+}
+
+// dumpConfig prints the effective configuration in config file format, as
+// given by config.Dump.
+func dumpConfig(info *config.Info) {
+	text, err := config.Dump(info)
+	if err != nil {
+		error.Report(err)
+		os.Exit(1)
+	}
+	fmt.Print(text)
+}
+
+// initConfig writes a starter config file to the location given by -config,
+// or to the user's XDG config directory if -config was not given.
+func initConfig() {
+	configPath := *configPtr
+	if configPath == "" {
+		configPath = config.UserConfigPath(os.Environ())
+	}
+
+	if err := config.WriteTemplate(configPath); err != nil {
+		error.Report(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote starter config file to '%s'.\n", configPath)
+}
+
+// printConfigHelp prints the description of every configuration variable
+// given by config.HelpText.
+func printConfigHelp() {
+	text, err := config.HelpText()
+	if err != nil {
+		error.Report(err)
+		os.Exit(1)
+	}
+	fmt.Println(text)
+}
+
+// handleError applies the error policy to err, which was returned by a call
+// to the Model, View or Controller. retry repeats that call, and is nil if
+// the call must not be repeated, in which case errors whose Action is Retry
+// are shown instead. ok is true if the call eventually succeeded and the
+// rest of the frame can continue.
+//
+// Recoverable errors are passed to the model and the resulting events are
+// drawn. Fatal errors, and errors which occur while handling another error,
+// cause an emergency save followed by a crash report.
+func handleError(
+	model mvc.Model, view mvc.View, err *error.Error,
+	retry func() *error.Error,
+) (ok bool) {
+
+	action := policy.Action(err.Code)
+	if action == mvc.Retry && retry == nil {
+		action = mvc.Show
+	}
+
+	switch action {
+	case mvc.Retry:
+		for i := 0; i < policy.MaxRetries && err != nil; i++ {
+			log.Warningf("Retrying after error: %s", err.Error())
+			err = retry()
+		}
+		if err == nil {
+			return true
+		}
+		fatalError(model, err)
+
+	case mvc.Show:
+		error.Log(err)
+		drawError(model, view, err)
+
+	default:
+		fatalError(model, err)
+	}
+
+	return false
 }
 
 // drawError handles the  boiler plate code associated with drawing the 
 // specified error. If an error occurs during this step, the process is
-// considered a lost cause and terminates after using low-level error reporting
-// tools.
+// considered a lost cause and terminates after writing a crash report.
 func drawError(model mvc.Model, view mvc.View, err *error.Error) {
 	events, err := model.RespondError(err)
 	if err != nil {
-		error.Report(err)
-		os.Exit(1)
+		fatalError(model, err)
 	}
 
 	err = view.Draw(model.Map(), model.Player(), events)
 	if err != nil {
-		error.Report(err)
-		os.Exit(1)
+		fatalError(model, err)
+	}
+}
+
+// fatalError attempts an emergency save and then terminates after writing a
+// crash report for err.
+func fatalError(model mvc.Model, err *error.Error) {
+	if saveErr := model.EmergencySave(); saveErr != nil {
+		log.Errorf("Emergency save failed: %s", saveErr.Error())
+	}
+	// The recording is most useful when the game crashes.
+	if recordErr := writeRecording(model); recordErr != nil {
+		log.Errorf("Could not write recording: %s", recordErr.Error())
 	}
+	reporter.Crash(err)
 }
 
-// mainloop draws a frame at the user specified rate until the game ends.
+// configPollInterval is the time between checks for changes to the config
+// files.
+const configPollInterval = time.Second
+
+// mainloop draws a frame at the user specified rate until the game ends. The
+// config files are polled for changes, and the new configuration is given to
+// the model and view whenever they change.
 func mainloop(
 	model mvc.Model,
 	view mvc.View, 
 	controller mvc.Controller, 
+	watcher *config.Watcher,
 	info *config.Info,
 ) {
 
-	ms := int(1000.0 / float64(info.FramesPerSecond))
-	tick := time.Tick(time.Millisecond * time.Duration(ms))
+	tick := time.NewTicker(frameDuration(info))
+	defer tick.Stop()
+	poll := time.Tick(configPollInterval)
+	frame := 0
 
 	for !model.GameOver() {
 		select {
-		case <-tick:
-			err := model.PauseTasks()
-			if err != nil { drawError(model, view, err) }
+		case <-tick.C:
+			frame++
+			runFrame(model, view, controller, frame)
+
+		case <-poll:
+			newInfo, ok := reloadConfig(model, view, watcher)
+			if ok {
+				info = newInfo
+				reporter.SetConfig(info)
+				tick.Reset(frameDuration(info))
+			}
+		}
+	}	
+}
+
+// runFrame reads input, updates the model and draws a single frame. Errors
+// are handled according to the error policy, and a recoverable error skips
+// the rest of the frame.
+func runFrame(
+	model mvc.Model, view mvc.View, controller mvc.Controller, frame int,
+) {
+	var (
+		keys   []mvc.Key
+		events []event.Event
+	)
+
+	steps := []frameStep{
+		{call: model.PauseTasks},
+		{retry: true, call: func() (err *error.Error) {
+			keys, err = controller.KeysPressed()
+			if err == nil && len(keys) > 0 {
+				reporter.LogInput(fmt.Sprintf("frame %d: %v", frame, keys))
+			}
+			return err
+		}},
+		{call: func() (err *error.Error) {
+			// The recorded keys have already been through the View.
+			if !replaying {
+				keys, err = view.Respond(keys)
+			}
+			return err
+		}},
+		{call: func() (err *error.Error) {
+			events, err = model.Respond(keys)
+			// A Model which returns an error is unchanged, so only frames it
+			// responded to are recorded. Frames which were cut short before
+			// reaching it are not recorded either.
+			if err == nil && recorder != nil {
+				recorder.Record(keys)
+			}
+			return err
+		}},
+		{retry: true, call: func() *error.Error {
+			return view.Draw(model.Map(), model.Player(), events)
+		}},
+	}
 
-			keys, err := controller.KeysPressed()
-			if err != nil { drawError(model, view, err) }
+	// Tasks are resumed even if the frame is cut short.
+	defer func() {
+		if err := model.ResumeTasks(); err != nil {
+			handleError(model, view, err, nil)
+		}
+	}()
 
-			keys, err = view.Respond(keys)
-			if err != nil { drawError(model, view, err) }
+	runSteps(model, view, steps)
+}
 
-			events, err := model.Respond(keys)
-			if err != nil { drawError(model, view, err) }
+// frameStep is a single call made during a frame. Only calls to the
+// terminal backend, which reads keys and draws, set retry: repeating a call
+// to the Model or to View.Respond could apply the same input twice.
+type frameStep struct {
+	call  func() *error.Error
+	retry bool
+}
 
-			err = view.Draw(model.Map(), model.Player(), events)
-			if err != nil { drawError(model, view, err) }
+// runSteps runs each step of a frame in order, handling errors according to
+// the error policy. A recoverable error skips the remaining steps.
+func runSteps(model mvc.Model, view mvc.View, steps []frameStep) {
+	for _, step := range steps {
+		err := step.call()
+		if err == nil {
+			continue
+		}
 
-			err = model.ResumeTasks()
-			if err != nil { drawError(model, view, err) }
+		var retry func() *error.Error
+		if step.retry {
+			retry = step.call
 		}
-	}	
-}
\ No newline at end of file
+		if !handleError(model, view, err, retry) {
+			return
+		}
+	}
+}
+
+// frameDuration returns the time between frames requested by info.
+func frameDuration(info *config.Info) time.Duration {
+	ms := int(1000.0 / float64(info.Display.FramesPerSecond))
+	return time.Millisecond * time.Duration(ms)
+}
+
+// reloadConfig checks the config files for changes and passes the new
+// configuration to the model and view. ok is true if the configuration was
+// replaced.
+//
+// Configuration errors cannot be returned after initialization, so problems
+// with the new config files are shown to the player as a message instead,
+// and the old configuration stays active.
+func reloadConfig(
+	model mvc.Model, view mvc.View, watcher *config.Watcher,
+) (info *config.Info, ok bool) {
+
+	info, origins, changed, err := watcher.Check()
+	if !changed {
+		return nil, false
+	} else if err != nil && err.Code == error.Sanity {
+		fatalError(model, err)
+		return nil, false
+	} else if err != nil {
+		desc := fmt.Sprintf("Config files were not reloaded: %s",
+			err.Description)
+		events := []event.Event{event.Message{Str: desc}}
+		if err = view.Draw(model.Map(), model.Player(), events); err != nil {
+			drawError(model, view, err)
+		}
+		return nil, false
+	}
+
+	log.Infof("Reloaded config files.")
+	steps := []func() *error.Error{
+		func() *error.Error { return logger.Configure(info) },
+		func() *error.Error { return model.Reconfigure(info) },
+		func() *error.Error { return view.Reconfigure(info, origins) },
+	}
+	for _, step := range steps {
+		if err = step(); err != nil {
+			handleError(model, view, err, nil)
+		}
+	}
+	return info, true
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phil-mansfield/rogue/actor"
+	"github.com/phil-mansfield/rogue/config"
+	"github.com/phil-mansfield/rogue/crash"
+	"github.com/phil-mansfield/rogue/error"
+	"github.com/phil-mansfield/rogue/event"
+	"github.com/phil-mansfield/rogue/mvc"
+	"github.com/phil-mansfield/rogue/replay"
+	"github.com/phil-mansfield/rogue/save"
+	"github.com/phil-mansfield/rogue/world"
+)
+
+// testModel is a deterministic Model which fails on chosen calls. A failed
+// call leaves the game unchanged.
+type testModel struct {
+	game  *save.Game
+	turns int64
+
+	pauses, responds int
+	// pauseFails and respondFails give the calls, counted from zero, of
+	// PauseTasks and Respond which fail.
+	pauseFails, respondFails map[int]*error.Error
+}
+
+var _ mvc.Model = &testModel{} // typechecking
+
+func (model *testModel) PauseTasks() *error.Error {
+	model.pauses++
+	return model.pauseFails[model.pauses-1]
+}
+
+func (model *testModel) ResumeTasks() *error.Error { return nil }
+
+func (model *testModel) Respond(keys []mvc.Key) ([]event.Event, *error.Error) {
+	model.responds++
+	if err := model.respondFails[model.responds-1]; err != nil {
+		return nil, err
+	}
+	model.game.Turn++
+	for _, key := range keys {
+		model.game.AddMessage(key.Name)
+	}
+	return nil, nil
+}
+
+func (model *testModel) RespondError(
+	err *error.Error,
+) ([]event.Event, *error.Error) {
+	return nil, nil
+}
+
+func (model *testModel) Save() *error.Error                    { return nil }
+func (model *testModel) EmergencySave() *error.Error           { return nil }
+func (model *testModel) Game() *save.Game                      { return model.game }
+func (model *testModel) Map() world.Map                        { return nil }
+func (model *testModel) Player() actor.Actor                   { return nil }
+func (model *testModel) GameOver() bool                        { return model.game.Turn >= model.turns }
+func (model *testModel) Reconfigure(*config.Info) *error.Error { return nil }
+func (model *testModel) Close()                                {}
+
+// testController presses a different key on every frame.
+type testController struct {
+	calls int
+}
+
+func (controller *testController) KeysPressed() ([]mvc.Key, *error.Error) {
+	controller.calls++
+	return []mvc.Key{{Name: fmt.Sprintf("k%d", controller.calls)}}, nil
+}
+
+func (controller *testController) Close() {}
+
+func TestRecordMainloop(t *testing.T) {
+	reporter = crash.New(t.TempDir())
+	policy = mvc.DefaultPolicy()
+	defer func() { recorder, replaying = nil, false }()
+
+	// The config files are empty, so the watcher never reloads anything.
+	dir := t.TempDir()
+	src := config.Sources{
+		SystemPath: filepath.Join(dir, "system.conf"),
+		UserPath:   filepath.Join(dir, "user.conf"),
+		Overrides:  []string{"Display.FramesPerSecond=1000"},
+	}
+	for _, path := range []string{src.SystemPath, src.UserPath} {
+		if writeErr := os.WriteFile(path, nil, 0644); writeErr != nil {
+			t.Fatalf("Could not write config file: %s", writeErr)
+		}
+	}
+	watcher, info, _, err := config.NewWatcher(src)
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %s", err.Error())
+	}
+
+	tests := []struct {
+		pauseFails, respondFails map[int]*error.Error
+	}{
+		{nil, nil},
+		// Frames cut short before the Model is given input.
+		{map[int]*error.Error{0: error.New(error.Value, "Test."),
+			4: error.New(error.Value, "Test.")}, nil},
+		// Model.Respond fails, which is shown rather than retried.
+		{nil, map[int]*error.Error{0: error.New(error.Library, "Test."),
+			3: error.New(error.Library, "Test.")}},
+	}
+
+	for i, test := range tests {
+		game, err := save.New(int64(i+1), false)
+		if err != nil {
+			t.Fatalf("save.New failed: %s", err.Error())
+		}
+		if recorder, err = replay.NewRecorder(game); err != nil {
+			t.Fatalf("NewRecorder failed: %s", err.Error())
+		}
+		replaying = false
+
+		model := &testModel{
+			game: game, turns: 8,
+			pauseFails: test.pauseFails, respondFails: test.respondFails,
+		}
+		view, controller := replay.HeadlessView{}, &testController{}
+		runFrame(model, view, controller, 0)
+		mainloop(model, view, controller, watcher, info)
+
+		rep, err := recorder.Finish(game)
+		if err != nil {
+			t.Fatalf("Test %d: Finish failed: %s", i, err.Error())
+		}
+		// Failed calls to Respond are not retried, so their keys are lost.
+		dropped := len(test.respondFails)
+		if int64(controller.calls-dropped) != game.Turn {
+			t.Errorf("Test %d: %d frames read keys for %d turns.",
+				i, controller.calls, game.Turn)
+		}
+		if int64(rep.Frames) != game.Turn {
+			t.Errorf("Test %d: recorded %d frames for %d turns.",
+				i, rep.Frames, game.Turn)
+		}
+
+		played, err := rep.Game()
+		if err != nil {
+			t.Fatalf("Test %d: Game failed: %s", i, err.Error())
+		}
+		recorder, replaying = nil, true
+		playModel := &testModel{game: played, turns: 8}
+		err = runReplay(rep, playModel, view, replay.NewController(rep), 0)
+		if err != nil {
+			t.Errorf("Test %d: playback failed: %s", i, err.Error())
+		}
+	}
+}
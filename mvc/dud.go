@@ -4,19 +4,50 @@ import (
 	"fmt"
 
 	"github.com/phil-mansfield/rogue/actor"
+	"github.com/phil-mansfield/rogue/config"
 	"github.com/phil-mansfield/rogue/error"
 	"github.com/phil-mansfield/rogue/event"
+	"github.com/phil-mansfield/rogue/mvc/options"
+	"github.com/phil-mansfield/rogue/save"
 	"github.com/phil-mansfield/rogue/world"
 )
 
+// DudModel keeps all of its state in its save.Game, so that saving and
+// loading restores it exactly. The player dies after dudLifetime turns.
 type DudModel struct {
-	frames int
+	info *config.Info
+	game *save.Game
+	savePath string
 }
 
 type DudController struct {
 }
 
 type DudView struct {
+	info *config.Info
+	origins config.Origins
+	configPath string
+
+	// options is the open options screen, or nil if it is closed.
+	options *options.Screen
+	// redraw is true if the options screen has changed since it was last
+	// drawn.
+	redraw bool
+	// message describes why the options could not be saved, if they could
+	// not.
+	message string
+}
+
+// dudLifetime is the number of turns the DudModel's player lives for.
+const dudLifetime = 40
+
+// optionsKey is the key which opens the options screen.
+const optionsKey = "O"
+
+// dudEditor is a minimal options.LineEditor which appends typed characters
+// and deletes on Backspace.
+type dudEditor struct {
+	str string
 }
 
 // DudModel methods
@@ -30,15 +61,42 @@ func (model *DudModel) ResumeTasks() *error.Error {
 }
 
 func (model *DudModel) Respond([]Key) ([]event.Event, *error.Error) {
-	model.frames += 1
-	return []event.Event{event.Message{fmt.Sprintf("Frame # = %d", model.frames)}}, nil
+	if model.game.Dead {
+		return nil, nil
+	}
+
+	model.game.Turn += 1
+	msg := fmt.Sprintf("Turn # = %d", model.game.Turn)
+	if model.game.Turn >= dudLifetime {
+		model.game.Dead = true
+		msg += ", you die."
+	}
+	model.game.AddMessage(msg)
+	return []event.Event{event.Message{Str: msg}}, nil
 }
 
 func (model *DudModel) RespondError(
 	err *error.Error,
 ) ([]event.Event, *error.Error) {
 	
-	return []event.Event{}, nil
+	return []event.Event{
+		event.RecoverableError{Code: err.Code, Description: err.Description},
+	}, nil
+}
+
+func (model *DudModel) Save() *error.Error {
+	if model.savePath == "" {
+		return nil
+	}
+	return save.Store(model.savePath, model.game)
+}
+
+func (model *DudModel) EmergencySave() *error.Error {
+	return model.Save()
+}
+
+func (model *DudModel) Game() *save.Game {
+	return model.game
 }
 
 func (model *DudModel) Map() world.Map {
@@ -50,7 +108,12 @@ func (model *DudModel) Player() actor.Actor {
 }
 
 func (model *DudModel) GameOver() bool {
-	return model.frames == 40
+	return model.game.Dead
+}
+
+func (model *DudModel) Reconfigure(info *config.Info) *error.Error {
+	model.info = info
+	return nil
 }
 
 func (model *DudModel) Close() {
@@ -71,15 +134,106 @@ func (view *DudView) Draw(
 		switch ev := ev.(type) {
 		case event.Message:
 			fmt.Println(ev.String())
+		case event.RecoverableError:
+			fmt.Println(ev.String())
 		default:
 			return error.New(error.Sanity, "Unknown event type.")
 		}
 	}
+
+	if view.message != "" {
+		fmt.Println(view.message)
+		view.message = ""
+	}
+	if view.options != nil && view.redraw {
+		view.drawOptions()
+		view.redraw = false
+	}
 	return nil
 }
-	
-func (view *DudView) Respond([]Key) ([]Key, *error.Error) {
-	return []Key{}, nil
+
+// drawOptions prints every row of the options screen.
+func (view *DudView) drawOptions() {
+	for _, row := range view.options.Rows() {
+		cursor := " "
+		if row.Selected {
+			cursor = ">"
+		}
+		fmt.Printf("%s %s = %s\n", cursor, row.Name, row.Value)
+	}
+	if msg := view.options.Message(); msg != "" {
+		fmt.Println(msg)
+	}
+}
+
+// Respond passes keys to the options screen while it is open, and opens it
+// when optionsKey is pressed. Every other key is returned for the Model.
+func (view *DudView) Respond(keys []Key) ([]Key, *error.Error) {
+	out := []Key{}
+	for _, key := range keys {
+		var err *error.Error
+		if view.options != nil {
+			err = view.optionsKeyPress(key.Name)
+		} else if key.Name == optionsKey {
+			err = view.openOptions()
+		} else {
+			out = append(out, key)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// openOptions opens the options screen.
+func (view *DudView) openOptions() *error.Error {
+	screen, err := options.New(view.info, view.origins, newDudEditor)
+	if err != nil {
+		return err
+	}
+	view.options, view.redraw = screen, true
+	return nil
+}
+
+// optionsKeyPress passes a key to the options screen. When the screen is
+// closed, any changes are saved to the user config file, and the config
+// files are reloaded by the main loop as usual.
+//
+// Only Sanity errors are returned. The player is told about other problems
+// with saving, and the previous config file is left in place.
+func (view *DudView) optionsKeyPress(key string) *error.Error {
+	done, err := view.options.KeyPress(key)
+	if err != nil {
+		return err
+	}
+	view.redraw = true
+	if !done {
+		return nil
+	}
+
+	screen := view.options
+	view.options = nil
+	if !screen.Changed() {
+		return nil
+	}
+
+	if err = screen.Save(view.configPath); err != nil {
+		if err.Code == error.Sanity {
+			return err
+		}
+		view.message = fmt.Sprintf("Options were not saved: %s",
+			err.Description)
+	}
+	return nil
+}
+
+func (view *DudView) Reconfigure(
+	info *config.Info, origins config.Origins,
+) *error.Error {
+	view.info, view.origins = info, origins
+	return nil
 }
 
 func (view *DudView) Close() { 
@@ -87,6 +241,26 @@ func (view *DudView) Close() {
 }
 
 
+// dudEditor methods
+
+
+func newDudEditor(capacity int) options.LineEditor {
+	return &dudEditor{}
+}
+
+func (edit *dudEditor) SetString(str string) { edit.str = str }
+
+func (edit *dudEditor) String() string { return edit.str }
+
+func (edit *dudEditor) KeyPress(key string) {
+	if key == "Backspace" && len(edit.str) > 0 {
+		edit.str = edit.str[:len(edit.str)-1]
+	} else if len(key) == 1 {
+		edit.str += key
+	}
+}
+
+
 // DudController methods
 
 
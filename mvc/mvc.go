@@ -5,20 +5,41 @@ import (
 	"github.com/phil-mansfield/rogue/config"
 	"github.com/phil-mansfield/rogue/error"
 	"github.com/phil-mansfield/rogue/event"
+	"github.com/phil-mansfield/rogue/save"
 	"github.com/phil-mansfield/rogue/world"
 )
 
 type Model interface {
 	PauseTasks() *error.Error
 	ResumeTasks() *error.Error
+	// Respond updates the game in response to the keys pressed on a frame.
+	// If it returns an error, the game must be unchanged.
 	Respond([]Key) ([]event.Event, *error.Error)
+	// RespondError is called with errors which the game recovers from. The
+	// returned events should include an event.RecoverableError so that the
+	// View can show the error to the player.
 	RespondError(*error.Error) ([]event.Event, *error.Error)
+	// Save saves the game when the player quits. If the player has died in
+	// a permadeath game, the save file is deleted instead. The save file of
+	// a permadeath game is deleted when play starts, so it only survives if
+	// Save or EmergencySave writes it back.
+	Save() *error.Error
+	// EmergencySave saves the game, if possible, before a fatal error ends
+	// it.
+	EmergencySave() *error.Error
+	// Game returns the complete state of the game. The Model's response to
+	// input must depend only on this state, so that replays are exact.
+	Game() *save.Game
 
 	Map() world.Map
 	Player() actor.Actor
 
 	GameOver() bool
 
+	// Reconfigure is called with the new configuration whenever the config
+	// files are reloaded while the game is running.
+	Reconfigure(*config.Info) *error.Error
+
 	Close()
 }
 
@@ -26,6 +47,11 @@ type View interface {
 	Draw(world.Map, actor.Actor, []event.Event) *error.Error
 	Respond([]Key) ([]Key, *error.Error)
 
+	// Reconfigure is called with the new configuration, and the origin of
+	// each of its values, whenever the config files are reloaded while the
+	// game is running.
+	Reconfigure(*config.Info, config.Origins) *error.Error
+
 	Close()
 }
 
@@ -35,9 +61,26 @@ type Controller interface {
 	Close()
 }
 
+// Key is a single key press.
 type Key struct {
+	// Name is the name of the key, as used by the line editor: the
+	// character typed, or a name such as "Enter", "Esc" or "Left".
+	Name string
 }
 
-func New(info *config.Info) (Model, View, Controller, *error.Error) {
-	return &DudModel{}, &DudView{}, &DudController{}, nil
+// New creates the Model, View and Controller for game, which is saved to
+// savePath. An empty savePath disables saving, as when playing back a
+// replay.
+//
+// origins gives the origin of each value in info, as returned by
+// config.Load, and configPath is the user config file which the options
+// screen saves to.
+func New(
+	info *config.Info, origins config.Origins, configPath string,
+	game *save.Game, savePath string,
+) (Model, View, Controller, *error.Error) {
+
+	model := &DudModel{info: info, game: game, savePath: savePath}
+	view := &DudView{info: info, origins: origins, configPath: configPath}
+	return model, view, &DudController{}, nil
 }
\ No newline at end of file
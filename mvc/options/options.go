@@ -0,0 +1,296 @@
+/*Package options implements the in-game options screen, which lets players
+edit every configuration variable without touching config files.
+
+The Screen only tracks state: the View feeds it key presses and draws its
+Rows. Each variable is edited with the control given by its config.Widget:
+
+	Toggle   Enter, Left or Right flips the value.
+	Choice   Left and Right cycle through the choices.
+	Slider   Left and Right step through the range, PgUp and PgDn take
+	         larger steps.
+	Text     Enter opens a LineEditor, Enter again accepts the new value and
+	         Esc discards it.
+
+Up and Down move between variables and Esc closes the screen. Every new value
+is validated with the variable's ConvertFunc, and edited variables are
+recorded as coming from the user config file so that Save writes them there.
+The running game picks up the saved file through its config.Watcher.
+*/
+package options
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/phil-mansfield/rogue/config"
+	"github.com/phil-mansfield/rogue/error"
+)
+
+const (
+	// editorCapacity is the minimum capacity of the LineEditor used for text
+	// variables.
+	editorCapacity = 80
+	// sliderSteps is the number of steps a float slider is divided into.
+	sliderSteps = 20
+	// pageSteps is the number of steps taken by PgUp and PgDn.
+	pageSteps = 10
+)
+
+// LineEditor is a single-line text editor which is given keys one at a time
+// by the Screen.
+type LineEditor interface {
+	SetString(string)
+	String() string
+	// KeyPress handles a key other than Enter and Esc.
+	KeyPress(key string)
+}
+
+// Row is a single line of the options screen.
+type Row struct {
+	Name, Value, Help string
+	Widget            config.Widget
+	Selected, Editing bool
+}
+
+// Screen contains the state of the options screen.
+type Screen struct {
+	info    *config.Info
+	origins config.Origins
+	opts    []config.Option
+
+	newEditor func(capacity int) LineEditor
+	edit      LineEditor
+
+	cursor int
+	// edited lists the variables changed since the last Save, in the order
+	// they were first changed.
+	edited  []string
+	message string
+}
+
+// New creates a Screen which edits a copy of info. origins gives the origin
+// of each of its values, as returned by config.Load. newEditor creates the
+// LineEditor used for text variables; it is called with the largest
+// capacity the variable's value may need.
+//
+// New returns the same Sanity errors as config.Default.
+func New(
+	info *config.Info, origins config.Origins,
+	newEditor func(capacity int) LineEditor,
+) (*Screen, *error.Error) {
+
+	opts, err := config.Options()
+	if err != nil {
+		return nil, err
+	}
+	info, err = config.Copy(info)
+	if err != nil {
+		return nil, err
+	}
+
+	copied := make(config.Origins, len(origins))
+	for key, origin := range origins {
+		copied[key] = origin
+	}
+
+	return &Screen{
+		info: info, origins: copied, opts: opts, newEditor: newEditor,
+	}, nil
+}
+
+// Info returns the configuration as edited on the screen.
+func (s *Screen) Info() *config.Info { return s.info }
+
+// Origins returns the origins of the values in Info.
+func (s *Screen) Origins() config.Origins { return s.origins }
+
+// Changed returns true if any variable has been changed.
+func (s *Screen) Changed() bool { return len(s.edited) > 0 }
+
+// Message returns a description of why the last attempted change was
+// rejected, or the empty string if it was accepted.
+func (s *Screen) Message() string { return s.message }
+
+// Rows returns the lines of the screen in the order they should be drawn.
+func (s *Screen) Rows() []Row {
+	rows := make([]Row, len(s.opts))
+	for i, opt := range s.opts {
+		rows[i] = Row{
+			Name: opt.Name, Value: s.value(opt.Name), Help: opt.Help,
+			Widget: opt.Widget, Selected: i == s.cursor,
+			Editing: i == s.cursor && s.edit != nil,
+		}
+		if rows[i].Editing {
+			rows[i].Value = s.edit.String()
+		}
+	}
+	return rows
+}
+
+// KeyPress updates the screen in response to a key. done is true if the
+// player has closed the screen.
+func (s *Screen) KeyPress(key string) (done bool, err *error.Error) {
+	if s.edit != nil {
+		return false, s.editKeyPress(key)
+	}
+
+	switch key {
+	case "Esc":
+		return true, nil
+	case "Up":
+		s.cursor = (s.cursor + len(s.opts) - 1) % len(s.opts)
+		s.message = ""
+		return false, nil
+	case "Down":
+		s.cursor = (s.cursor + 1) % len(s.opts)
+		s.message = ""
+		return false, nil
+	}
+
+	opt := s.opts[s.cursor]
+	switch opt.Widget.Kind {
+	case config.ToggleWidget:
+		if key == "Enter" || key == "Left" || key == "Right" {
+			value := strconv.FormatBool(s.value(opt.Name) != "true")
+			return false, s.set(opt.Name, value)
+		}
+	case config.ChoiceWidget:
+		if step := stepSize(key); step != 0 {
+			value := cycle(opt.Widget.Choices, s.value(opt.Name), step)
+			return false, s.set(opt.Name, value)
+		}
+	case config.IntSliderWidget, config.FloatSliderWidget:
+		if step := stepSize(key); step != 0 {
+			return false, s.slide(opt, step)
+		}
+	case config.TextWidget:
+		if key == "Enter" {
+			value := s.value(opt.Name)
+			capacity := editorCapacity
+			if len(value) > capacity {
+				capacity = len(value)
+			}
+			s.edit = s.newEditor(capacity)
+			s.edit.SetString(value)
+			s.message = ""
+		}
+	}
+
+	return false, nil
+}
+
+// Save writes the variables changed on the screen since the last Save to the
+// user config file at filePath. The rest of the file is left as it is.
+//
+// Save can return Library and Sanity errors.
+func (s *Screen) Save(filePath string) *error.Error {
+	if err := config.EditUserFile(filePath, s.info, s.edited); err != nil {
+		return err
+	}
+	s.edited = nil
+	return nil
+}
+
+// editKeyPress handles a key while a text variable is being edited.
+func (s *Screen) editKeyPress(key string) *error.Error {
+	switch key {
+	case "Esc":
+		s.edit = nil
+		s.message = ""
+	case "Enter":
+		err := s.set(s.opts[s.cursor].Name, s.edit.String())
+		if err != nil {
+			return err
+		} else if s.message == "" {
+			s.edit = nil
+		}
+	default:
+		s.edit.KeyPress(key)
+	}
+	return nil
+}
+
+// slide moves the value of a slider variable by step steps, clamping it to
+// the slider's range.
+func (s *Screen) slide(opt config.Option, step int) *error.Error {
+	w := opt.Widget
+	val, parseErr := strconv.ParseFloat(s.value(opt.Name), 64)
+	if parseErr != nil {
+		val = w.Min
+	}
+
+	if w.Kind == config.IntSliderWidget {
+		val = math.Max(w.Min, math.Min(w.Max, val+float64(step)))
+		return s.set(opt.Name, strconv.Itoa(int(val)))
+	}
+
+	size := (w.Max - w.Min) / sliderSteps
+	val = math.Max(w.Min, math.Min(w.Max, val+size*float64(step)))
+	return s.set(opt.Name, strconv.FormatFloat(val, 'g', 6, 64))
+}
+
+// set validates and assigns a new value to a variable. Invalid values are
+// reported through Message rather than as errors, since they are the
+// player's mistake. Only Sanity errors are returned.
+func (s *Screen) set(name, value string) *error.Error {
+	if err := config.Set(s.info, name, value); err != nil {
+		if err.Code != error.Value {
+			return err
+		}
+		s.message = err.Description
+		return nil
+	}
+
+	s.message = ""
+	if !s.isEdited(name) {
+		s.edited = append(s.edited, name)
+	}
+	s.origins[name] = config.Origin{
+		Layer: config.UserLayer, Location: "options screen",
+	}
+	return nil
+}
+
+// isEdited returns true if the variable has been changed since the last Save.
+func (s *Screen) isEdited(name string) bool {
+	for _, edited := range s.edited {
+		if edited == name {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the current value of a variable.
+func (s *Screen) value(name string) string {
+	// Names come from config.Options, so Value cannot fail.
+	value, _ := config.Value(s.info, name)
+	return value
+}
+
+// stepSize returns the number of steps a key moves a slider or choice by.
+func stepSize(key string) int {
+	switch key {
+	case "Left":
+		return -1
+	case "Right":
+		return 1
+	case "PgDn":
+		return -pageSteps
+	case "PgUp":
+		return pageSteps
+	}
+	return 0
+}
+
+// cycle returns the choice step places after curr, wrapping around.
+func cycle(choices []string, curr string, step int) string {
+	i := 0
+	for j, choice := range choices {
+		if choice == curr {
+			i = j
+		}
+	}
+	n := len(choices)
+	return choices[((i+step)%n+n)%n]
+}
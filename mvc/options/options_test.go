@@ -0,0 +1,145 @@
+package options
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phil-mansfield/rogue/config"
+)
+
+// fakeEditor is a LineEditor which appends single-character keys and
+// deletes on Backspace.
+type fakeEditor struct {
+	str string
+}
+
+func (edit *fakeEditor) SetString(str string) { edit.str = str }
+func (edit *fakeEditor) String() string       { return edit.str }
+
+func (edit *fakeEditor) KeyPress(key string) {
+	if key == "Backspace" && len(edit.str) > 0 {
+		edit.str = edit.str[:len(edit.str)-1]
+	} else if len(key) == 1 {
+		edit.str += key
+	}
+}
+
+func newFakeEditor(capacity int) LineEditor { return &fakeEditor{} }
+
+// moveTo presses Down until the named variable is selected.
+func moveTo(t *testing.T, s *Screen, name string) {
+	for i := 0; i < len(s.opts); i++ {
+		for _, row := range s.Rows() {
+			if row.Selected && row.Name == name {
+				return
+			}
+		}
+		s.KeyPress("Down")
+	}
+	t.Fatalf("No row named '%s'.", name)
+}
+
+// press presses each key in turn.
+func press(t *testing.T, s *Screen, keys ...string) {
+	for _, key := range keys {
+		if _, err := s.KeyPress(key); err != nil {
+			t.Fatalf("KeyPress(%s) failed: %s", key, err.VerboseError())
+		}
+	}
+}
+
+func TestScreen(t *testing.T) {
+	info, _ := config.Default()
+	origins := config.Origins{}
+	s, err := New(info, origins, newFakeEditor)
+	if err != nil {
+		t.Fatalf("New failed: %s", err.VerboseError())
+	}
+
+	moveTo(t, s, "Display.ShowFrameRate")
+	press(t, s, "Enter")
+	if !s.Info().Display.ShowFrameRate {
+		t.Errorf("Toggle did not change Display.ShowFrameRate.")
+	}
+
+	moveTo(t, s, "Display.Terminal")
+	press(t, s, "Right")
+	if s.Info().Display.Terminal != "gl" {
+		t.Errorf("Display.Terminal = %s, not gl.", s.Info().Display.Terminal)
+	}
+	press(t, s, "Right")
+	if s.Info().Display.Terminal != "curses" {
+		t.Errorf("Choice did not wrap around.")
+	}
+
+	moveTo(t, s, "Display.FramesPerSecond")
+	press(t, s, "Right", "PgUp", "Left")
+	if s.Info().Display.FramesPerSecond != 30 {
+		t.Errorf("Display.FramesPerSecond = %d, not 30.",
+			s.Info().Display.FramesPerSecond)
+	}
+	press(t, s, "PgDn", "PgDn", "PgDn", "PgDn")
+	if s.Info().Display.FramesPerSecond != 1 {
+		t.Errorf("Slider was not clamped: Display.FramesPerSecond = %d.",
+			s.Info().Display.FramesPerSecond)
+	}
+
+	moveTo(t, s, "Display.ScrollSpeed")
+	press(t, s, "Right")
+	if speed := s.Info().Display.ScrollSpeed; speed < 1.49 || speed > 1.5 {
+		t.Errorf("Display.ScrollSpeed = %g, not 1.495.", speed)
+	}
+
+	// Invalid text is rejected and editing continues.
+	moveTo(t, s, "Input.KeyRepeatDelay")
+	press(t, s, "Enter", "Backspace", "Backspace", "x", "Enter")
+	if s.Message() == "" {
+		t.Errorf("Invalid duration was accepted.")
+	} else if rows := s.Rows(); !rowNamed(rows, "Input.KeyRepeatDelay").Editing {
+		t.Errorf("Editing stopped after invalid value.")
+	}
+	press(t, s, "Backspace", "s", "Enter")
+	if s.Message() != "" {
+		t.Errorf("Valid duration was rejected: %s", s.Message())
+	} else if delay := s.Info().Input.KeyRepeatDelay.String(); delay != "2m30s" {
+		t.Errorf("Input.KeyRepeatDelay = %s, not 2m30s.", delay)
+	}
+
+	// Esc discards edits.
+	moveTo(t, s, "FavoriteQuote")
+	press(t, s, "Enter", "!", "Esc")
+	if strings.HasSuffix(s.Info().FavoriteQuote, "!") {
+		t.Errorf("Esc did not discard the edit.")
+	}
+
+	if info.Display.ShowFrameRate || len(origins) != 0 {
+		t.Errorf("Screen modified the original Info or Origins.")
+	}
+
+	if done, _ := s.KeyPress("Esc"); !done {
+		t.Errorf("Esc did not close the screen.")
+	}
+
+	userPath := filepath.Join(t.TempDir(), "config.txt")
+	if err = s.Save(userPath); err != nil {
+		t.Fatalf("Save failed: %s", err.VerboseError())
+	}
+	saved, err := config.Parse(userPath)
+	if err != nil {
+		t.Fatalf("Parse of saved file failed: %s", err.VerboseError())
+	} else if !saved.Display.ShowFrameRate || saved.Display.FramesPerSecond != 1 {
+		t.Errorf("Saved file does not contain edited values.")
+	} else if s.Changed() {
+		t.Errorf("Screen is still changed after Save.")
+	}
+}
+
+func rowNamed(rows []Row, name string) Row {
+	for _, row := range rows {
+		if row.Name == name {
+			return row
+		}
+	}
+	return Row{}
+}
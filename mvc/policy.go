@@ -0,0 +1,60 @@
+package mvc
+
+import (
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// Action is the response to an error returned by the Model, View or
+// Controller during the main loop.
+type Action uint8
+
+const (
+	// Show passes the error to Model.RespondError so that it can be shown to
+	// the player as an event.RecoverableError, and skips the rest of the
+	// frame.
+	Show Action = iota
+	// Retry calls the failed method again, up to Policy.MaxRetries times,
+	// before treating the error as Fatal. It is meant for transient failures
+	// in the terminal backend, so only Controller.KeysPressed and View.Draw
+	// are retried. Errors from other calls, which could apply the same input
+	// twice if repeated, are shown instead.
+	Retry
+	// Fatal makes an emergency save of the game and then crashes.
+	Fatal
+)
+
+// Policy decides how the main loop responds to errors, based on their
+// ErrorCode.
+type Policy struct {
+	Actions    map[error.ErrorCode]Action
+	MaxRetries int
+}
+
+// DefaultPolicy returns the Policy used by the game:
+//
+//	Configuration  Show (config files are only reloaded while running)
+//	Library        Retry
+//	MissingFile    Show
+//	Sanity         Fatal
+//	Value          Show
+func DefaultPolicy() Policy {
+	return Policy{
+		Actions: map[error.ErrorCode]Action{
+			error.Configuration: Show,
+			error.Library:       Retry,
+			error.MissingFile:   Show,
+			error.Sanity:        Fatal,
+			error.Value:         Show,
+		},
+		MaxRetries: 3,
+	}
+}
+
+// Action returns the Action for errors with the given code. Codes which the
+// policy does not mention are Fatal.
+func (policy Policy) Action(code error.ErrorCode) Action {
+	if action, ok := policy.Actions[code]; ok {
+		return action
+	}
+	return Fatal
+}
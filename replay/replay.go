@@ -0,0 +1,378 @@
+/*Package replay records the input of a game so that it can be played back
+exactly, which makes bugs reproducible.
+
+A replay holds the state of the game when recording started, the keys passed
+to Model.Respond on every frame, and a hash of the state of the game when
+recording stopped. Only frames on which the Model was given input are
+recorded, so frames which were cut short by an error before reaching the
+Model are not played back. Since the Model is deterministic given its state and its
+input, playing the keys back from the same starting state must produce the
+same final state, and Check reports a Sanity error if it does not.
+
+Replays are written as text, so that they can be attached to bug reports and
+inspected by hand:
+
+	rogue replay 1
+	seed 1792365571659144835
+	frames 40
+	start UkdTVgEABQBHQU1F...
+	keys 3 "a" "Enter"
+	keys 7 "Left"
+	hash 9f86d081884c7d65...
+
+The keys line gives the frame number followed by the quoted name of each
+key. Frames with no keys are omitted.
+*/
+package replay
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/phil-mansfield/rogue/actor"
+	"github.com/phil-mansfield/rogue/config"
+	"github.com/phil-mansfield/rogue/error"
+	"github.com/phil-mansfield/rogue/event"
+	"github.com/phil-mansfield/rogue/mvc"
+	"github.com/phil-mansfield/rogue/save"
+	"github.com/phil-mansfield/rogue/world"
+)
+
+// FormatVersion is the version of the replay format written by Format.
+const FormatVersion = 1
+
+const header = "rogue replay"
+
+// maxLineLength is the longest line accepted by Parse. The start line holds
+// a whole save file.
+const maxLineLength = 1 << 24
+
+// Frame is the input of a single frame.
+type Frame struct {
+	Index int
+	Keys  []mvc.Key
+}
+
+// Replay is a recorded game.
+type Replay struct {
+	// Seed is the game's seed. It is informational only, since Start holds
+	// the full state of the random number generators.
+	Seed int64
+	// Frames is the number of frames which were recorded. Frames are
+	// numbered from zero.
+	Frames int
+	// Start is the save file encoding of the game when recording started.
+	Start []byte
+	// Input holds every frame with keys, in order.
+	Input []Frame
+	// Hash is the StateHash of the game when recording stopped.
+	Hash string
+}
+
+// StateHash returns a hash of the complete state of game.
+//
+// StateHash can return Sanity errors.
+func StateHash(game *save.Game) (string, *error.Error) {
+	data, err := save.Encode(game)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Recorder records a Replay as a game is played.
+type Recorder struct {
+	replay Replay
+	frame  int
+}
+
+// NewRecorder starts recording game from its current state.
+//
+// NewRecorder can return Sanity errors.
+func NewRecorder(game *save.Game) (*Recorder, *error.Error) {
+	start, err := save.Encode(game)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{replay: Replay{Seed: game.RNG.Seed(), Start: start}}, nil
+}
+
+// Record records the keys passed to Model.Respond on the next frame. It must
+// be called exactly once for every frame on which the Model is given input,
+// even if Model.Respond is retried, and never for other frames.
+func (r *Recorder) Record(keys []mvc.Key) {
+	if len(keys) > 0 {
+		copied := append([]mvc.Key{}, keys...)
+		r.replay.Input = append(r.replay.Input, Frame{r.frame, copied})
+	}
+	r.frame++
+	r.replay.Frames = r.frame
+}
+
+// Finish stops recording and returns the Replay, given the final state of
+// the game.
+//
+// Finish can return Sanity errors.
+func (r *Recorder) Finish(game *save.Game) (*Replay, *error.Error) {
+	hash, err := StateHash(game)
+	if err != nil {
+		return nil, err
+	}
+	r.replay.Hash = hash
+	return &r.replay, nil
+}
+
+// Game returns the game as it was when recording started.
+//
+// Game returns the same errors as save.Decode.
+func (rep *Replay) Game() (*save.Game, *error.Error) {
+	return save.Decode(rep.Start)
+}
+
+// Check compares the final state of a played back game with the state
+// which was recorded.
+//
+// A Sanity error is returned if the states differ, since that means the
+// game is not deterministic.
+func (rep *Replay) Check(game *save.Game) *error.Error {
+	hash, err := StateHash(game)
+	if err != nil {
+		return err
+	}
+	if hash != rep.Hash {
+		desc := fmt.Sprintf("Replay ended in state %s instead of the "+
+			"recorded state %s.", hash, rep.Hash)
+		return error.New(error.Sanity, desc)
+	}
+	return nil
+}
+
+// Format returns the text of the replay file for rep.
+func Format(rep *Replay) string {
+	lines := []string{
+		fmt.Sprintf("%s %d", header, FormatVersion),
+		fmt.Sprintf("seed %d", rep.Seed),
+		fmt.Sprintf("frames %d", rep.Frames),
+		"start " + base64.StdEncoding.EncodeToString(rep.Start),
+	}
+	for _, frame := range rep.Input {
+		fields := []string{"keys", strconv.Itoa(frame.Index)}
+		for _, key := range frame.Keys {
+			fields = append(fields, strconv.Quote(key.Name))
+		}
+		lines = append(lines, strings.Join(fields, " "))
+	}
+	lines = append(lines, "hash "+rep.Hash)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Parse parses the text of a replay file.
+//
+// A Value error is returned if text is not a valid replay.
+func Parse(text string) (*Replay, *error.Error) {
+	rep := &Replay{}
+	seen := make(map[string]bool)
+	sawHeader := false
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(nil, maxLineLength)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if !sawHeader {
+			if line != fmt.Sprintf("%s %d", header, FormatVersion) {
+				desc := fmt.Sprintf("Replay starts with '%s' instead of "+
+					"'%s %d'.", line, header, FormatVersion)
+				return nil, error.New(error.Value, desc)
+			}
+			sawHeader = true
+			continue
+		}
+
+		word, rest, _ := strings.Cut(line, " ")
+		if err := parseLine(rep, word, rest); err != nil {
+			return nil, err.With("line", lineNum)
+		}
+		seen[word] = true
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return nil, error.Wrap(error.Value, scanErr, "Could not read replay.")
+	}
+
+	for _, word := range []string{"frames", "start", "hash"} {
+		if !seen[word] {
+			desc := fmt.Sprintf("Replay has no '%s' line.", word)
+			return nil, error.New(error.Value, desc)
+		}
+	}
+	return rep, nil
+}
+
+// parseLine parses a single line of a replay file, which starts with word,
+// into rep.
+func parseLine(rep *Replay, word, rest string) *error.Error {
+	desc := fmt.Sprintf("Invalid replay '%s' line.", word)
+
+	switch word {
+	case "seed":
+		seed, convErr := strconv.ParseInt(rest, 10, 64)
+		if convErr != nil {
+			return error.Wrap(error.Value, convErr, desc)
+		}
+		rep.Seed = seed
+	case "frames":
+		frames, convErr := strconv.Atoi(rest)
+		if convErr != nil || frames < 0 {
+			return error.New(error.Value, desc+" Expected a frame count.")
+		}
+		rep.Frames = frames
+	case "start":
+		start, decodeErr := base64.StdEncoding.DecodeString(rest)
+		if decodeErr != nil {
+			return error.Wrap(error.Value, decodeErr, desc)
+		}
+		rep.Start = start
+	case "hash":
+		rep.Hash = rest
+	case "keys":
+		return parseKeys(rep, rest)
+	default:
+		desc = fmt.Sprintf("Unknown replay line type '%s'.", word)
+		return error.New(error.Value, desc)
+	}
+	return nil
+}
+
+// parseKeys parses the rest of a keys line and appends it to rep.Input.
+func parseKeys(rep *Replay, rest string) *error.Error {
+	indexStr, rest, _ := strings.Cut(rest, " ")
+	index, convErr := strconv.Atoi(indexStr)
+	if convErr != nil || index < 0 {
+		desc := fmt.Sprintf("Invalid frame number '%s'.", indexStr)
+		return error.New(error.Value, desc)
+	}
+	if n := len(rep.Input); n > 0 && rep.Input[n-1].Index >= index {
+		desc := fmt.Sprintf("Frame %d comes after frame %d.",
+			index, rep.Input[n-1].Index)
+		return error.New(error.Value, desc)
+	}
+
+	frame := Frame{Index: index}
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		quoted, unquoteErr := strconv.QuotedPrefix(rest)
+		if unquoteErr != nil {
+			desc := fmt.Sprintf("Key names must be quoted, but found '%s'.",
+				rest)
+			return error.New(error.Value, desc)
+		}
+		name, _ := strconv.Unquote(quoted)
+		frame.Keys = append(frame.Keys, mvc.Key{Name: name})
+		rest = strings.TrimSpace(rest[len(quoted):])
+	}
+
+	rep.Input = append(rep.Input, frame)
+	return nil
+}
+
+// Read reads the replay file at path.
+//
+// A MissingFile error is returned if the file does not exist, and a Library
+// error if it cannot be read. Otherwise, Read returns the same errors as
+// Parse.
+func Read(path string) (*Replay, *error.Error) {
+	data, readErr := ioutil.ReadFile(path)
+	if os.IsNotExist(readErr) {
+		return nil, error.Wrap(error.MissingFile, readErr, "Replay file "+
+			"does not exist.").With("path", path)
+	} else if readErr != nil {
+		return nil, error.Wrap(error.Library, readErr, "Could not read "+
+			"replay file.").With("path", path)
+	}
+
+	rep, err := Parse(string(data))
+	if err != nil {
+		return nil, err.With("path", path)
+	}
+	return rep, nil
+}
+
+// Write writes rep to the replay file at path.
+//
+// Write can return Library errors.
+func Write(path string, rep *Replay) *error.Error {
+	writeErr := ioutil.WriteFile(path, []byte(Format(rep)), 0644)
+	if writeErr != nil {
+		return error.Wrap(error.Library, writeErr, "Could not write replay "+
+			"file.").With("path", path)
+	}
+	return nil
+}
+
+// Controller is an mvc.Controller which plays back the keys of a Replay,
+// one frame per call to KeysPressed.
+type Controller struct {
+	rep   *Replay
+	frame int
+	next  int
+}
+
+var _ mvc.Controller = &Controller{} // typechecking
+
+// NewController creates a Controller which plays back rep.
+func NewController(rep *Replay) *Controller {
+	return &Controller{rep: rep}
+}
+
+// KeysPressed returns the keys of the next frame.
+func (c *Controller) KeysPressed() ([]mvc.Key, *error.Error) {
+	keys := []mvc.Key{}
+	if c.next < len(c.rep.Input) && c.rep.Input[c.next].Index == c.frame {
+		keys = c.rep.Input[c.next].Keys
+		c.next++
+	}
+	c.frame++
+	return keys, nil
+}
+
+// Done returns true once every recorded frame has been played back.
+func (c *Controller) Done() bool {
+	return c.frame >= c.rep.Frames
+}
+
+// Close does nothing.
+func (c *Controller) Close() {}
+
+// HeadlessView is an mvc.View which draws nothing, for playing back replays
+// as fast as possible.
+type HeadlessView struct{}
+
+var _ mvc.View = HeadlessView{} // typechecking
+
+// Draw does nothing.
+func (HeadlessView) Draw(world.Map, actor.Actor, []event.Event) *error.Error {
+	return nil
+}
+
+// Respond returns keys unchanged.
+func (HeadlessView) Respond(keys []mvc.Key) ([]mvc.Key, *error.Error) {
+	return keys, nil
+}
+
+// Reconfigure does nothing.
+func (HeadlessView) Reconfigure(*config.Info, config.Origins) *error.Error {
+	return nil
+}
+
+// Close does nothing.
+func (HeadlessView) Close() {}
@@ -0,0 +1,114 @@
+package replay
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/phil-mansfield/rogue/error"
+	"github.com/phil-mansfield/rogue/mvc"
+	"github.com/phil-mansfield/rogue/rng"
+	"github.com/phil-mansfield/rogue/save"
+)
+
+// respond is a deterministic stand-in for Model.Respond.
+func respond(game *save.Game, keys []mvc.Key) {
+	game.Turn++
+	for _, key := range keys {
+		game.AddMessage(key.Name)
+		game.RNG.Get(rng.Combat).Uint64()
+	}
+}
+
+func newGame(t *testing.T) *save.Game {
+	game, err := save.New(99, false)
+	if err != nil {
+		t.Fatalf("save.New failed: %s", err.Error())
+	}
+	return game
+}
+
+func TestRecordAndPlayBack(t *testing.T) {
+	input := [][]mvc.Key{
+		{{Name: "a"}},
+		{},
+		{{Name: "Enter"}, {Name: "\""}, {Name: " "}},
+		{},
+		{},
+	}
+
+	game := newGame(t)
+	recorder, err := NewRecorder(game)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %s", err.Error())
+	}
+	for _, keys := range input {
+		recorder.Record(keys)
+		respond(game, keys)
+	}
+	rep, err := recorder.Finish(game)
+	if err != nil {
+		t.Fatalf("Finish failed: %s", err.Error())
+	}
+
+	path := filepath.Join(t.TempDir(), "test.replay")
+	if err := Write(path, rep); err != nil {
+		t.Fatalf("Write failed: %s", err.Error())
+	}
+	read, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read failed: %s", err.Error())
+	} else if !reflect.DeepEqual(read, rep) {
+		t.Errorf("Read replay %+v differs from written replay %+v.", read, rep)
+	}
+
+	played, err := read.Game()
+	if err != nil {
+		t.Fatalf("Game failed: %s", err.Error())
+	}
+	controller := NewController(read)
+	for frame := 0; !controller.Done(); frame++ {
+		keys, _ := controller.KeysPressed()
+		if !reflect.DeepEqual(keys, input[frame]) {
+			t.Errorf("Frame %d: played back %v instead of %v.",
+				frame, keys, input[frame])
+		}
+		respond(played, keys)
+	}
+	if err := read.Check(played); err != nil {
+		t.Errorf("Check failed: %s", err.Error())
+	}
+
+	played.Turn++
+	if err := read.Check(played); err == nil || err.Code != error.Sanity {
+		t.Errorf("Check of a different state gave %v.", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	good := "rogue replay 1\nframes 2\nstart AAAA\nkeys 1 \"a\"\nhash x\n"
+	if _, err := Parse(good); err != nil {
+		t.Fatalf("Parse failed on a valid replay: %s", err.Error())
+	} else if _, err := Parse("\n  \n" + good); err != nil {
+		t.Errorf("Parse failed on leading blank lines: %s", err.Error())
+	}
+
+	tests := []string{
+		"",
+		"rogue replay 2\nframes 2\nstart AAAA\nhash x\n",
+		"rogue replay 1\nstart AAAA\nhash x\n",
+		"rogue replay 1\nframes two\nstart AAAA\nhash x\n",
+		"rogue replay 1\nframes 2\nstart !!!\nhash x\n",
+		"rogue replay 1\nframes 2\nstart AAAA\nkeys 1 a\nhash x\n",
+		"rogue replay 1\nframes 2\nstart AAAA\nkeys 1\nkeys 0\nhash x\n",
+		"rogue replay 1\nframes 2\nstart AAAA\ncolor red\nhash x\n",
+	}
+	for i, text := range tests {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("Test %d: Parse succeeded.", i)
+		} else if err.Code != error.Value {
+			t.Errorf("Test %d: Parse gave %s instead of a Value error.",
+				i, err.Code)
+		}
+	}
+}
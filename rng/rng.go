@@ -0,0 +1,411 @@
+/*Package rng provides reproducible random numbers for everything in the game
+which is random.
+
+An RNG is a xoshiro256** generator. It is fast, has a period of 2^256 - 1,
+and its entire state is four integers, which can be saved with the game and
+restored later to continue the same sequence:
+
+	state := r.State()
+	...
+	r.SetState(state)
+
+A game is started from a single seed, which is split into independent named
+Streams, so that, for example, generating an extra item does not change the
+layout of the next level:
+
+	streams := rng.NewStreams(seed)
+	damage := streams.Get(rng.Combat).Roll(dice)
+
+Dice are written in the usual notation, such as "3d6+2", and parsed with
+ParseDice. RNGs and Streams are not safe for concurrent use.
+*/
+package rng
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/phil-mansfield/rogue/error"
+)
+
+// RNG is a seeded xoshiro256** pseudo-random number generator. It
+// implements math/rand.Source64, so it can also be used with rand.New.
+type RNG struct {
+	s [4]uint64
+}
+
+// State is the complete state of an RNG.
+type State struct {
+	S [4]uint64
+}
+
+// New creates an RNG from seed. The same seed always produces the same
+// sequence.
+func New(seed int64) *RNG {
+	r := &RNG{}
+	r.Seed(seed)
+	return r
+}
+
+// Seed resets the RNG to the start of the sequence given by seed.
+func (r *RNG) Seed(seed int64) {
+	// The state is expanded from the seed with SplitMix64, as recommended by
+	// the authors of xoshiro, which never produces an all-zero state.
+	x := uint64(seed)
+	for i := range r.s {
+		r.s[i] = splitMix64(&x)
+	}
+}
+
+// splitMix64 advances the SplitMix64 generator with state x and returns its
+// next output.
+func splitMix64(x *uint64) uint64 {
+	*x += 0x9e3779b97f4a7c15
+	z := *x
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// State returns the current state of the RNG.
+func (r *RNG) State() State {
+	return State{r.s}
+}
+
+// SetState restores a state returned by State. The RNG then continues with
+// the same sequence the original RNG produced after State was called.
+//
+// A Value error is returned if the state is all zeros, which xoshiro cannot
+// leave. This can only happen if the state was corrupted.
+func (r *RNG) SetState(state State) *error.Error {
+	if state.S == [4]uint64{} {
+		return error.New(error.Value, "RNG state cannot be all zeros.")
+	}
+	r.s = state.S
+	return nil
+}
+
+// Uint64 returns a uniformly distributed 64-bit integer.
+func (r *RNG) Uint64() uint64 {
+	s := &r.s
+	result := bits.RotateLeft64(s[1]*5, 7) * 9
+	t := s[1] << 17
+
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+
+	return result
+}
+
+// Int63 returns a uniformly distributed non-negative int64.
+func (r *RNG) Int63() int64 {
+	return int64(r.Uint64() >> 1)
+}
+
+// Intn returns a uniformly distributed integer in [0, n). It panics if n is
+// not positive.
+func (r *RNG) Intn(n int) int {
+	if n <= 0 {
+		panic(fmt.Sprintf("Intn called with non-positive n = %d.", n))
+	}
+	return int(r.uint64n(uint64(n)))
+}
+
+// uint64n returns a uniformly distributed integer in [0, n) for n > 0, using
+// Lemire's multiply-and-reject method to avoid modulo bias.
+func (r *RNG) uint64n(n uint64) uint64 {
+	hi, lo := bits.Mul64(r.Uint64(), n)
+	if lo < n {
+		threshold := -n % n
+		for lo < threshold {
+			hi, lo = bits.Mul64(r.Uint64(), n)
+		}
+	}
+	return hi
+}
+
+// Range returns a uniformly distributed integer in [low, high]. It panics if
+// high < low.
+func (r *RNG) Range(low, high int) int {
+	if high < low {
+		panic(fmt.Sprintf("Range called with high = %d < low = %d.",
+			high, low))
+	}
+	return low + int(r.uint64n(uint64(high-low)+1))
+}
+
+// Float64 returns a uniformly distributed float64 in [0, 1).
+func (r *RNG) Float64() float64 {
+	return float64(r.Uint64()>>11) * 0x1p-53
+}
+
+// Chance returns true with probability p.
+func (r *RNG) Chance(p float64) bool {
+	return r.Float64() < p
+}
+
+// Shuffle randomizes the order of n elements using swap to exchange the
+// elements with indices i and j.
+func (r *RNG) Shuffle(n int, swap func(i, j int)) {
+	for i := n - 1; i > 0; i-- {
+		swap(i, r.Intn(i+1))
+	}
+}
+
+// Perm returns a random permutation of the integers [0, n).
+func (r *RNG) Perm(n int) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	r.Shuffle(n, func(i, j int) { perm[i], perm[j] = perm[j], perm[i] })
+	return perm
+}
+
+// Shuffle randomizes the order of xs in place.
+func Shuffle[T any](r *RNG, xs []T) {
+	r.Shuffle(len(xs), func(i, j int) { xs[i], xs[j] = xs[j], xs[i] })
+}
+
+// Weighted returns an index into weights chosen with probability
+// proportional to its weight, or -1 if every weight is zero. It panics if a
+// weight is negative.
+func (r *RNG) Weighted(weights []int) int {
+	total := 0
+	for i, w := range weights {
+		if w < 0 {
+			panic(fmt.Sprintf("Weight %d is negative: %d.", i, w))
+		}
+		total += w
+	}
+	if total == 0 {
+		return -1
+	}
+
+	x := r.Intn(total)
+	for i, w := range weights {
+		if x < w {
+			return i
+		}
+		x -= w
+	}
+	panic("Weighted choice ran past the last weight.")
+}
+
+// Choose returns an element of xs chosen with probability proportional to
+// its weight, as given by weight. ok is false if xs is empty or every weight
+// is zero.
+func Choose[T any](r *RNG, xs []T, weight func(T) int) (x T, ok bool) {
+	weights := make([]int, len(xs))
+	for i := range xs {
+		weights[i] = weight(xs[i])
+	}
+	i := r.Weighted(weights)
+	if i < 0 {
+		return x, false
+	}
+	return xs[i], true
+}
+
+// Names of the standard streams.
+const (
+	MapGen = "map"
+	Combat = "combat"
+	Loot   = "loot"
+	AI     = "ai"
+)
+
+// Streams is a set of independent RNGs derived from a single game seed. Each
+// stream is identified by a name and produces the same sequence for the same
+// seed regardless of which other streams exist or how much they are used.
+type Streams struct {
+	seed    int64
+	streams map[string]*RNG
+}
+
+// StreamsState is the complete state of a Streams, in a form which can be
+// saved with encoding/gob or encoding/json.
+type StreamsState struct {
+	Seed    int64
+	Streams map[string]State
+}
+
+// NewStreams creates the Streams for a game started with seed.
+func NewStreams(seed int64) *Streams {
+	return &Streams{seed, make(map[string]*RNG)}
+}
+
+// Seed returns the game seed the streams were derived from.
+func (s *Streams) Seed() int64 { return s.seed }
+
+// Get returns the stream with the given name, creating it if it has not
+// been used before.
+func (s *Streams) Get(name string) *RNG {
+	if r, ok := s.streams[name]; ok {
+		return r
+	}
+	r := New(streamSeed(s.seed, name))
+	s.streams[name] = r
+	return r
+}
+
+// streamSeed returns the seed of the named stream: the game seed mixed with
+// the FNV-1a hash of the name.
+func streamSeed(seed int64, name string) int64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(name); i++ {
+		hash ^= uint64(name[i])
+		hash *= 1099511628211
+	}
+	x := uint64(seed) ^ hash
+	return int64(splitMix64(&x))
+}
+
+// Names returns the names of the streams which have been used, sorted.
+func (s *Streams) Names() []string {
+	names := make([]string, 0, len(s.streams))
+	for name := range s.streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// State returns the current state of every stream.
+func (s *Streams) State() StreamsState {
+	state := StreamsState{s.seed, make(map[string]State, len(s.streams))}
+	for name, r := range s.streams {
+		state.Streams[name] = r.State()
+	}
+	return state
+}
+
+// RestoreStreams recreates the Streams whose state was returned by State.
+//
+// A Value error is returned if the state of any stream is invalid.
+func RestoreStreams(state StreamsState) (*Streams, *error.Error) {
+	s := NewStreams(state.Seed)
+	for name, rState := range state.Streams {
+		r := &RNG{}
+		if err := r.SetState(rState); err != nil {
+			desc := fmt.Sprintf("Could not restore RNG stream '%s'.", name)
+			return nil, error.Wrap(error.Value, err, desc)
+		}
+		s.streams[name] = r
+	}
+	return s, nil
+}
+
+// maxDice is the largest number of dice or sides allowed in dice notation,
+// which keeps every roll well within the range of an int.
+const maxDice = 10000
+
+// Dice describes a roll of Count dice with Sides sides each, plus Modifier.
+type Dice struct {
+	Count, Sides, Modifier int
+}
+
+// ParseDice parses dice notation: an optional number of dice, 'd', the
+// number of sides, and an optional "+N" or "-N" modifier, as in "3d6+2",
+// "d20" or "2d4-1". A plain integer, such as "5", is a constant.
+//
+// A Value error is returned if str is not valid dice notation.
+func ParseDice(str string) (Dice, *error.Error) {
+	s := strings.ToLower(strings.Join(strings.Fields(str), ""))
+
+	invalid := func(reason string) (Dice, *error.Error) {
+		desc := fmt.Sprintf("'%s' is not valid dice notation: %s", str, reason)
+		return Dice{}, error.New(error.Value, desc)
+	}
+
+	var d Dice
+	dIndex := strings.IndexByte(s, 'd')
+	if dIndex < 0 {
+		mod, err := strconv.Atoi(s)
+		if err != nil {
+			return invalid("expected a form like '3d6+2'.")
+		}
+		d.Modifier = mod
+		return d, nil
+	}
+
+	d.Count = 1
+	if dIndex > 0 {
+		count, err := strconv.Atoi(s[:dIndex])
+		if err != nil || count < 0 {
+			return invalid("the number of dice must be a non-negative integer.")
+		}
+		d.Count = count
+	}
+
+	rest := s[dIndex+1:]
+	modIndex := strings.IndexAny(rest, "+-")
+	sidesStr := rest
+	if modIndex >= 0 {
+		sidesStr = rest[:modIndex]
+		mod, err := strconv.Atoi(rest[modIndex:])
+		if err != nil {
+			return invalid("the modifier must be an integer.")
+		}
+		d.Modifier = mod
+	}
+
+	sides, err := strconv.Atoi(sidesStr)
+	if err != nil || sides < 1 {
+		return invalid("the number of sides must be a positive integer.")
+	}
+	d.Sides = sides
+
+	if d.Count > maxDice || d.Sides > maxDice {
+		return invalid(fmt.Sprintf("at most %d dice with at most %d "+
+			"sides are allowed.", maxDice, maxDice))
+	}
+	return d, nil
+}
+
+// String returns the dice in dice notation.
+func (d Dice) String() string {
+	if d.Count == 0 || d.Sides == 0 {
+		return strconv.Itoa(d.Modifier)
+	}
+
+	str := fmt.Sprintf("%dd%d", d.Count, d.Sides)
+	if d.Modifier > 0 {
+		str += fmt.Sprintf("+%d", d.Modifier)
+	} else if d.Modifier < 0 {
+		str += fmt.Sprintf("%d", d.Modifier)
+	}
+	return str
+}
+
+// Min returns the smallest possible roll.
+func (d Dice) Min() int {
+	if d.Sides == 0 {
+		return d.Modifier
+	}
+	return d.Count + d.Modifier
+}
+
+// Max returns the largest possible roll.
+func (d Dice) Max() int {
+	return d.Count*d.Sides + d.Modifier
+}
+
+// Roll rolls the dice.
+func (r *RNG) Roll(d Dice) int {
+	total := d.Modifier
+	if d.Sides == 0 {
+		return total
+	}
+	for i := 0; i < d.Count; i++ {
+		total += 1 + r.Intn(d.Sides)
+	}
+	return total
+}
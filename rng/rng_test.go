@@ -0,0 +1,187 @@
+package rng
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+var _ rand.Source64 = new(RNG) // typechecking
+
+func TestRNG(t *testing.T) {
+	// The first output of xoshiro256** from the state {1, 2, 3, 4}.
+	r := &RNG{}
+	r.SetState(State{[4]uint64{1, 2, 3, 4}})
+	if x := r.Uint64(); x != 11520 {
+		t.Errorf("First output from {1, 2, 3, 4} was %d instead of 11520.", x)
+	}
+
+	a, b := New(42), New(42)
+	for i := 0; i < 100; i++ {
+		if x, y := a.Uint64(), b.Uint64(); x != y {
+			t.Fatalf("RNGs with the same seed differ at output %d.", i)
+		}
+	}
+
+	state := a.State()
+	want := []int{a.Intn(100), a.Intn(100), a.Intn(100)}
+	restored := New(0)
+	if err := restored.SetState(state); err != nil {
+		t.Fatalf("SetState failed: %s", err.Error())
+	}
+	got := []int{restored.Intn(100), restored.Intn(100), restored.Intn(100)}
+	if !slices.Equal(got, want) {
+		t.Errorf("Restored RNG produced %v instead of %v.", got, want)
+	}
+
+	if err := restored.SetState(State{}); err == nil {
+		t.Errorf("SetState accepted an all-zero state.")
+	}
+
+	for i := 0; i < 1000; i++ {
+		if x := a.Range(-3, 3); x < -3 || x > 3 {
+			t.Fatalf("Range(-3, 3) = %d.", x)
+		}
+		if f := a.Float64(); f < 0 || f >= 1 {
+			t.Fatalf("Float64() = %g.", f)
+		}
+	}
+}
+
+func TestStreams(t *testing.T) {
+	a, b := NewStreams(7), NewStreams(7)
+
+	// Using streams in a different order, or using extra streams, doesn't
+	// change what the other streams produce.
+	a.Get(Loot).Uint64()
+	x := a.Get(Combat).Uint64()
+	b.Get(MapGen).Uint64()
+	if y := b.Get(Combat).Uint64(); x != y {
+		t.Errorf("Combat stream depends on the use of other streams.")
+	}
+	if a.Get(Loot).State() == a.Get(MapGen).State() {
+		t.Errorf("Loot and map streams have the same state.")
+	}
+
+	if names := a.Names(); !slices.Equal(names, []string{Combat, Loot, MapGen}) {
+		t.Errorf("Names() = %v.", names)
+	}
+
+	restored, err := RestoreStreams(a.State())
+	if err != nil {
+		t.Fatalf("RestoreStreams failed: %s", err.Error())
+	}
+	if restored.Seed() != 7 {
+		t.Errorf("Restored seed is %d instead of 7.", restored.Seed())
+	}
+	for _, name := range []string{Combat, Loot, MapGen, AI} {
+		if x, y := a.Get(name).Uint64(), restored.Get(name).Uint64(); x != y {
+			t.Errorf("Restored stream '%s' differs from the original.", name)
+		}
+	}
+
+	bad := a.State()
+	bad.Streams[AI] = State{}
+	if _, err := RestoreStreams(bad); err == nil {
+		t.Errorf("RestoreStreams accepted an all-zero state.")
+	}
+}
+
+func TestParseDice(t *testing.T) {
+	tests := []struct {
+		str   string
+		dice  Dice
+		valid bool
+		norm  string
+	}{
+		{"3d6+2", Dice{3, 6, 2}, true, "3d6+2"},
+		{"d20", Dice{1, 20, 0}, true, "1d20"},
+		{"2D4 - 1", Dice{2, 4, -1}, true, "2d4-1"},
+		{"5", Dice{0, 0, 5}, true, "5"},
+		{"-2", Dice{0, 0, -2}, true, "-2"},
+		{"0d6+1", Dice{0, 6, 1}, true, "1"},
+		{"", Dice{}, false, ""},
+		{"d", Dice{}, false, ""},
+		{"3d", Dice{}, false, ""},
+		{"3d0", Dice{}, false, ""},
+		{"-1d6", Dice{}, false, ""},
+		{"3d6+", Dice{}, false, ""},
+		{"3d6+-2", Dice{}, false, ""},
+		{"3d6+2+1", Dice{}, false, ""},
+		{"xd6", Dice{}, false, ""},
+		{"3d6x", Dice{}, false, ""},
+		{"100000d6", Dice{}, false, ""},
+	}
+
+	for i, test := range tests {
+		dice, err := ParseDice(test.str)
+		if (err == nil) != test.valid {
+			t.Errorf("Test %d: ParseDice('%s') gave error %v.", i, test.str, err)
+		} else if test.valid && (dice != test.dice || dice.String() != test.norm) {
+			t.Errorf("Test %d: ParseDice('%s') = %+v (%s) instead of %+v (%s).",
+				i, test.str, dice, dice, test.dice, test.norm)
+		}
+	}
+}
+
+func TestRoll(t *testing.T) {
+	r := New(1)
+	d := Dice{3, 6, 2}
+	seen := make(map[int]bool)
+	for i := 0; i < 10000; i++ {
+		x := r.Roll(d)
+		if x < d.Min() || x > d.Max() {
+			t.Fatalf("Roll(%s) = %d, outside [%d, %d].", d, x, d.Min(), d.Max())
+		}
+		seen[x] = true
+	}
+	if len(seen) != d.Max()-d.Min()+1 {
+		t.Errorf("Only %d of the possible rolls of %s occurred.", len(seen), d)
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	r := New(1)
+	counts := make([]int, 4)
+	for i := 0; i < 10000; i++ {
+		counts[r.Weighted([]int{1, 0, 3, 0})]++
+	}
+	if counts[1] != 0 || counts[3] != 0 {
+		t.Errorf("Zero-weight indices were chosen: %v.", counts)
+	} else if counts[2] < 2*counts[0] {
+		t.Errorf("Weight 3 was chosen %d times and weight 1 %d times.",
+			counts[2], counts[0])
+	}
+
+	if i := r.Weighted([]int{0, 0}); i != -1 {
+		t.Errorf("Weighted with zero total returned %d.", i)
+	}
+
+	x, ok := Choose(r, []string{"a", "b"}, func(s string) int {
+		if s == "b" {
+			return 1
+		}
+		return 0
+	})
+	if !ok || x != "b" {
+		t.Errorf("Choose() = %s, %v instead of b, true.", x, ok)
+	}
+	if _, ok := Choose(r, []string{}, func(string) int { return 1 }); ok {
+		t.Errorf("Choose from an empty slice succeeded.")
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	r := New(3)
+	xs := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	Shuffle(r, xs)
+	if slices.Equal(xs, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Errorf("Shuffle left the slice in order.")
+	}
+	slices.Sort(xs)
+	perm := r.Perm(10)
+	slices.Sort(perm)
+	if !slices.Equal(xs, perm) || perm[0] != 0 || perm[9] != 9 {
+		t.Errorf("Shuffle or Perm did not produce a permutation.")
+	}
+}
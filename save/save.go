@@ -0,0 +1,547 @@
+/*Package save writes the state of a game to a save file and reads it back.
+
+A save file is a versioned binary file made of tagged sections, one for each
+part of the game state, followed by a CRC-32 (IEEE) checksum of everything
+before it. All values are little-endian:
+
+	header:   magic [4]byte, version uint16, count uint16
+	section:  tag [4]byte, length uint32, data [length]byte
+	trailer:  checksum uint32
+
+The sections written by the current version are:
+
+	GAME  turn int64, flags uint8 (1 = permadeath, 2 = dead)
+	ITEM  the item.ListBuffer, as encoded by ListBuffer.Marshal
+	IDNT  the item.Knowledge, as encoded by Knowledge.Marshal
+	RNGS  seed int64, count uint16, then for each stream:
+	      length uint16, name [length]byte, state [4]uint64
+	MSGS  count uint32, then for each message: length uint32, text
+
+Whenever the layout changes, FormatVersion must be incremented and a
+Migration which converts the sections of the previous version must be added
+to migrations, so that older save files can still be loaded. Sections with
+unknown tags are ignored.
+
+Maps, actors and the turn scheduler will get sections of their own once they
+exist.
+*/
+package save
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/phil-mansfield/rogue/error"
+	"github.com/phil-mansfield/rogue/item"
+	"github.com/phil-mansfield/rogue/rng"
+)
+
+const (
+	// FormatVersion is the version of the save file format written by
+	// Encode.
+	FormatVersion = 1
+	// MessageHistory is the number of messages kept in a Game's log.
+	MessageHistory = 200
+
+	headerSize        = 4 + 2 + 2
+	sectionHeaderSize = 4 + 4
+	checksumSize      = 4
+	// maxValueSize is the size of the largest fixed-size value in a
+	// section.
+	maxValueSize = 8
+
+	identifyStream = "identify"
+)
+
+var magic = [4]byte{'R', 'G', 'S', 'V'}
+
+// Section tags.
+const (
+	gameTag      = "GAME"
+	itemTag      = "ITEM"
+	knowledgeTag = "IDNT"
+	rngTag       = "RNGS"
+	messageTag   = "MSGS"
+)
+
+const (
+	permadeathFlag = 1 << iota
+	deadFlag
+)
+
+// Game is the complete state of a game.
+type Game struct {
+	// Turn is the number of turns which have been taken.
+	Turn int64
+	// Permadeath is true if the save file is deleted when the player dies.
+	Permadeath bool
+	// Dead is true once the player has died.
+	Dead bool
+
+	Items     *item.ListBuffer
+	Knowledge *item.Knowledge
+	RNG       *rng.Streams
+	// Messages is the message log, oldest first.
+	Messages []string
+}
+
+// New creates the state of a new game started with seed.
+//
+// New can return Sanity errors.
+func New(seed int64, permadeath bool) (*Game, *error.Error) {
+	streams := rng.NewStreams(seed)
+	knowledge, err := item.NewKnowledge(streams.Get(identifyStream).Int63())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Game{
+		Permadeath: permadeath,
+		Items:      item.New(),
+		Knowledge:  knowledge,
+		RNG:        streams,
+		Messages:   []string{},
+	}, nil
+}
+
+// AddMessage appends msg to the message log, dropping the oldest message if
+// the log holds more than MessageHistory messages.
+func (g *Game) AddMessage(msg string) {
+	g.Messages = append(g.Messages, msg)
+	if extra := len(g.Messages) - MessageHistory; extra > 0 {
+		g.Messages = append(g.Messages[:0], g.Messages[extra:]...)
+	}
+}
+
+// Migration converts the sections of a save file from one version to the
+// next, in place. sections maps each section's tag to its data.
+type Migration func(sections map[string][]byte) *error.Error
+
+var (
+	// migrations[i] converts a save file from version i + 1 to version
+	// i + 2.
+	migrations = []Migration{}
+	// currentVersion is the version written by Encode. It is a variable so
+	// that tests can add versions.
+	currentVersion uint16 = FormatVersion
+)
+
+// Encode returns the save file encoding of g.
+//
+// A Sanity error is returned if the item buffer is inconsistent.
+func Encode(g *Game) ([]byte, *error.Error) {
+	items, err := g.Items.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	sections := map[string][]byte{
+		gameTag:      encodeGame(g),
+		itemTag:      items,
+		knowledgeTag: g.Knowledge.Marshal(),
+		rngTag:       encodeStreams(g.RNG.State()),
+		messageTag:   encodeMessages(g.Messages),
+	}
+	return encodeSections(currentVersion, sections), nil
+}
+
+// encodeSections returns a save file with the given version containing
+// sections, in order of their tags.
+func encodeSections(version uint16, sections map[string][]byte) []byte {
+	tags := make([]string, 0, len(sections))
+	size := headerSize + checksumSize
+	for tag, data := range sections {
+		tags = append(tags, tag)
+		size += sectionHeaderSize + len(data)
+	}
+	sort.Strings(tags)
+
+	data := make([]byte, headerSize, size)
+	copy(data[0:4], magic[:])
+	binary.LittleEndian.PutUint16(data[4:6], version)
+	binary.LittleEndian.PutUint16(data[6:8], uint16(len(tags)))
+
+	for _, tag := range tags {
+		data = append(data, tag...)
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(sections[tag])))
+		data = append(data, sections[tag]...)
+	}
+
+	return binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+}
+
+func encodeGame(g *Game) []byte {
+	data := binary.LittleEndian.AppendUint64(nil, uint64(g.Turn))
+	flags := byte(0)
+	if g.Permadeath {
+		flags |= permadeathFlag
+	}
+	if g.Dead {
+		flags |= deadFlag
+	}
+	return append(data, flags)
+}
+
+func encodeStreams(state rng.StreamsState) []byte {
+	names := make([]string, 0, len(state.Streams))
+	for name := range state.Streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data := binary.LittleEndian.AppendUint64(nil, uint64(state.Seed))
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(names)))
+	for _, name := range names {
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(name)))
+		data = append(data, name...)
+		for _, x := range state.Streams[name].S {
+			data = binary.LittleEndian.AppendUint64(data, x)
+		}
+	}
+	return data
+}
+
+func encodeMessages(messages []string) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len(messages)))
+	for _, msg := range messages {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(msg)))
+		data = append(data, msg...)
+	}
+	return data
+}
+
+// Decode returns the Game encoded in data. Save files written by older
+// versions are migrated to the current version first.
+//
+// A Value error is returned if data is truncated, has the wrong magic
+// number, was written by an unsupported version, has an invalid checksum,
+// is missing a section, or contains invalid game state. A Sanity error is
+// returned if a migration is missing.
+func Decode(data []byte) (*Game, *error.Error) {
+	version, sections, err := decodeSections(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(migrations) != int(currentVersion)-1 {
+		desc := fmt.Sprintf("There are %d save file migrations, but the "+
+			"format version is %d.", len(migrations), currentVersion)
+		return nil, error.New(error.Sanity, desc)
+	}
+	for v := version; v < currentVersion; v++ {
+		if err = migrations[v-1](sections); err != nil {
+			desc := fmt.Sprintf("Could not migrate save file from version "+
+				"%d to %d.", v, v+1)
+			return nil, error.Wrap(error.Value, err, desc)
+		}
+	}
+
+	for _, tag := range []string{
+		gameTag, itemTag, knowledgeTag, rngTag, messageTag,
+	} {
+		if _, ok := sections[tag]; !ok {
+			desc := fmt.Sprintf("Save file has no '%s' section.", tag)
+			return nil, error.New(error.Value, desc)
+		}
+	}
+
+	g := &Game{Items: &item.ListBuffer{}, Knowledge: &item.Knowledge{}}
+	if err = decodeGame(sections[gameTag], g); err != nil {
+		return nil, err
+	} else if err = g.Items.Unmarshal(sections[itemTag]); err != nil {
+		return nil, error.Wrap(error.Value, err, "Could not read items.")
+	} else if err = g.Knowledge.Unmarshal(sections[knowledgeTag]); err != nil {
+		return nil, error.Wrap(error.Value, err, "Could not read knowledge.")
+	}
+
+	state, err := decodeStreams(sections[rngTag])
+	if err != nil {
+		return nil, err
+	}
+	if g.RNG, err = rng.RestoreStreams(state); err != nil {
+		return nil, err
+	}
+
+	if g.Messages, err = decodeMessages(sections[messageTag]); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// decodeSections splits a save file into its version and sections.
+func decodeSections(
+	data []byte,
+) (version uint16, sections map[string][]byte, err *error.Error) {
+
+	if len(data) < headerSize+checksumSize {
+		desc := fmt.Sprintf("Save file has length %d, which is too short "+
+			"for a header.", len(data))
+		return 0, nil, error.New(error.Value, desc)
+	}
+
+	var fileMagic [4]byte
+	copy(fileMagic[:], data[0:4])
+	if fileMagic != magic {
+		desc := fmt.Sprintf("Save file has bad magic %q.", fileMagic[:])
+		return 0, nil, error.New(error.Value, desc)
+	}
+
+	version = binary.LittleEndian.Uint16(data[4:6])
+	if version == 0 || version > currentVersion {
+		desc := fmt.Sprintf("Save file has version %d, but only versions "+
+			"up to %d are supported.", version, currentVersion)
+		return 0, nil, error.New(error.Value, desc)
+	}
+
+	body := data[:len(data)-checksumSize]
+	if binary.LittleEndian.Uint32(data[len(body):]) != crc32.ChecksumIEEE(body) {
+		return 0, nil, error.New(error.Value, "Save file has bad checksum.")
+	}
+
+	count := int(binary.LittleEndian.Uint16(data[6:8]))
+	sections = make(map[string][]byte, count)
+	rest := body[headerSize:]
+	for i := 0; i < count; i++ {
+		if len(rest) < sectionHeaderSize {
+			return 0, nil, error.New(error.Value, "Save file is truncated.")
+		}
+		tag := string(rest[0:4])
+		n := binary.LittleEndian.Uint32(rest[4:8])
+		rest = rest[sectionHeaderSize:]
+		if uint64(len(rest)) < uint64(n) {
+			desc := fmt.Sprintf("Save file section '%s' is truncated.", tag)
+			return 0, nil, error.New(error.Value, desc)
+		}
+		sections[tag], rest = rest[:n], rest[n:]
+	}
+
+	if len(rest) != 0 {
+		desc := fmt.Sprintf("Save file has %d bytes after its last section.",
+			len(rest))
+		return 0, nil, error.New(error.Value, desc)
+	}
+	return version, sections, nil
+}
+
+func decodeGame(data []byte, g *Game) *error.Error {
+	if len(data) != 9 {
+		desc := fmt.Sprintf("Save file '%s' section has length %d instead "+
+			"of 9.", gameTag, len(data))
+		return error.New(error.Value, desc)
+	}
+	g.Turn = int64(binary.LittleEndian.Uint64(data[0:8]))
+	g.Permadeath = data[8]&permadeathFlag != 0
+	g.Dead = data[8]&deadFlag != 0
+	return nil
+}
+
+// reader reads little-endian values from a section, remembering whether it
+// ran out of data.
+type reader struct {
+	data      []byte
+	truncated bool
+}
+
+// bytes returns the next n bytes of the section. If fewer than n are left,
+// the reader is marked as truncated and zeroes are returned. n may come from
+// a corrupt length, so no more zeroes are allocated than a fixed-size value
+// needs.
+func (r *reader) bytes(n int) []byte {
+	if r.truncated || n < 0 || len(r.data) < n {
+		r.truncated = true
+		if n < 0 || n > maxValueSize {
+			n = maxValueSize
+		}
+		return make([]byte, n)
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) uint16() uint16 { return binary.LittleEndian.Uint16(r.bytes(2)) }
+func (r *reader) uint32() uint32 { return binary.LittleEndian.Uint32(r.bytes(4)) }
+func (r *reader) uint64() uint64 { return binary.LittleEndian.Uint64(r.bytes(8)) }
+
+// check returns a Value error if the section was truncated or has data left
+// over.
+func (r *reader) check(tag string) *error.Error {
+	if r.truncated {
+		desc := fmt.Sprintf("Save file section '%s' is truncated.", tag)
+		return error.New(error.Value, desc)
+	} else if len(r.data) != 0 {
+		desc := fmt.Sprintf("Save file section '%s' has %d extra bytes.",
+			tag, len(r.data))
+		return error.New(error.Value, desc)
+	}
+	return nil
+}
+
+func decodeStreams(data []byte) (rng.StreamsState, *error.Error) {
+	r := &reader{data: data}
+	state := rng.StreamsState{
+		Seed:    int64(r.uint64()),
+		Streams: make(map[string]rng.State),
+	}
+
+	n := int(r.uint16())
+	for i := 0; i < n && !r.truncated; i++ {
+		name := string(r.bytes(int(r.uint16())))
+		var s rng.State
+		for j := range s.S {
+			s.S[j] = r.uint64()
+		}
+		state.Streams[name] = s
+	}
+
+	return state, r.check(rngTag)
+}
+
+func decodeMessages(data []byte) ([]string, *error.Error) {
+	r := &reader{data: data}
+	n := int(r.uint32())
+	if n > MessageHistory {
+		desc := fmt.Sprintf("Save file has %d messages, but at most %d are "+
+			"kept.", n, MessageHistory)
+		return nil, error.New(error.Value, desc)
+	}
+
+	messages := make([]string, 0, n)
+	for i := 0; i < n && !r.truncated; i++ {
+		messages = append(messages, string(r.bytes(int(r.uint32()))))
+	}
+
+	return messages, r.check(messageTag)
+}
+
+// DefaultPath returns the save file used when none is configured, given the
+// environment env: rogue/game.sav in $XDG_STATE_HOME, in $HOME/.local/state
+// if it is not set, or in the system temporary directory if neither is.
+func DefaultPath(env []string) string {
+	dir := lookupEnv(env, "XDG_STATE_HOME")
+	if dir == "" {
+		if home := lookupEnv(env, "HOME"); home != "" {
+			dir = filepath.Join(home, ".local", "state")
+		} else {
+			dir = os.TempDir()
+		}
+	}
+	return filepath.Join(dir, "rogue", "game.sav")
+}
+
+// lookupEnv returns the value of the variable key in env, or the empty
+// string if it is not set.
+func lookupEnv(env []string, key string) string {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			return kv[len(key)+1:]
+		}
+	}
+	return ""
+}
+
+// Read reads the Game saved at path.
+//
+// A MissingFile error is returned if there is no save file, and a Library
+// error if it cannot be read. Otherwise, Read returns the same errors as
+// Decode, with the path attached.
+func Read(path string) (*Game, *error.Error) {
+	data, readErr := ioutil.ReadFile(path)
+	if os.IsNotExist(readErr) {
+		return nil, error.Wrap(error.MissingFile, readErr, "There is no "+
+			"save file.").With("path", path)
+	} else if readErr != nil {
+		return nil, error.Wrap(error.Library, readErr, "Could not read "+
+			"save file.").With("path", path)
+	}
+
+	g, err := Decode(data)
+	if err != nil {
+		return nil, err.With("path", path)
+	}
+	return g, nil
+}
+
+// Consume deletes the save file at path if g is a permadeath game. It is
+// called once a loaded game starts being played, so that killing the game
+// before the player dies and loading the save again does not undo the death.
+// The game is written back when the player quits or by an emergency save.
+//
+// Consume can return Library errors.
+func Consume(path string, g *Game) *error.Error {
+	if !g.Permadeath {
+		return nil
+	}
+	return Delete(path)
+}
+
+// Write saves g to path, replacing any previous save. The file is written
+// to a temporary file first, so a crash part way through leaves the old
+// save intact.
+//
+// Write can return Library and Sanity errors.
+func Write(path string, g *Game) *error.Error {
+	data, err := Encode(g)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if mkErr := os.MkdirAll(dir, 0755); mkErr != nil {
+		return error.Wrap(error.Library, mkErr, "Could not create save "+
+			"directory.").With("path", dir)
+	}
+
+	tmp, tmpErr := ioutil.TempFile(dir, ".save-")
+	if tmpErr != nil {
+		return error.Wrap(error.Library, tmpErr, "Could not create "+
+			"temporary save file.").With("path", dir)
+	}
+
+	_, writeErr := tmp.Write(data)
+	if syncErr := tmp.Sync(); writeErr == nil {
+		writeErr = syncErr
+	}
+	if closeErr := tmp.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr == nil {
+		writeErr = os.Rename(tmp.Name(), path)
+	}
+	if writeErr != nil {
+		os.Remove(tmp.Name())
+		return error.Wrap(error.Library, writeErr, "Could not write save "+
+			"file.").With("path", path)
+	}
+
+	return nil
+}
+
+// Store saves g to path, unless the player has died in a permadeath game,
+// in which case the save file is deleted instead.
+//
+// Store can return Library and Sanity errors.
+func Store(path string, g *Game) *error.Error {
+	if g.Permadeath && g.Dead {
+		return Delete(path)
+	}
+	return Write(path, g)
+}
+
+// Delete deletes the save file at path. It is not an error for there to be
+// no save file.
+//
+// Delete can return Library errors.
+func Delete(path string) *error.Error {
+	if rmErr := os.Remove(path); rmErr != nil && !os.IsNotExist(rmErr) {
+		return error.Wrap(error.Library, rmErr, "Could not delete save "+
+			"file.").With("path", path)
+	}
+	return nil
+}
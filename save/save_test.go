@@ -0,0 +1,273 @@
+package save
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phil-mansfield/rogue/error"
+	"github.com/phil-mansfield/rogue/item"
+	"github.com/phil-mansfield/rogue/rng"
+)
+
+// testGame returns a game which has been played for a few turns.
+func testGame(t *testing.T) *Game {
+	g, err := New(12, true)
+	if err != nil {
+		t.Fatalf("New failed: %s", err.Error())
+	}
+
+	g.Turn = 57
+	g.RNG.Get(rng.Combat).Uint64()
+	g.RNG.Get(rng.Loot).Uint64()
+	g.Knowledge.Identify(item.PotionOfHealing)
+	if _, err := g.Items.Singleton(item.Item{Count: 3, Type: item.PotionOfHealing}); err != nil {
+		t.Fatalf("Singleton failed: %s", err.Error())
+	}
+	g.AddMessage("You feel better.")
+	g.AddMessage("")
+	return g
+}
+
+func TestRoundTrip(t *testing.T) {
+	g := testGame(t)
+	data, err := Encode(g)
+	if err != nil {
+		t.Fatalf("Encode failed: %s", err.Error())
+	}
+
+	loaded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %s", err.Error())
+	}
+
+	if loaded.Turn != 57 || !loaded.Permadeath || loaded.Dead {
+		t.Errorf("Loaded Turn = %d, Permadeath = %v, Dead = %v.",
+			loaded.Turn, loaded.Permadeath, loaded.Dead)
+	}
+	if !loaded.Knowledge.IsIdentified(item.PotionOfHealing) {
+		t.Errorf("Identified potion was forgotten.")
+	}
+	if len(loaded.Messages) != 2 || loaded.Messages[0] != "You feel better." {
+		t.Errorf("Loaded messages are %q.", loaded.Messages)
+	}
+
+	// The loaded game encodes identically and continues with the same
+	// random numbers.
+	reencoded, err := Encode(loaded)
+	if err != nil {
+		t.Fatalf("Encode of loaded game failed: %s", err.Error())
+	} else if !bytes.Equal(data, reencoded) {
+		t.Errorf("Loaded game encodes differently from the original.")
+	}
+	for _, name := range []string{rng.Combat, rng.Loot, rng.MapGen} {
+		if g.RNG.Get(name).Uint64() != loaded.RNG.Get(name).Uint64() {
+			t.Errorf("Stream '%s' differs after loading.", name)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data, err := Encode(testGame(t))
+	if err != nil {
+		t.Fatalf("Encode failed: %s", err.Error())
+	}
+
+	_, _, err = decodeSections(data)
+	if err != nil {
+		t.Fatalf("decodeSections failed: %s", err.Error())
+	}
+
+	corrupt := func(i int) []byte {
+		bad := append([]byte{}, data...)
+		bad[i] ^= 0xff
+		return bad
+	}
+	noMessages := func() []byte {
+		_, sections, _ := decodeSections(data)
+		delete(sections, messageTag)
+		return encodeSections(FormatVersion, sections)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"truncated", data[:len(data)/2]},
+		{"magic", corrupt(0)},
+		{"version", encodeSections(FormatVersion+1, map[string][]byte{})},
+		{"checksum", corrupt(len(data) / 2)},
+		{"missing section", noMessages()},
+	}
+
+	for _, test := range tests {
+		if _, err := Decode(test.data); err == nil {
+			t.Errorf("%s: Decode succeeded.", test.name)
+		} else if err.Code != error.Value {
+			t.Errorf("%s: Decode gave %s instead of a Value error.",
+				test.name, err.Code)
+		}
+	}
+}
+
+func TestMigration(t *testing.T) {
+	data, err := Encode(testGame(t))
+	if err != nil {
+		t.Fatalf("Encode failed: %s", err.Error())
+	}
+
+	defer func(v uint16, m []Migration) {
+		currentVersion, migrations = v, m
+	}(currentVersion, migrations)
+
+	if _, err := Decode(data); err != nil {
+		t.Fatalf("Decode failed before adding a version: %s", err.Error())
+	}
+
+	// Version 2 adds a message to the log.
+	currentVersion++
+	if _, err := Decode(data); err == nil || err.Code != error.Sanity {
+		t.Errorf("Decode without a migration gave %v.", err)
+	}
+
+	migrations = append(migrations, func(sections map[string][]byte) *error.Error {
+		messages, err := decodeMessages(sections[messageTag])
+		if err != nil {
+			return err
+		}
+		sections[messageTag] = encodeMessages(append(messages, "Migrated."))
+		return nil
+	})
+
+	g, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode of an old save failed: %s", err.Error())
+	} else if last := g.Messages[len(g.Messages)-1]; last != "Migrated." {
+		t.Errorf("Migration did not run; last message is '%s'.", last)
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "game.sav")
+
+	if _, err := Read(path); err == nil || !errors.Is(err, error.MissingFile) {
+		t.Errorf("Read of a missing file gave %v.", err)
+	}
+
+	g := testGame(t)
+	if err := Store(path, g); err != nil {
+		t.Fatalf("Store failed: %s", err.Error())
+	}
+	loaded, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read failed: %s", err.Error())
+	} else if loaded.Turn != g.Turn {
+		t.Errorf("Read game has Turn = %d instead of %d.", loaded.Turn, g.Turn)
+	}
+
+	// Death without permadeath keeps the save.
+	g.Dead, g.Permadeath = true, false
+	if err := Store(path, g); err != nil {
+		t.Fatalf("Store failed: %s", err.Error())
+	} else if _, statErr := os.Stat(path); statErr != nil {
+		t.Errorf("Save was deleted without permadeath.")
+	}
+
+	g.Permadeath = true
+	if err := Store(path, g); err != nil {
+		t.Fatalf("Store failed: %s", err.Error())
+	} else if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+		t.Errorf("Save was not deleted after a permadeath.")
+	}
+	if err := Delete(path); err != nil {
+		t.Errorf("Deleting a missing save failed: %s", err.Error())
+	}
+
+	// Only the save of a permadeath game is consumed.
+	for _, permadeath := range []bool{false, true} {
+		g.Dead, g.Permadeath = false, permadeath
+		if err := Store(path, g); err != nil {
+			t.Fatalf("Store failed: %s", err.Error())
+		}
+		if err := Consume(path, g); err != nil {
+			t.Fatalf("Consume failed: %s", err.Error())
+		}
+		_, statErr := os.Stat(path)
+		if permadeath && !os.IsNotExist(statErr) {
+			t.Errorf("Permadeath save was not consumed.")
+		} else if !permadeath && statErr != nil {
+			t.Errorf("Save was consumed without permadeath.")
+		}
+	}
+}
+
+func TestAddMessage(t *testing.T) {
+	g := &Game{}
+	for i := 0; i < MessageHistory+5; i++ {
+		g.AddMessage(string(rune('a' + i%26)))
+	}
+	if len(g.Messages) != MessageHistory || g.Messages[0] != "f" {
+		t.Errorf("Log has %d messages starting with '%s'.",
+			len(g.Messages), g.Messages[0])
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	g, err := New(12, true)
+	if err != nil {
+		f.Fatalf("New failed: %s", err.Error())
+	}
+	g.AddMessage("You feel better.")
+	data, err := Encode(g)
+	if err != nil {
+		f.Fatalf("Encode failed: %s", err.Error())
+	}
+	f.Add(data)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzDecode(t, data)
+
+		// Random input will almost never have a valid checksum, so also
+		// try it with a corrected one to exercise the checks behind it.
+		if len(data) >= headerSize+checksumSize {
+			fixed := append([]byte{}, data...)
+			body := fixed[:len(fixed)-checksumSize]
+			binary.LittleEndian.PutUint32(
+				fixed[len(body):], crc32.ChecksumIEEE(body),
+			)
+			fuzzDecode(t, fixed)
+		}
+	})
+}
+
+func fuzzDecode(t *testing.T, data []byte) {
+	g, err := Decode(data)
+	if err != nil {
+		if err.Code != error.Value {
+			t.Fatalf("Decode gave a %s error: %s", err.Code, err.Error())
+		}
+		return
+	}
+
+	// Anything accepted must survive a round trip unchanged.
+	reencoded, err := Encode(g)
+	if err != nil {
+		t.Fatalf("Encode of decoded game failed: %s", err.Error())
+	}
+	loaded, err := Decode(reencoded)
+	if err != nil {
+		t.Fatalf("Decode of reencoded game failed: %s", err.Error())
+	}
+	again, err := Encode(loaded)
+	if err != nil {
+		t.Fatalf("Encode of reloaded game failed: %s", err.Error())
+	} else if !bytes.Equal(reencoded, again) {
+		t.Fatalf("Decoded game encodes differently after a round trip.")
+	}
+}